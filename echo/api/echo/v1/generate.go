@@ -0,0 +1,5 @@
+// Package echov1 is the gRPC API, generated from list.proto, and the
+// protobuf bodies of the http API, generated from payload.proto.
+package echov1
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative list.proto payload.proto
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/bench"
+	apiclient "github.com/alipourhabibi/exercises-journal/echo/internal/client"
+	"github.com/spf13/cobra"
+)
+
+var benchFlags struct {
+	server      string
+	token       string
+	list        string
+	mix         string
+	concurrency int
+	rate        float64
+	duration    time.Duration
+	prefill     int
+	values      int
+	output      string
+}
+
+var benchCmd = &cobra.Command{
+	Use:           "bench",
+	Short:         "drive a running server with a mix of operations and report how it answered",
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE:          runBench,
+}
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+	flags := benchCmd.Flags()
+	flags.StringVar(&benchFlags.server, "server", "http://127.0.0.1:8082", "http address of the server")
+	flags.StringVar(&benchFlags.token, "token", "", "api key or JWT, when the server has auth enabled")
+	flags.StringVar(&benchFlags.list, "list", "", "named list to work on instead of the global one")
+	flags.StringVar(&benchFlags.mix, "mix", "insert=1,remove=1,get=4,find=4", "relative weight of each operation")
+	flags.IntVarP(&benchFlags.concurrency, "concurrency", "c", 8, "requests in flight at once")
+	flags.Float64Var(&benchFlags.rate, "rate", 0, "requests per second across all workers; 0 is as fast as possible")
+	flags.DurationVarP(&benchFlags.duration, "duration", "d", 10*time.Second, "how long to send for")
+	flags.IntVar(&benchFlags.prefill, "prefill", 1000, "values appended before the run")
+	flags.IntVar(&benchFlags.values, "values", 1000, "values are drawn from 1 to this")
+	flags.StringVarP(&benchFlags.output, "output", "o", outputTable, "output format: table or json")
+}
+
+func runBench(cmd *cobra.Command, args []string) error {
+	if benchFlags.output != outputTable && benchFlags.output != outputJSON {
+		return usageError(cmd, fmt.Errorf("unknown output %q", benchFlags.output))
+	}
+	mix, err := bench.ParseMix(benchFlags.mix)
+	if err != nil {
+		return usageError(cmd, err)
+	}
+	// keep a connection per worker instead of redialing for every request
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = benchFlags.concurrency
+	c, err := apiclient.New(
+		apiclient.WithServer(benchFlags.server),
+		apiclient.WithToken(benchFlags.token),
+		apiclient.WithHTTPClient(&http.Client{Transport: transport}),
+	)
+	if err != nil {
+		return usageError(cmd, err)
+	}
+	runner, err := bench.New(
+		bench.WithClient(c),
+		bench.WithList(benchFlags.list),
+		bench.WithMix(mix),
+		bench.WithConcurrency(benchFlags.concurrency),
+		bench.WithRate(benchFlags.rate),
+		bench.WithDuration(benchFlags.duration),
+		bench.WithPrefill(benchFlags.prefill),
+		bench.WithValues(benchFlags.values),
+	)
+	if err != nil {
+		return usageError(cmd, err)
+	}
+
+	// an interrupt ends the run early but still reports it
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	rep, err := runner.Run(ctx)
+	if err != nil {
+		return failed(cmd, err)
+	}
+
+	out := cmd.OutOrStdout()
+	if benchFlags.output == outputJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	}
+	fmt.Fprintf(out, "%d requests in %.2fs, %.1f/s, %d errors\n\n", rep.Requests, rep.Duration, rep.Throughput, rep.Errors)
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "OP\tREQUESTS\tERRORS\tRPS\tP50\tP90\tP99\tP99.9\tMAX\t")
+	row := func(op string, requests, errors int64, rps float64, l bench.Latency) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.1f\t%.2fms\t%.2fms\t%.2fms\t%.2fms\t%.2fms\t\n",
+			op, requests, errors, rps, l.P50, l.P90, l.P99, l.P999, l.Max)
+	}
+	for _, op := range bench.Ops {
+		if r, ok := rep.Ops[op]; ok {
+			row(op, r.Requests, r.Errors, r.Throughput, r.Latency)
+		}
+	}
+	row("all", rep.Requests, rep.Errors, rep.Throughput, rep.Latency)
+	w.Flush()
+	if len(rep.ErrorCodes) > 0 {
+		fmt.Fprintln(out, "\nerrors:")
+		for _, code := range rep.Codes() {
+			fmt.Fprintf(out, "  %s: %d\n", code, rep.ErrorCodes[code])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/bench"
+)
+
+func TestBench(t *testing.T) {
+	dir := t.TempDir()
+	port := freePort(t)
+	path := filepath.Join(dir, "config.yaml")
+	writeConfig(t, path, port, "error")
+	startServe(t, path)
+
+	var stdout bytes.Buffer
+	rootCmd.SetOut(&stdout)
+	rootCmd.SetArgs([]string{"bench", "--server", fmt.Sprintf("http://127.0.0.1:%d", port),
+		"-d", "300ms", "-c", "4", "--prefill", "50", "--mix", "insert=1,get=1", "-o", "json"})
+	defer rootCmd.SetArgs(nil)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("bench failed: %v", err)
+	}
+
+	var rep bench.Report
+	err = json.Unmarshal(stdout.Bytes(), &rep)
+	if err != nil {
+		t.Fatalf("bench printed %q: %v", stdout.String(), err)
+	}
+	if rep.Requests == 0 || rep.Ops[bench.OpInsert].Requests == 0 || rep.Ops[bench.OpGet].Requests == 0 {
+		t.Errorf("bench sent nothing: %+v", rep)
+	}
+	if _, ok := rep.Ops[bench.OpFind]; ok {
+		t.Errorf("bench sent finds outside the mix")
+	}
+	if rep.Latency.P50 <= 0 || rep.Latency.P50 > rep.Latency.Max {
+		t.Errorf("bench reported latency %+v", rep.Latency)
+	}
+}
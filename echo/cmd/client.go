@@ -0,0 +1,321 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	apiclient "github.com/alipourhabibi/exercises-journal/echo/internal/client"
+	"github.com/spf13/cobra"
+)
+
+// exit codes of the client commands, by what the server answered
+const (
+	exitFailure      = 1 // no answer from the server, or an unexpected one
+	exitUsage        = 2 // invalid arguments, or a 400
+	exitAuth         = 3 // 401 or 403
+	exitNotFound     = 4 // 404
+	exitConflict     = 5 // 409
+	exitPrecondition = 6 // 412
+	exitRetry        = 7 // 421, 429 or 503: try again, or at another server
+	exitServer       = 8 // any other 5xx
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// ExitError ends the process with Code. What went wrong was already
+// printed.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+var clientFlags struct {
+	server string
+	token  string
+	list   string
+	output string
+}
+
+var clientCmd = &cobra.Command{
+	Use:   "client",
+	Short: "talk to a running server",
+}
+
+func init() {
+	rootCmd.AddCommand(clientCmd)
+	flags := clientCmd.PersistentFlags()
+	flags.StringVar(&clientFlags.server, "server", "http://127.0.0.1:8082", "http address of the server")
+	flags.StringVar(&clientFlags.token, "token", "", "api key or JWT, when the server has auth enabled")
+	flags.StringVar(&clientFlags.list, "list", "", "named list to work on instead of the global one")
+	flags.StringVarP(&clientFlags.output, "output", "o", outputTable, "output format: table or json")
+
+	insert := clientCommand("insert <index> <value>", "insert a value at an index", 2, runInsert)
+	insert.Flags().Uint64("if-version", 0, "only insert if the list is at this version")
+	remove := clientCommand("remove <index>", "remove the value at an index", 1, runRemove)
+	remove.Flags().Uint64("if-version", 0, "only remove if the list is at this version")
+	export := clientCommand("export", "write the whole list as json, csv or ndjson", 0, runExport)
+	export.Flags().String("format", "json", "json, csv or ndjson")
+	export.Flags().StringP("file", "f", "", "file to write to instead of stdout")
+	imp := clientCommand("import [file]", "append rows from a file, or stdin, to the list", -1, runImport)
+	imp.Flags().String("format", "", "json, csv or ndjson; by default taken from the file name, else json")
+	imp.Flags().String("mode", "append", "append, or replace the elements")
+
+	clientCmd.AddCommand(
+		insert,
+		remove,
+		clientCommand("get <index>", "show the value at an index", 1, runGet),
+		clientCommand("find <value>", "show the first index of a value", 1, runFind),
+		clientCommand("list", "show the named lists", 0, runList),
+		export,
+		imp,
+	)
+}
+
+// clientCommand builds a subcommand taking nargs arguments, or at most one
+// when nargs is negative. Errors are printed here, so cobra only passes the
+// exit code on.
+func clientCommand(use, short string, nargs int, run func(cmd *cobra.Command, c *apiclient.Client, args []string) error) *cobra.Command {
+	return &cobra.Command{
+		Use:           use,
+		Short:         short,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (nargs >= 0 && len(args) != nargs) || (nargs < 0 && len(args) > 1) {
+				return usageError(cmd, fmt.Errorf("wrong number of arguments"))
+			}
+			if clientFlags.output != outputTable && clientFlags.output != outputJSON {
+				return usageError(cmd, fmt.Errorf("unknown output %q", clientFlags.output))
+			}
+			c, err := apiclient.New(
+				apiclient.WithServer(clientFlags.server),
+				apiclient.WithToken(clientFlags.token),
+			)
+			if err != nil {
+				return usageError(cmd, err)
+			}
+			err = run(cmd, c, args)
+			if err != nil {
+				return failed(cmd, err)
+			}
+			return nil
+		},
+	}
+}
+
+func usageError(cmd *cobra.Command, err error) error {
+	fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\nUsage: %s\n", err, cmd.UseLine())
+	return &ExitError{Code: exitUsage, Err: err}
+}
+
+// failed prints err and picks the exit code for it. Errors of the server
+// are printed as they were sent when the output is json.
+func failed(cmd *cobra.Command, err error) error {
+	var exit *ExitError
+	if errors.As(err, &exit) {
+		return err
+	}
+	var apiErr *apiclient.Error
+	if !errors.As(err, &apiErr) {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", err)
+		return &ExitError{Code: exitFailure, Err: err}
+	}
+	if clientFlags.output == outputJSON {
+		json.NewEncoder(cmd.ErrOrStderr()).Encode(apiErr)
+	} else {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", apiErr)
+	}
+	return &ExitError{Code: exitCode(apiErr.Status), Err: err}
+}
+
+func exitCode(status int) int {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusUnsupportedMediaType, http.StatusRequestEntityTooLarge:
+		return exitUsage
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return exitAuth
+	case http.StatusNotFound:
+		return exitNotFound
+	case http.StatusConflict:
+		return exitConflict
+	case http.StatusPreconditionFailed:
+		return exitPrecondition
+	case http.StatusMisdirectedRequest, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return exitRetry
+	}
+	if status >= http.StatusInternalServerError {
+		return exitServer
+	}
+	return exitFailure
+}
+
+// render prints v as JSON, or as a table of header and rows.
+func render(cmd *cobra.Command, v any, header []string, rows ...[]any) error {
+	out := cmd.OutOrStdout()
+	if clientFlags.output == outputJSON {
+		return json.NewEncoder(out).Encode(v)
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fields := make([]string, len(row))
+		for i, field := range row {
+			fields[i] = fmt.Sprint(field)
+		}
+		fmt.Fprintln(w, strings.Join(fields, "\t"))
+	}
+	return w.Flush()
+}
+
+// entityResult is what the commands on a single element print.
+type entityResult struct {
+	Index   uint   `json:"index"`
+	Value   int    `json:"value"`
+	Version uint64 `json:"version"`
+}
+
+func renderEntity(cmd *cobra.Command, e apiclient.Entity, version uint64) error {
+	return render(cmd, entityResult{Index: e.Index, Value: e.Value, Version: version},
+		[]string{"INDEX", "VALUE", "VERSION"}, []any{e.Index, e.Value, version})
+}
+
+func parseIndex(cmd *cobra.Command, arg string) (uint, error) {
+	index, err := strconv.ParseUint(arg, 10, 32)
+	if err != nil {
+		return 0, usageError(cmd, fmt.Errorf("invalid index %q", arg))
+	}
+	return uint(index), nil
+}
+
+func parseValue(cmd *cobra.Command, arg string) (int, error) {
+	value, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, usageError(cmd, fmt.Errorf("invalid value %q", arg))
+	}
+	return value, nil
+}
+
+func runInsert(cmd *cobra.Command, c *apiclient.Client, args []string) error {
+	index, err := parseIndex(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	value, err := parseValue(cmd, args[1])
+	if err != nil {
+		return err
+	}
+	ifVersion, _ := cmd.Flags().GetUint64("if-version")
+	version, err := c.Insert(cmd.Context(), clientFlags.list, index, value, ifVersion)
+	if err != nil {
+		return err
+	}
+	return renderEntity(cmd, apiclient.Entity{Index: index, Value: value}, version)
+}
+
+func runRemove(cmd *cobra.Command, c *apiclient.Client, args []string) error {
+	index, err := parseIndex(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	ifVersion, _ := cmd.Flags().GetUint64("if-version")
+	version, err := c.Remove(cmd.Context(), clientFlags.list, index, ifVersion)
+	if err != nil {
+		return err
+	}
+	return render(cmd, map[string]any{"index": index, "version": version},
+		[]string{"INDEX", "VERSION"}, []any{index, version})
+}
+
+func runGet(cmd *cobra.Command, c *apiclient.Client, args []string) error {
+	index, err := parseIndex(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	e, version, err := c.Get(cmd.Context(), clientFlags.list, index)
+	if err != nil {
+		return err
+	}
+	return renderEntity(cmd, e, version)
+}
+
+func runFind(cmd *cobra.Command, c *apiclient.Client, args []string) error {
+	value, err := parseValue(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	e, version, err := c.Find(cmd.Context(), clientFlags.list, value)
+	if err != nil {
+		return err
+	}
+	return renderEntity(cmd, e, version)
+}
+
+func runList(cmd *cobra.Command, c *apiclient.Client, args []string) error {
+	lists, err := c.Lists(cmd.Context())
+	if err != nil {
+		return err
+	}
+	rows := make([][]any, 0, len(lists))
+	for _, l := range lists {
+		rows = append(rows, []any{l.Name, l.Size, l.MaxSize})
+	}
+	return render(cmd, lists, []string{"NAME", "SIZE", "MAX_SIZE"}, rows...)
+}
+
+func runExport(cmd *cobra.Command, c *apiclient.Client, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	path, _ := cmd.Flags().GetString("file")
+	out := cmd.OutOrStdout()
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	return c.Export(cmd.Context(), clientFlags.list, format, out)
+}
+
+func runImport(cmd *cobra.Command, c *apiclient.Client, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	mode, _ := cmd.Flags().GetString("mode")
+	in := cmd.InOrStdin()
+	if len(args) == 1 && args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+		if format == "" {
+			format = strings.TrimPrefix(filepath.Ext(args[0]), ".")
+		}
+	}
+	if format == "" {
+		format = "json"
+	}
+	result, err := c.Import(cmd.Context(), clientFlags.list, format, mode, in)
+	if err != nil {
+		return err
+	}
+	return render(cmd, result, []string{"IMPORTED", "VERSION"}, []any{result.Imported, result.Version})
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runClient runs the client command with args against server and returns
+// what it printed and its exit code.
+func runClient(t *testing.T, server string, args ...string) (string, string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	rootCmd.SetOut(&stdout)
+	rootCmd.SetErr(&stderr)
+	rootCmd.SetArgs(append([]string{"client", "--server", server}, args...))
+	defer rootCmd.SetArgs(nil)
+	err := rootCmd.Execute()
+	var exit *ExitError
+	switch {
+	case errors.As(err, &exit):
+		return stdout.String(), stderr.String(), exit.Code
+	case err != nil:
+		t.Fatalf("client %v failed: %v", args, err)
+	}
+	return stdout.String(), stderr.String(), 0
+}
+
+func TestClient(t *testing.T) {
+	dir := t.TempDir()
+	port := freePort(t)
+	path := filepath.Join(dir, "config.yaml")
+	writeConfig(t, path, port, "error")
+	startServe(t, path)
+	server := fmt.Sprintf("http://127.0.0.1:%d", port)
+
+	for i, value := range []string{"10", "20", "30"} {
+		_, stderr, code := runClient(t, server, "-o", "table", "insert", fmt.Sprint(i), value)
+		if code != 0 {
+			t.Fatalf("insert %s exited with %d: %s", value, code, stderr)
+		}
+	}
+
+	stdout, _, code := runClient(t, server, "-o", "json", "get", "1")
+	var got entityResult
+	if err := json.Unmarshal([]byte(stdout), &got); code != 0 || err != nil {
+		t.Fatalf("get printed %q and exited with %d", stdout, code)
+	}
+	if got != (entityResult{Index: 1, Value: 20, Version: 3}) {
+		t.Errorf("get printed %+v", got)
+	}
+
+	stdout, _, code = runClient(t, server, "-o", "table", "find", "30")
+	if code != 0 || !strings.Contains(stdout, "INDEX") || !strings.Contains(stdout, "30") {
+		t.Errorf("find printed %q and exited with %d", stdout, code)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"missing index", []string{"get", "9"}, exitNotFound},
+		{"stale version", []string{"remove", "0", "--if-version", "1"}, exitPrecondition},
+		{"bad argument", []string{"get", "x"}, exitUsage},
+		{"missing list", []string{"get", "0", "--list", "nope"}, exitNotFound},
+		{"no server", []string{"get", "0", "--list", "", "--server", "http://127.0.0.1:1"}, exitFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, stderr, code := runClient(t, server, append([]string{"-o", "table"}, tt.args...)...)
+			if code != tt.code {
+				t.Errorf("exited with %d, want %d: %s", code, tt.code, stderr)
+			}
+			if stderr == "" {
+				t.Errorf("printed no error")
+			}
+		})
+	}
+
+	export := filepath.Join(dir, "numbers.csv")
+	if _, stderr, code := runClient(t, server, "export", "--format", "csv", "--file", export); code != 0 {
+		t.Fatalf("export exited with %d: %s", code, stderr)
+	}
+	stdout, stderr, code := runClient(t, server, "-o", "json", "import", export, "--mode", "replace")
+	if code != 0 {
+		t.Fatalf("import exited with %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, `"imported":3`) {
+		t.Errorf("import printed %q", stdout)
+	}
+	data, _ := os.ReadFile(export)
+	if !strings.Contains(string(data), "2,30") {
+		t.Errorf("export wrote %q", data)
+	}
+}
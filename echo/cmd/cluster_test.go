@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestCluster(t *testing.T) {
+	dir := t.TempDir()
+	ports := []uint{freePort(t), freePort(t), freePort(t)}
+	bases := make([]string, len(ports))
+	for i, port := range ports {
+		id := fmt.Sprintf("node%d", i+1)
+		bases[i] = fmt.Sprintf("http://127.0.0.1:%d", port)
+		address := fmt.Sprintf("127.0.0.1:%d", freePort(t))
+		cluster := fmt.Sprintf("  role: standalone\n\ncluster:\n  enabled: true\n  node_id: %s\n  address: %s\n  dir: %s\n  bootstrap: %t\n",
+			id, address, filepath.Join(dir, id), i == 0)
+		path := filepath.Join(dir, id+".yaml")
+		writeReplicationConfig(t, path, port, cluster)
+		startServe(t, path)
+
+		if i == 0 {
+			// node1 leads the cluster it bootstrapped once it elected itself
+			waitFor(t, func() bool {
+				return put(bases[0]+"/api/v1/numbers", 1) == nil
+			})
+			continue
+		}
+		body := fmt.Sprintf(`{"id": %q, "address": %q}`, id, address)
+		if res := send(t, http.MethodPost, bases[0]+"/admin/cluster/members", body); res.StatusCode != http.StatusCreated {
+			t.Fatalf("Can't add %s: %d", id, res.StatusCode)
+		}
+	}
+
+	if err := put(bases[0]+"/api/v1/numbers", 2); err != nil {
+		t.Fatalf("Can't insert on the leader: %v", err)
+	}
+	for _, base := range bases[1:] {
+		waitFor(t, func() bool {
+			got, err := valueAt(base+"/api/v1/numbers", 0)
+			return err == nil && got == 2
+		})
+	}
+
+	res := send(t, http.MethodPut, bases[1]+"/api/v1/numbers", `{"index": 0, "value": 3}`)
+	if res.StatusCode != http.StatusMisdirectedRequest {
+		t.Errorf("Follower should refuse writes, got %d", res.StatusCode)
+	}
+	res = send(t, http.MethodPost, bases[1]+"/api/v1/lists", `{"name": "a"}`)
+	if res.StatusCode != http.StatusMisdirectedRequest {
+		t.Errorf("Follower should refuse new lists, got %d", res.StatusCode)
+	}
+
+	linearizable := func(base string) int {
+		req, _ := http.NewRequest(http.MethodGet, base+"/api/v1/numbers/index/0", nil)
+		req.Header.Set("X-Read-Consistency", "linearizable")
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Can't read from %s: %v", base, err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	if status := linearizable(bases[0]); status != http.StatusOK {
+		t.Errorf("Leader should serve linearizable reads, got %d", status)
+	}
+	if status := linearizable(bases[1]); status != http.StatusMisdirectedRequest {
+		t.Errorf("Follower should refuse linearizable reads, got %d", status)
+	}
+
+	if res := send(t, http.MethodDelete, bases[0]+"/admin/cluster/members/node3", ""); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("Can't remove node3: %d", res.StatusCode)
+	}
+	if res := send(t, http.MethodDelete, bases[0]+"/admin/cluster/members/node3", ""); res.StatusCode != http.StatusNotFound {
+		t.Errorf("Removing node3 twice should be a 404, got %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	echov1 "github.com/alipourhabibi/exercises-journal/echo/api/echo/v1"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/audit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func writeGRPCConfig(t *testing.T, path string, port, grpcPort uint) {
+	data := fmt.Sprintf("server:\n  port: %d\n\ngrpc:\n  port: %d\n\nlogger:\n  level: error\n\nevents:\n  buffer: 16\n", port, grpcPort)
+	err := os.WriteFile(path, []byte(data), 0600)
+	if err != nil {
+		t.Fatalf("Can't write config: %v", err)
+	}
+}
+
+func dialGRPC(t *testing.T, port uint) echov1.ListServiceClient {
+	conn, err := grpc.NewClient(fmt.Sprintf("127.0.0.1:%d", port), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Can't dial gRPC: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return echov1.NewListServiceClient(conn)
+}
+
+func TestGRPCSharesListWithHTTP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	port, grpcPort, nextGRPCPort := freePort(t), freePort(t), freePort(t)
+	writeGRPCConfig(t, path, port, grpcPort)
+	startServe(t, path)
+	base := fmt.Sprintf("http://127.0.0.1:%d/api/v1/numbers", port)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c := dialGRPC(t, grpcPort)
+	// resuming from version 0 replays whatever happens before the stream
+	// is set up on the server
+	watch, err := c.Watch(ctx, &echov1.WatchRequest{LastVersion: new(uint64)})
+	if err != nil {
+		t.Fatalf("Can't watch: %v", err)
+	}
+
+	res, err := c.Insert(ctx, &echov1.InsertRequest{Index: 0, Value: 5})
+	if err != nil {
+		t.Fatalf("Can't insert over gRPC: %v", err)
+	}
+	if res.Version != 1 {
+		t.Fatalf("Insert returned version %d, want 1", res.Version)
+	}
+	err = put(base, 6)
+	if err != nil {
+		t.Fatalf("Can't insert over http: %v", err)
+	}
+	got, err := c.Get(ctx, &echov1.GetRequest{Index: 1})
+	if err != nil {
+		t.Fatalf("Can't get over gRPC: %v", err)
+	}
+	if got.Value != 5 || got.Version != 2 {
+		t.Fatalf("Got value %d at version %d, want 5 at 2", got.Value, got.Version)
+	}
+	_, err = c.Remove(ctx, &echov1.RemoveRequest{Index: 0, IfVersion: new(uint64)})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("Remove with a stale version returned %v, want FailedPrecondition", err)
+	}
+	_, err = c.Find(ctx, &echov1.FindRequest{List: "missing", Value: 5})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Find in a missing list returned %v, want NotFound", err)
+	}
+	// zero is refused like it is over http
+	_, err = c.Insert(ctx, &echov1.InsertRequest{Index: 0, Value: 0})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Insert of 0 returned %v, want InvalidArgument", err)
+	}
+
+	for _, want := range []int64{5, 6} {
+		e, err := watch.Recv()
+		if err != nil {
+			t.Fatalf("Can't receive event: %v", err)
+		}
+		if e.Op != "insert" || e.Value != want {
+			t.Fatalf("Got %s %d, want insert %d", e.Op, e.Value, want)
+		}
+	}
+
+	// moving the gRPC port ends streams on the old server and serves the
+	// same list on the new one
+	writeGRPCConfig(t, path, port, nextGRPCPort)
+	sighup(t)
+	_, err = watch.Recv()
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("Watch on the old server ended with %v, want Unavailable", err)
+	}
+	next := dialGRPC(t, nextGRPCPort)
+	waitFor(t, func() bool {
+		found, err := next.Find(ctx, &echov1.FindRequest{Value: 6})
+		return err == nil && found.Index == 0
+	})
+}
+
+func TestGRPCAudit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	logPath := filepath.Join(dir, "audit.log")
+	port, grpcPort := freePort(t), freePort(t)
+	data := fmt.Sprintf("server:\n  port: %d\n\ngrpc:\n  port: %d\n\nlogger:\n  level: error\n\naudit:\n  enabled: true\n  path: %s\n  max_size_mb: 1\n", port, grpcPort, logPath)
+	err := os.WriteFile(path, []byte(data), 0600)
+	if err != nil {
+		t.Fatalf("Can't write config: %v", err)
+	}
+	startServe(t, path)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c := dialGRPC(t, grpcPort)
+	_, err = c.Insert(ctx, &echov1.InsertRequest{Index: 0, Value: 5})
+	if err != nil {
+		t.Fatalf("Can't insert over gRPC: %v", err)
+	}
+	_, err = c.Remove(ctx, &echov1.RemoveRequest{Index: 0})
+	if err != nil {
+		t.Fatalf("Can't remove over gRPC: %v", err)
+	}
+
+	records, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Can't read audit log: %v", err)
+	}
+	var ops []string
+	for _, line := range strings.Split(strings.TrimSpace(string(records)), "\n") {
+		var r audit.Record
+		err := json.Unmarshal([]byte(line), &r)
+		if err != nil {
+			t.Fatalf("Bad audit record %q: %v", line, err)
+		}
+		// without auth the actor is where the call came from
+		if r.Actor != "127.0.0.1" {
+			t.Errorf("Record %+v has actor %q, want the peer address", r, r.Actor)
+		}
+		ops = append(ops, r.Op)
+	}
+	if strings.Join(ops, ",") != "insert,remove" {
+		t.Errorf("Audit log holds %v, want the insert and the remove", ops)
+	}
+}
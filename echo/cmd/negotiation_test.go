@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	echov1 "github.com/alipourhabibi/exercises-journal/echo/api/echo/v1"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/codec"
+	"google.golang.org/protobuf/proto"
+)
+
+// exchange sends body with contentType, asking for accept back.
+func exchange(t *testing.T, method, url, contentType, accept string, body []byte) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Can't build request: %v", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("Can't read response: %v", err)
+	}
+	return res, data
+}
+
+// errorCode reads the code of an error body in format c.
+func errorCode(c *codec.Codec, data []byte) (apierror.Code, error) {
+	if c == codec.Protobuf {
+		var e echov1.Error
+		err := c.Unmarshal(data, &e)
+		return apierror.Code(e.Code), err
+	}
+	var e apierror.Error
+	err := c.Unmarshal(data, &e)
+	return e.Code, err
+}
+
+func TestContentNegotiation(t *testing.T) {
+	port := freePort(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, port, "error")
+	startServe(t, path)
+	base := fmt.Sprintf("http://127.0.0.1:%d/api/v1", port)
+
+	for _, c := range codec.Codecs {
+		t.Run(c.MediaType, func(t *testing.T) {
+			body, err := c.Marshal(list.ListEntity{Index: 0, Value: 42})
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			res, data := exchange(t, http.MethodPut, base+"/numbers", c.MediaType, c.MediaType, body)
+			if res.StatusCode != http.StatusCreated {
+				t.Fatalf("PUT = %d %s", res.StatusCode, data)
+			}
+			if got := res.Header.Get("Content-Type"); got != c.MediaType && c != codec.JSON {
+				t.Errorf("Content-Type = %q, want %q", got, c.MediaType)
+			}
+			var got list.ListEntity
+			if err := c.Unmarshal(data, &got); err != nil || got.Value != 42 {
+				t.Errorf("PUT answered %+v, %v", got, err)
+			}
+
+			// bodies fail the same way in every format: the schema first,
+			// then the validator
+			for _, tt := range []struct {
+				body  any
+				proto *echov1.ListEntity
+				code  apierror.Code
+			}{
+				{map[string]any{"index": 0}, &echov1.ListEntity{Index: proto.Uint64(0)}, apierror.CodeInvalidRequest},
+				{map[string]any{"index": 0, "value": 0}, &echov1.ListEntity{Index: proto.Uint64(0), Value: proto.Int64(0)}, apierror.CodeValidationFailed},
+			} {
+				v := tt.body
+				if c == codec.Protobuf {
+					v = tt.proto
+				}
+				body, err := c.Marshal(v)
+				if err != nil {
+					t.Fatalf("Marshal() error = %v", err)
+				}
+				res, data := exchange(t, http.MethodPut, base+"/numbers", c.MediaType, c.MediaType, body)
+				code, err := errorCode(c, data)
+				if err != nil {
+					t.Fatalf("Error body %q: %v", data, err)
+				}
+				if res.StatusCode != http.StatusBadRequest || code != tt.code {
+					t.Errorf("PUT %v = %d %s, want 400 %s", tt.body, res.StatusCode, code, tt.code)
+				}
+			}
+		})
+	}
+
+	body, _ := codec.JSON.Marshal(list.ListEntity{Value: 1})
+	res, _ := exchange(t, http.MethodPut, base+"/numbers", "text/plain", "", body)
+	if res.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("PUT as text/plain = %d, want 415", res.StatusCode)
+	}
+	// batches have no protobuf message
+	res, _ = exchange(t, http.MethodPost, base+"/numbers:batch", codec.Protobuf.MediaType, "", []byte{8, 1})
+	if res.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("POST batch as protobuf = %d, want 415", res.StatusCode)
+	}
+	// nothing is inserted when the answer couldn't be sent
+	res, _ = exchange(t, http.MethodPut, base+"/numbers", codec.JSON.MediaType, "text/html", body)
+	if res.StatusCode != http.StatusNotAcceptable {
+		t.Errorf("PUT accepting text/html = %d, want 406", res.StatusCode)
+	}
+	res, _ = exchange(t, http.MethodGet, base+"/lists", "", codec.Protobuf.MediaType, nil)
+	if res.StatusCode != http.StatusNotAcceptable {
+		t.Errorf("GET lists as protobuf = %d, want 406", res.StatusCode)
+	}
+	res, data := exchange(t, http.MethodGet, base+"/numbers/search", "", codec.CBOR.MediaType, nil)
+	var found struct {
+		Matches []list.ListEntity `json:"matches"`
+	}
+	if err := codec.CBOR.Unmarshal(data, &found); err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("GET search as CBOR = %d %v", res.StatusCode, err)
+	}
+	if len(found.Matches) != len(codec.Codecs) {
+		t.Errorf("List holds %d values, want one per format", len(found.Matches))
+	}
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeReplicationConfig(t *testing.T, path string, port uint, replication string) {
+	data := fmt.Sprintf("server:\n  port: %d\n\nlogger:\n  level: error\n\nevents:\n  buffer: 16\n\nreplication:\n%s", port, replication)
+	err := os.WriteFile(path, []byte(data), 0600)
+	if err != nil {
+		t.Fatalf("Can't write config: %v", err)
+	}
+}
+
+// valueAt returns the value at index of the list under base, or an error
+// if there is none yet.
+func valueAt(base string, index int) (int, error) {
+	res, err := client.Get(fmt.Sprintf("%s/index/%d", base, index))
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("status %d", res.StatusCode)
+	}
+	var e struct {
+		Value int `json:"value"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&e)
+	return e.Value, err
+}
+
+func send(t *testing.T, method, url, body string) *http.Response {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Can't build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, url, err)
+	}
+	res.Body.Close()
+	return res
+}
+
+func TestReplication(t *testing.T) {
+	dir := t.TempDir()
+	leaderPort, rejectPort, forwardPort := freePort(t), freePort(t), freePort(t)
+	leader := fmt.Sprintf("http://127.0.0.1:%d", leaderPort)
+
+	path := filepath.Join(dir, "leader.yaml")
+	writeReplicationConfig(t, path, leaderPort, "  role: leader\n  log_size: 4\n")
+	startServe(t, path)
+
+	// more changes than the log keeps, so followers have to start from a
+	// snapshot
+	for i := 1; i <= 10; i++ {
+		err := put(leader+"/api/v1/numbers", i)
+		if err != nil {
+			t.Fatalf("Can't insert on the leader: %v", err)
+		}
+	}
+	if res := send(t, http.MethodPost, leader+"/api/v1/lists", `{"name": "a"}`); res.StatusCode != http.StatusCreated {
+		t.Fatalf("Can't create a list on the leader: %d", res.StatusCode)
+	}
+	err := put(leader+"/api/v1/lists/a/numbers", 100)
+	if err != nil {
+		t.Fatalf("Can't insert into a on the leader: %v", err)
+	}
+
+	followers := map[uint]string{rejectPort: "false", forwardPort: "true"}
+	for port, forward := range followers {
+		path := filepath.Join(dir, fmt.Sprintf("follower-%d.yaml", port))
+		writeReplicationConfig(t, path, port, fmt.Sprintf("  role: follower\n  leader: %s\n  forward_writes: %s\n", leader, forward))
+		startServe(t, path)
+	}
+
+	// waitValue waits for every follower to hold want at index 0 of path
+	waitValue := func(path string, want int) {
+		t.Helper()
+		for port := range followers {
+			base := fmt.Sprintf("http://127.0.0.1:%d%s", port, path)
+			waitFor(t, func() bool {
+				got, err := valueAt(base, 0)
+				return err == nil && got == want
+			})
+		}
+	}
+	waitValue("/api/v1/numbers", 10)
+	waitValue("/api/v1/lists/a/numbers", 100)
+
+	// live changes follow the snapshot
+	err = put(leader+"/api/v1/numbers", 11)
+	if err != nil {
+		t.Fatalf("Can't insert on the leader: %v", err)
+	}
+	if res := send(t, http.MethodPatch, leader+"/api/v1/lists/a", `{"name": "b"}`); res.StatusCode != http.StatusOK {
+		t.Fatalf("Can't rename a on the leader: %d", res.StatusCode)
+	}
+	waitValue("/api/v1/numbers", 11)
+	waitValue("/api/v1/lists/b/numbers", 100)
+
+	// versions are the leader's, so ETags work across instances
+	leaderTag := send(t, http.MethodGet, leader+"/api/v1/numbers/index/0", "").Header.Get("ETag")
+	for port := range followers {
+		tag := send(t, http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d/api/v1/numbers/index/0", port), "").Header.Get("ETag")
+		if tag != leaderTag {
+			t.Fatalf("Follower on %d has ETag %s, the leader %s", port, tag, leaderTag)
+		}
+	}
+
+	res := send(t, http.MethodPut, fmt.Sprintf("http://127.0.0.1:%d/api/v1/numbers", rejectPort), `{"index": 0, "value": 12}`)
+	if res.StatusCode != http.StatusMisdirectedRequest {
+		t.Fatalf("Follower should refuse writes, got %d", res.StatusCode)
+	}
+
+	res = send(t, http.MethodPut, fmt.Sprintf("http://127.0.0.1:%d/api/v1/numbers", forwardPort), `{"index": 0, "value": 13}`)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("Follower should forward writes, got %d", res.StatusCode)
+	}
+	if got, err := valueAt(leader+"/api/v1/numbers", 0); err != nil || got != 13 {
+		t.Fatalf("Forwarded write should be on the leader, got %d %v", got, err)
+	}
+	waitValue("/api/v1/numbers", 13)
+}
@@ -2,14 +2,31 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"log/slog"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/alipourhabibi/exercises-journal/echo/config"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/audit"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/auth"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/certs"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/cluster"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/idempotency"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list/backend"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/metrics"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/ratelimit"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/replication"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/tracing"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/webhook"
 	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/rpc"
 	"github.com/spf13/cobra"
 )
 
@@ -17,6 +34,20 @@ func init() {
 	rootCmd.AddCommand(runCmd)
 }
 
+var configFile string
+
+// devTLS serves over TLS with a self-signed certificate made at startup.
+var devTLS bool
+
+const (
+	roleLeader   = "leader"
+	roleFollower = "follower"
+)
+
+// logLevel backs every logger we install so a reload can change the level in
+// place instead of replacing the handler.
+var logLevel = new(slog.LevelVar)
+
 func getConfigFilePath(cmd *cobra.Command) string {
 	configFlag := cmd.Flags().Lookup("config")
 	if configFlag != nil {
@@ -28,91 +59,583 @@ func getConfigFilePath(cmd *cobra.Command) string {
 	return ""
 }
 
+// setupLogger applies the logger config. The level is always updated in
+// place; the handler itself is only replaced when replace is set.
+func setupLogger(c config.Config, replace bool) {
+	level, ok := config.MapLevel[strings.ToUpper(c.Logger.Level)]
+	if !ok {
+		level = slog.LevelError
+	}
+	logLevel.Set(level)
+	if !replace {
+		return
+	}
+	// lines logged with a traced context carry its trace and span ids
+	l := slog.New(tracing.NewLogHandler(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		AddSource: c.Logger.AddSource,
+		Level:     logLevel,
+	})))
+	slog.SetDefault(l)
+}
+
+// authConfig turns the auth section of the config into auth options.
+func authConfig(c config.Config) []auth.AuthConfiguration {
+	cfgs := []auth.AuthConfiguration{
+		auth.WithEnabled(c.Auth.Enabled),
+		auth.WithJWT(c.Auth.JWT.Secret, c.Auth.JWT.Issuer),
+	}
+	for _, k := range c.Auth.APIKeys {
+		cfgs = append(cfgs, auth.WithAPIKey(k.Name, k.Key, auth.Role(k.Role)))
+	}
+	return cfgs
+}
+
+// rateLimitConfig turns the rate_limit section of the config into limiter
+// options.
+func rateLimitConfig(c config.Config) []ratelimit.LimiterConfiguration {
+	rl := c.RateLimit
+	keyBy := ratelimit.KeyByIP
+	if rl.Key == string(ratelimit.KeyByAPIKey) {
+		keyBy = ratelimit.KeyByAPIKey
+	}
+	return []ratelimit.LimiterConfiguration{
+		ratelimit.WithEnabled(rl.Enabled),
+		ratelimit.WithKeyBy(keyBy),
+		ratelimit.WithReadLimit(ratelimit.Limit{Rate: rl.Read.Rate, Burst: rl.Read.Burst}),
+		ratelimit.WithWriteLimit(ratelimit.Limit{Rate: rl.Write.Rate, Burst: rl.Write.Burst}),
+	}
+}
+
+// certConfig turns the tls section of the config into cert options. A
+// non-nil devCert is served instead of the files.
+func certConfig(c config.Config, devCert *tls.Certificate) ([]certs.CertConfiguration, error) {
+	t := c.TLS
+	cfgs := []certs.CertConfiguration{}
+	if devCert != nil {
+		cfgs = append(cfgs, certs.WithCertificate(*devCert))
+	} else {
+		cfgs = append(cfgs, certs.WithKeyPair(t.CertFile, t.KeyFile))
+	}
+	if t.MinVersion != "" {
+		v, err := certs.ParseVersion(t.MinVersion)
+		if err != nil {
+			return nil, err
+		}
+		cfgs = append(cfgs, certs.WithMinVersion(v))
+	}
+	if t.ClientCAFile != "" {
+		cfgs = append(cfgs, certs.WithClientCA(t.ClientCAFile))
+	}
+	return cfgs, nil
+}
+
 var runCmd = &cobra.Command{
 	Use:   "run",
 	Short: "run http server",
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		cmd.Flags().String("config", "config/config.yaml", "yaml config file path")
+		cmd.Flags().BoolVar(&devTLS, "dev-tls", false, "serve TLS with a self-signed certificate kept in memory")
 		err := cmd.ParseFlags(args)
 		if err != nil {
 			return err
 		}
-		err = config.Load(getConfigFilePath(cmd))
+		configFile = getConfigFilePath(cmd)
+		err = config.Load(configFile)
 		if err != nil {
 			return err
 		}
-		level, ok := config.MapLevel[strings.ToUpper(config.Confs.Logger.Level)]
-		if !ok {
-			level = slog.LevelError
-		}
-		l := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-			AddSource: config.Confs.Logger.AddSource,
-			Level:     level,
-		}))
-		slog.SetDefault(l)
+		setupLogger(config.Confs, true)
 		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := context.Background()
-		server := handlers.New()
-		var err error
-		go func() {
-			err = server.Start(ctx)
+		return serve(context.Background())
+	},
+}
+
+// serve runs the http server until ctx is done or a SIGINT/SIGTERM arrives.
+// SIGHUP re-reads configFile and applies it without dropping requests.
+func serve(ctx context.Context) error {
+	// other servers in the same process may load their own config, so this
+	// one only reads its copy from here on
+	cfg := config.Confs
+	// stops the follower when serve returns
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	m, err := metrics.New()
+	if err != nil {
+		return err
+	}
+	if cfg.Tracing.Enabled {
+		t := cfg.Tracing
+		tracingCfgs := []tracing.TracingConfiguration{
+			tracing.WithExporter(tracing.Exporter(t.Exporter)),
+			tracing.WithSampleRatio(t.SampleRatio),
+		}
+		if t.ServiceName != "" {
+			tracingCfgs = append(tracingCfgs, tracing.WithServiceName(t.ServiceName))
+		}
+		if t.Exporter == string(tracing.ExporterOTLP) {
+			tracingCfgs = append(tracingCfgs, tracing.WithOTLP(t.OTLP.Endpoint, tracing.Protocol(t.OTLP.Protocol), t.OTLP.Insecure))
+		}
+		tracer, err := tracing.New(tracingCfgs...)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			// flush the spans of the last requests
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := tracer.Shutdown(ctx); err != nil {
+				slog.Error("could not flush spans", "error", err)
+			}
+		}()
+	}
+	repl := cfg.Replication
+	var leader *replication.Leader
+	if repl.Role == roleLeader {
+		leader, err = replication.NewLeader(replication.WithLogSize(repl.LogSize))
+		if err != nil {
+			return err
+		}
+	}
+	var node *cluster.Node
+	if cfg.Cluster.Enabled {
+		c := cfg.Cluster
+		node, err = cluster.New(
+			cluster.WithID(c.NodeID),
+			cluster.WithAddress(c.Address),
+			cluster.WithDir(c.Dir),
+			cluster.WithBootstrap(c.Bootstrap),
+			cluster.WithLogLevel(cfg.Logger.Level),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	kind := backend.Kind(cfg.Lists.Backend)
+	listCfgs := []list.ListConfiguration{
+		list.BootBackend(kind),
+		list.WithMaxSize(cfg.Lists.MaxSize),
+		list.WithLockWait(m.ObserveLockWait),
+	}
+	if cfg.Events.Buffer > 0 {
+		listCfgs = append(listCfgs, list.WithFeed(cfg.Events.Buffer))
+	}
+	if cfg.History.Retention > 0 {
+		listCfgs = append(listCfgs, list.WithHistory(cfg.History.Retention))
+	}
+	registryCfgs := []list.RegistryConfiguration{
+		list.WithDefaultMaxSize(cfg.Lists.MaxSize),
+		list.WithFeedSize(cfg.Events.Buffer),
+		list.WithListLockWait(m.ObserveLockWait),
+		list.WithBackendKind(kind),
+		list.WithHistoryRetention(cfg.History.Retention),
+	}
+	if leader != nil {
+		listCfgs = append(listCfgs, list.WithObserver(leader.ObserveGlobal))
+		registryCfgs = append(registryCfgs,
+			list.WithListObserver(leader.Observe),
+			list.WithRegistryObserver(leader.ObserveRegistry),
+		)
+	}
+	var auditLog *audit.Log
+	if cfg.Audit.Enabled {
+		a := cfg.Audit
+		auditLog, err = audit.New(
+			audit.WithPath(a.Path),
+			audit.WithMaxSize(int64(a.MaxSizeMB)<<20),
+			audit.WithMaxFiles(a.MaxFiles),
+		)
+		if err != nil {
+			return err
+		}
+		defer auditLog.Close()
+	}
+	var hooks *webhook.Dispatcher
+	if cfg.Webhooks.Enabled {
+		w := cfg.Webhooks
+		hooks, err = webhook.New(
+			webhook.WithRetries(w.MaxAttempts, w.Backoff, w.MaxBackoff),
+			webhook.WithTimeout(w.Timeout),
+			webhook.WithQueueSize(w.QueueSize),
+			webhook.WithDeadLetters(w.DeadLetters),
+			webhook.WithLocalTargets(w.AllowLocalTargets),
+		)
+		if err != nil {
+			return err
+		}
+		defer hooks.Close()
+		listCfgs = append(listCfgs, list.WithObserver(func(e list.Event) {
+			hooks.Observe(0, e)
+		}))
+		registryCfgs = append(registryCfgs, list.WithListObserver(hooks.Observe))
+	}
+	if node != nil {
+		listCfgs = append(listCfgs, list.WithProposer(node.Propose))
+		registryCfgs = append(registryCfgs, list.WithRegistryProposer(node.Propose))
+	}
+	l, err := list.New(listCfgs...)
+	if err != nil {
+		return err
+	}
+	r, err := list.NewRegistry(registryCfgs...)
+	if err != nil {
+		return err
+	}
+	if node != nil {
+		// restores the lists from disk before anything reads them
+		err = node.Start(l, r)
+		if err != nil {
+			return err
+		}
+		defer node.Shutdown()
+	}
+	if hooks != nil {
+		err = webhook.WithNames(r.Name)(hooks)
+		if err != nil {
+			return err
+		}
+	}
+	err = metrics.WithLists(l, r)(m)
+	if err != nil {
+		return err
+	}
+	keys, err := idempotency.New(
+		idempotency.WithTTL(cfg.Idempotency.TTL),
+	)
+	if err != nil {
+		return err
+	}
+	authService, err := auth.New(authConfig(cfg)...)
+	if err != nil {
+		return err
+	}
+	limiter, err := ratelimit.New(rateLimitConfig(cfg)...)
+	if err != nil {
+		return err
+	}
+	ready := &handlers.Readiness{}
+	opts := []handlers.ServerConfiguration{
+		handlers.WithMetrics(m),
+		handlers.WithReadiness(ready),
+		handlers.WithList(l),
+		handlers.WithRegistry(r),
+		handlers.WithAuth(authService),
+		handlers.WithRateLimit(limiter),
+		handlers.WithTrustedProxies(cfg.Server.TrustedProxies),
+		handlers.WithIdempotency(keys),
+	}
+	if node != nil {
+		opts = append(opts, handlers.WithCluster(node))
+	}
+	if auditLog != nil {
+		opts = append(opts, handlers.WithAudit(auditLog))
+	}
+	if hooks != nil {
+		opts = append(opts, handlers.WithWebhooks(hooks))
+	}
+
+	rpcOpts := []rpc.ServerConfiguration{
+		rpc.WithList(l),
+		rpc.WithRegistry(r),
+		rpc.WithAuth(authService),
+		rpc.WithRateLimit(limiter),
+	}
+	if auditLog != nil {
+		rpcOpts = append(rpcOpts, rpc.WithAudit(auditLog))
+	}
+	var certService *certs.CertService
+	// kept for reloads, which only pick up new files
+	var devCert *tls.Certificate
+	if cfg.TLS.Enabled || devTLS {
+		if devTLS {
+			cert, err := certs.SelfSigned("localhost", "127.0.0.1", "::1")
 			if err != nil {
-				return
+				return err
+			}
+			devCert = &cert
+			slog.Warn("serving a self-signed certificate; --dev-tls is for development only", "sha256", certs.Fingerprint(cert))
+		}
+		cfgs, err := certConfig(cfg, devCert)
+		if err != nil {
+			return err
+		}
+		certService, err = certs.New(cfgs...)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, handlers.WithTLS(certService.TLSConfig()))
+		rpcOpts = append(rpcOpts, rpc.WithTLS(certService.TLSConfig()))
+	}
+
+	var follower *replication.Follower
+	switch repl.Role {
+	case roleLeader:
+		err = replication.WithSource(l, r)(leader)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, handlers.WithLeader(leader))
+	case roleFollower:
+		follower, err = replication.NewFollower(
+			replication.WithLeader(repl.Leader, repl.Token),
+			replication.WithTarget(l, r),
+		)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, handlers.WithFollower(repl.Leader, repl.ForwardWrites))
+		rpcOpts = append(rpcOpts, rpc.WithReadOnly(repl.Leader))
+	}
+	// a follower only gets traffic once it holds the leader's data
+	synced := func() bool {
+		if follower == nil {
+			return true
+		}
+		select {
+		case <-follower.Synced():
+			return true
+		default:
+			return false
+		}
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	server, err := handlers.New(opts...)
+	if err != nil {
+		return err
+	}
+	err = server.Listen(cfg.Server.Port)
+	if err != nil {
+		return err
+	}
+	grpcServer, err := listenGRPC(cfg.GRPC.Port, rpcOpts)
+	if err != nil {
+		server.Shutdown(ctx)
+		return err
+	}
+	start(ctx, server)
+	start(ctx, grpcServer)
+	ready.Set(synced())
+	if cfg.History.Retention > 0 {
+		go compactHistory(ctx, l, r, cfg.History.CompactInterval)
+	}
+	if follower != nil {
+		go follower.Run(ctx)
+		go func() {
+			select {
+			case <-follower.Synced():
+				ready.Set(true)
+			case <-ctx.Done():
 			}
 		}()
+	}
 
-		sigs := make(chan os.Signal, 1)
-		signal.Notify(sigs, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	for {
+		var sig os.Signal
+		select {
+		case <-ctx.Done():
+			return errors.Join(
+				shutdown(context.Background(), server),
+				shutdown(context.Background(), grpcServer),
+			)
+		case sig = <-sigs:
+		}
 
-		for {
-			sig := <-sigs
-			switch sig {
-			case syscall.SIGHUP:
-				// TODO make function for reloading the configs
-				slog.Info("Received SIGHUP, reloading configuration...")
+		switch sig {
+		case syscall.SIGHUP:
+			slog.Info("Received SIGHUP, reloading configuration...", "path", configFile)
 
-				// reload the config
-				err := config.Load("config/config.yaml")
-				if err != nil {
-					return err
-				}
+			// nothing is applied unless the whole file is valid
+			c, err := config.Read(configFile)
+			if err != nil {
+				slog.Error("could not reload config; keeping the previous one", "error", err)
+				continue
+			}
+			prev := cfg
 
-				// reload the logger with new config
-				level, ok := config.MapLevel[strings.ToUpper(config.Confs.Logger.Level)]
-				if !ok {
-					level = slog.LevelError
+			// bind the new ports before touching anything else so a busy
+			// port leaves the running setup as it was
+			next := server
+			if c.Server.Port != prev.Server.Port {
+				next, err = handlers.New(opts...)
+				if err == nil {
+					err = next.Listen(c.Server.Port)
 				}
-				l := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-					AddSource: config.Confs.Logger.AddSource,
-					Level:     level,
-				}))
-				slog.SetDefault(l)
-
-				// shutdown the prev server
-				// continue with the the last config if failed
-				err = server.Shutdown(ctx)
 				if err != nil {
-					slog.Error("could not start new server", "error", err)
+					slog.Error("could not listen on new port; keeping the previous one", "error", err)
 					continue
 				}
-				server = handlers.New()
-				go func() {
-					if err := server.Start(ctx); err != nil {
-						slog.Error("could not start new server", "error", err)
-						// TODO it return an error but it does the job; think about it later
-						// os.Exit(1)
+			}
+			nextGRPC := grpcServer
+			if c.GRPC.Port != prev.GRPC.Port {
+				nextGRPC, err = listenGRPC(c.GRPC.Port, rpcOpts)
+				if err != nil {
+					slog.Error("could not listen on new gRPC port; keeping the previous one", "error", err)
+					if next != server {
+						next.Shutdown(ctx)
 					}
-				}()
+					continue
+				}
+			}
+
+			cfg = c
+			config.Confs = c
+			err = authService.Reload(authConfig(cfg)...)
+			if err != nil {
+				// the file was validated, so this only fails on a bug
+				slog.Error("could not reload auth; keeping the previous one", "error", err)
+			}
+			err = limiter.Reload(rateLimitConfig(cfg)...)
+			if err != nil {
+				slog.Error("could not reload rate limits; keeping the previous ones", "error", err)
+			}
+			// certificates are read again even if the config didn't
+			// change, since they are usually renewed in place
+			if certService != nil && (c.TLS.Enabled || devTLS) {
+				cfgs, err := certConfig(cfg, devCert)
+				if err == nil {
+					err = certService.Reload(cfgs...)
+				}
+				if err != nil {
+					slog.Error("could not reload certificates; keeping the previous ones", "error", err)
+				}
+			}
+			if c.TLS.Enabled != prev.TLS.Enabled && !devTLS {
+				slog.Warn("turning tls on or off only applies after a restart")
+			}
+			if !slices.Equal(c.Server.TrustedProxies, prev.Server.TrustedProxies) {
+				slog.Warn("trusted proxies only apply after a restart")
+			}
+			setupLogger(cfg, c.Logger.AddSource != prev.Logger.AddSource)
+			r.SetDefaultMaxSize(c.Lists.MaxSize)
+			err = r.SetBackendKind(backend.Kind(c.Lists.Backend))
+			if err != nil {
+				slog.Error("could not change the list backend; keeping the previous one", "error", err)
+			}
+			keys.SetTTL(c.Idempotency.TTL)
+			if (c.History.Retention > 0) != (prev.History.Retention > 0) || c.History.CompactInterval != prev.History.CompactInterval {
+				slog.Warn("turning history on or off and its compact interval only apply after a restart")
+			} else if c.History.Retention > 0 {
+				l.History().SetRetention(c.History.Retention)
+				r.SetHistoryRetention(c.History.Retention)
+			}
 
-			case syscall.SIGINT, syscall.SIGTERM:
-				slog.Info("Received SIGINT/SIGTERM, shutting down...")
-				if err := server.Shutdown(ctx); err != nil {
-					slog.Error("could not gracefully shut down server", "error", err)
+			if next != server || nextGRPC != grpcServer {
+				// keep load balancers away until the new servers took over
+				ready.Set(false)
+				// the new listeners are already accepting, so the old
+				// servers can drain their in-flight requests at their own pace
+				if next != server {
+					start(ctx, next)
+					shutdown(ctx, server)
+					server = next
+				}
+				if nextGRPC != grpcServer {
+					start(ctx, nextGRPC)
+					shutdown(ctx, grpcServer)
+					grpcServer = nextGRPC
 				}
-				os.Exit(0)
+				ready.Set(synced())
 			}
+			if c.Replication != prev.Replication {
+				slog.Warn("replication settings only apply after a restart")
+			}
+			if c.Tracing != prev.Tracing {
+				slog.Warn("tracing settings only apply after a restart")
+			}
+			if c.Webhooks != prev.Webhooks {
+				slog.Warn("webhook settings only apply after a restart")
+			}
+			if c.Audit != prev.Audit {
+				slog.Warn("audit settings only apply after a restart")
+			}
+			if c.Cluster != prev.Cluster {
+				slog.Warn("cluster settings only apply after a restart")
+			}
+			slog.Info("configuration reloaded")
+
+		case syscall.SIGINT, syscall.SIGTERM:
+			slog.Info("Received SIGINT/SIGTERM, shutting down...")
+			shutdown(ctx, server)
+			shutdown(ctx, grpcServer)
+			return nil
 		}
-	},
+	}
+}
+
+// compactHistory drops the changes older than the retention from the
+// history of every list, every interval, until ctx is done.
+func compactHistory(ctx context.Context, l *list.ListService, r *list.Registry, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		dropped := l.History().Compact()
+		r.View(func(lists []list.Named) {
+			for _, n := range lists {
+				if h := n.List.History(); h != nil {
+					dropped += h.Compact()
+				}
+			}
+		})
+		if dropped > 0 {
+			slog.Debug("compacted history", "dropped", dropped)
+		}
+	}
+}
+
+// runner is what serve needs from the http and gRPC servers.
+type runner interface {
+	Start(ctx context.Context) error
+	Shutdown(ctx context.Context) error
+}
+
+// listenGRPC binds a gRPC server to port, or returns nil when port is zero
+// and gRPC is off.
+func listenGRPC(port uint, opts []rpc.ServerConfiguration) (runner, error) {
+	if port == 0 {
+		return nil, nil
+	}
+	s, err := rpc.New(opts...)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Listen(port)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// start serves s in the background; a nil s is a server that is off.
+func start(ctx context.Context, s runner) {
+	if s == nil {
+		return
+	}
+	go func() {
+		if err := s.Start(ctx); err != nil {
+			slog.Error("server stopped", "error", err)
+		}
+	}()
+}
+
+// shutdown stops s gracefully and logs if it couldn't.
+func shutdown(ctx context.Context, s runner) error {
+	if s == nil {
+		return nil
+	}
+	err := s.Shutdown(ctx)
+	if err != nil {
+		slog.Error("could not gracefully shut down server", "error", err)
+	}
+	return err
 }
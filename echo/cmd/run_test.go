@@ -0,0 +1,307 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/config"
+)
+
+func freePort(t *testing.T) uint {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Can't find a free port: %v", err)
+	}
+	defer ln.Close()
+	return uint(ln.Addr().(*net.TCPAddr).Port)
+}
+
+func writeConfig(t *testing.T, path string, port uint, level string) {
+	data := fmt.Sprintf("server:\n  port: %d\n\nlogger:\n  add_source: false\n  level: %s\n", port, level)
+	err := os.WriteFile(path, []byte(data), 0600)
+	if err != nil {
+		t.Fatalf("Can't write config: %v", err)
+	}
+}
+
+// startServe loads the config at path and runs serve until the test ends.
+func startServe(t *testing.T, path string) {
+	configFile = path
+	err := config.Load(configFile)
+	if err != nil {
+		t.Fatalf("Can't load config: %v", err)
+	}
+	setupLogger(config.Confs, true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("serve returned: %v", err)
+		}
+	})
+	waitFor(t, func() bool {
+		return get(fmt.Sprintf("http://127.0.0.1:%d/api/v1/numbers/index/0", config.Confs.Server.Port)) == nil
+	})
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// client opens a connection per request so a request is either refused by a
+// closed listener or fully served, never lost on a reused idle connection.
+var client = &http.Client{
+	Timeout:   2 * time.Second,
+	Transport: &http.Transport{DisableKeepAlives: true},
+}
+
+// get fails only if the server couldn't answer; 404 is still an answer.
+func get(url string) error {
+	res, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode >= 500 {
+		return fmt.Errorf("status %d", res.StatusCode)
+	}
+	return nil
+}
+
+func put(url string, value int) error {
+	body := fmt.Sprintf(`{"index": 0, "value": %d}`, value)
+	req, err := http.NewRequest(http.MethodPut, url, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		return fmt.Errorf("status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// isClosed reports whether err means the listener was gone before the
+// request was accepted: either the dial was refused or the connection was
+// still in the accept backlog when the listener closed.
+func isClosed(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// load hammers the server on port with inserts and reads until stop is
+// closed and returns every failed request. Once port stops accepting
+// connections the workers retry on next, as a client following the reload
+// would; that retry failing means the new listener wasn't up in time.
+func load(port, next uint, stop <-chan struct{}) []error {
+	var (
+		mu       sync.Mutex
+		failures []error
+		wg       sync.WaitGroup
+	)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			current := port
+			for i := 1; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				base := fmt.Sprintf("http://127.0.0.1:%d/api/v1/numbers", current)
+				err := put(base, w*1000000+i)
+				if err == nil {
+					err = get(base + "/index/0")
+				}
+				if err != nil && isClosed(err) && current != next {
+					current = next
+					continue
+				}
+				if err != nil {
+					mu.Lock()
+					failures = append(failures, err)
+					mu.Unlock()
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	return failures
+}
+
+func sighup(t *testing.T) {
+	err := syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	if err != nil {
+		t.Fatalf("Can't send SIGHUP: %v", err)
+	}
+}
+
+func TestReloadLevelUnderLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	port := freePort(t)
+	writeConfig(t, path, port, "error")
+	startServe(t, path)
+
+	stop := make(chan struct{})
+	result := make(chan []error)
+	go func() {
+		result <- load(port, port, stop)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	writeConfig(t, path, port, "debug")
+	sighup(t)
+	waitFor(t, func() bool {
+		return logLevel.Level() == slog.LevelDebug
+	})
+	time.Sleep(100 * time.Millisecond)
+	close(stop)
+
+	failures := <-result
+	if len(failures) != 0 {
+		t.Fatalf("%d requests failed during reload, first: %v", len(failures), failures[0])
+	}
+}
+
+func TestReloadPortUnderLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	oldPort, newPort := freePort(t), freePort(t)
+	writeConfig(t, path, oldPort, "error")
+	startServe(t, path)
+
+	err := put(fmt.Sprintf("http://127.0.0.1:%d/api/v1/numbers", oldPort), 42)
+	if err != nil {
+		t.Fatalf("Can't insert before reload: %v", err)
+	}
+
+	stop := make(chan struct{})
+	result := make(chan []error)
+	go func() {
+		result <- load(oldPort, newPort, stop)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	writeConfig(t, path, newPort, "error")
+	sighup(t)
+	waitFor(t, func() bool {
+		return get(fmt.Sprintf("http://127.0.0.1:%d/api/v1/numbers/index/0", oldPort)) != nil
+	})
+	time.Sleep(100 * time.Millisecond)
+	close(stop)
+
+	failures := <-result
+	if len(failures) != 0 {
+		t.Fatalf("%d requests failed during reload, first: %v", len(failures), failures[0])
+	}
+
+	// the list is shared between the old and the new server
+	res, err := client.Get(fmt.Sprintf("http://127.0.0.1:%d/api/v1/numbers/value/42", newPort))
+	if err != nil {
+		t.Fatalf("Can't reach new server: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("Value inserted before reload should exist, got status %d", res.StatusCode)
+	}
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	port := freePort(t)
+	writeConfig(t, path, port, "error")
+	startServe(t, path)
+
+	// an invalid port must not apply the valid level next to it
+	writeConfig(t, path, 0, "debug")
+	sighup(t)
+	time.Sleep(200 * time.Millisecond)
+	writeConfig(t, path, port, "warn")
+	sighup(t)
+	waitFor(t, func() bool {
+		return logLevel.Level() == slog.LevelWarn
+	})
+
+	if config.Confs.Server.Port != port {
+		t.Fatalf("Port should still be %d but is %d", port, config.Confs.Server.Port)
+	}
+	err := get(fmt.Sprintf("http://127.0.0.1:%d/api/v1/numbers/index/0", port))
+	if err != nil {
+		t.Fatalf("Server should still be serving: %v", err)
+	}
+}
+
+func TestProbes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	port := freePort(t)
+	writeConfig(t, path, port, "error")
+	startServe(t, path)
+	base := fmt.Sprintf("http://127.0.0.1:%d", port)
+
+	for _, probe := range []string{"/healthz", "/readyz"} {
+		res, err := client.Get(base + probe)
+		if err != nil {
+			t.Fatalf("Can't reach %s: %v", probe, err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%s returned %d", probe, res.StatusCode)
+		}
+	}
+
+	err := put(base+"/api/v1/numbers", 7)
+	if err != nil {
+		t.Fatalf("Can't insert: %v", err)
+	}
+	res, err := client.Get(base + "/metrics")
+	if err != nil {
+		t.Fatalf("Can't reach /metrics: %v", err)
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatalf("Can't read /metrics: %v", err)
+	}
+	for _, want := range []string{
+		`echo_http_requests_total{method="PUT",route="/api/v1/numbers",status="201"} 1`,
+		`echo_http_request_duration_seconds_count{method="PUT",route="/api/v1/numbers",status="201"} 1`,
+		`echo_list_size{list=""} 1`,
+		`echo_list_lock_wait_seconds_count`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("/metrics is missing %s", want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	echov1 "github.com/alipourhabibi/exercises-journal/echo/api/echo/v1"
+	"github.com/alipourhabibi/exercises-journal/echo/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+func TestDevTLS(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	port, grpcPort := freePort(t), freePort(t)
+	writeGRPCConfig(t, path, port, grpcPort)
+	devTLS = true
+	defer func() { devTLS = false }()
+
+	configFile = path
+	err := config.Load(configFile)
+	if err != nil {
+		t.Fatalf("Can't load config: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx)
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("serve returned: %v", err)
+		}
+	}()
+
+	insecure := &tls.Config{InsecureSkipVerify: true}
+	c := &http.Client{Transport: &http.Transport{TLSClientConfig: insecure}, Timeout: time.Second}
+	url := fmt.Sprintf("https://127.0.0.1:%d/healthz", port)
+	var res *http.Response
+	waitFor(t, func() bool {
+		res, err = c.Get(url)
+		return err == nil
+	})
+	res.Body.Close()
+	if res.TLS == nil || res.TLS.PeerCertificates[0].Issuer.Organization[0] != "echo development" {
+		t.Errorf("Served %+v, want the self-signed certificate", res.TLS)
+	}
+	plain, err := client.Get(fmt.Sprintf("http://127.0.0.1:%d/healthz", port))
+	if err == nil {
+		plain.Body.Close()
+		if plain.StatusCode != http.StatusBadRequest {
+			t.Errorf("Plain http got %d, want it refused", plain.StatusCode)
+		}
+	}
+
+	conn, err := grpc.NewClient(fmt.Sprintf("127.0.0.1:%d", grpcPort), grpc.WithTransportCredentials(credentials.NewTLS(insecure)))
+	if err != nil {
+		t.Fatalf("Can't dial gRPC: %v", err)
+	}
+	defer conn.Close()
+	_, err = echov1.NewListServiceClient(conn).Insert(context.Background(), &echov1.InsertRequest{Index: 0, Value: 1})
+	if err != nil {
+		t.Errorf("gRPC over TLS failed: %v", err)
+	}
+}
+
+// TestDevTLSShutdownWithStream checks an open event stream doesn't hold
+// up the shutdown of a TLS server.
+func TestDevTLSShutdownWithStream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	port := freePort(t)
+	writeGRPCConfig(t, path, port, freePort(t))
+	devTLS = true
+	defer func() { devTLS = false }()
+
+	configFile = path
+	err := config.Load(configFile)
+	if err != nil {
+		t.Fatalf("Can't load config: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx)
+	}()
+
+	c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	url := fmt.Sprintf("https://127.0.0.1:%d/api/v1/numbers/events", port)
+	var res *http.Response
+	waitFor(t, func() bool {
+		res, err = c.Get(url)
+		return err == nil
+	})
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET events = %d", res.StatusCode)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("serve returned: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("serve didn't return with a stream open")
+	}
+}
@@ -1,8 +1,13 @@
 package config
 
 import (
+	"fmt"
 	"log/slog"
+	"net"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -14,15 +19,37 @@ var MapLevel = map[string]slog.Level{
 	"ERROR": slog.LevelError,
 }
 
-var Confs config
+var Confs Config
 
-type config struct {
-	Server server `yaml:"server"`
-	Logger logger `yaml:"logger"`
+type Config struct {
+	Server      server      `yaml:"server"`
+	GRPC        grpc        `yaml:"grpc"`
+	Logger      logger      `yaml:"logger"`
+	Lists       lists       `yaml:"lists"`
+	Idempotency idempotency `yaml:"idempotency"`
+	Events      events      `yaml:"events"`
+	Auth        auth        `yaml:"auth"`
+	RateLimit   rateLimit   `yaml:"rate_limit"`
+	Replication replication `yaml:"replication"`
+	Cluster     cluster     `yaml:"cluster"`
+	History     history     `yaml:"history"`
+	Audit       audit       `yaml:"audit"`
+	Webhooks    webhooks    `yaml:"webhooks"`
+	Tracing     tracing     `yaml:"tracing"`
+	TLS         tlsConfig   `yaml:"tls"`
 }
 
 type server struct {
 	Port uint `yaml:"port"`
+	// TrustedProxies are the addresses and CIDR ranges of proxies whose
+	// X-Forwarded-For and X-Real-IP headers name the client; requests
+	// from anywhere else are put down to the address they came from
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+type grpc struct {
+	// Port serves the gRPC API; zero turns it off
+	Port uint `yaml:"port"`
 }
 
 type logger struct {
@@ -30,11 +57,318 @@ type logger struct {
 	Level     string `yaml:"level"`
 }
 
-func Load(path string) error {
+type lists struct {
+	// MaxSize caps every list that isn't given its own limit; zero means unlimited
+	MaxSize uint `yaml:"max_size"`
+	// Backend is what lists keep their elements in: linkedlist, slice or
+	// tree
+	Backend string `yaml:"backend"`
+}
+
+type idempotency struct {
+	// TTL is how long a key and its response are kept; zero turns keys off
+	TTL time.Duration `yaml:"ttl"`
+}
+
+type events struct {
+	// Buffer is how many changes per list are kept for clients to resume
+	// from; zero turns the change feed off
+	Buffer int `yaml:"buffer"`
+}
+
+type auth struct {
+	// Enabled turns authentication on; when off every request is allowed
+	Enabled bool     `yaml:"enabled"`
+	APIKeys []apiKey `yaml:"api_keys"`
+	JWT     jwt      `yaml:"jwt"`
+}
+
+type apiKey struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+	Role string `yaml:"role"`
+}
+
+type jwt struct {
+	// Secret verifies HMAC-signed tokens; empty means JWTs aren't accepted
+	Secret string `yaml:"secret"`
+	Issuer string `yaml:"issuer"`
+}
+
+type rateLimit struct {
+	Enabled bool `yaml:"enabled"`
+	// Key is "ip" or "api_key"; api_key falls back to the ip for requests
+	// without credentials
+	Key   string `yaml:"key"`
+	Read  bucket `yaml:"read"`
+	Write bucket `yaml:"write"`
+}
+
+type bucket struct {
+	// Rate is how many requests per second refill the bucket
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+type replication struct {
+	// Role is standalone, leader or follower
+	Role string `yaml:"role"`
+	// LogSize is how many changes a leader keeps for followers to resume
+	// from
+	LogSize int `yaml:"log_size"`
+	// Leader is the http address of the leader a follower copies
+	Leader string `yaml:"leader"`
+	// Token authenticates a follower to a leader with auth enabled
+	Token string `yaml:"token"`
+	// ForwardWrites makes a follower send writes on to the leader instead
+	// of refusing them
+	ForwardWrites bool `yaml:"forward_writes"`
+}
+
+type cluster struct {
+	// Enabled sends every change through a raft log shared with the other
+	// members before it is made
+	Enabled bool `yaml:"enabled"`
+	// NodeID names this member; it must be unique and stay the same across
+	// restarts
+	NodeID string `yaml:"node_id"`
+	// Address is the host:port raft listens on, which the other members
+	// also dial, so it can't be a wildcard address
+	Address string `yaml:"address"`
+	// Dir keeps the raft log and snapshots
+	Dir string `yaml:"dir"`
+	// Bootstrap starts a new cluster with this member alone unless Dir
+	// already holds one; the others are added through the admin API
+	Bootstrap bool `yaml:"bootstrap"`
+}
+
+type history struct {
+	// Retention is how long changes are kept for reading lists as they
+	// were; zero turns history off
+	Retention time.Duration `yaml:"retention"`
+	// CompactInterval is how often changes older than the retention are
+	// dropped
+	CompactInterval time.Duration `yaml:"compact_interval"`
+}
+
+type audit struct {
+	// Enabled logs who made every change through the http and gRPC APIs
+	Enabled bool `yaml:"enabled"`
+	// Path is the file records are appended to; rotated files get .1, .2
+	// and so on after it
+	Path string `yaml:"path"`
+	// MaxSizeMB is how large the file grows before it is rotated
+	MaxSizeMB int `yaml:"max_size_mb"`
+	// MaxFiles is how many rotated files are kept
+	MaxFiles int `yaml:"max_files"`
+}
+
+type webhooks struct {
+	// Enabled lets clients subscribe to the changes through the http API
+	Enabled bool `yaml:"enabled"`
+	// MaxAttempts is how often a delivery is tried before it is kept as a
+	// dead letter
+	MaxAttempts int `yaml:"max_attempts"`
+	// Backoff is the wait after the first failed attempt; it doubles after
+	// each one after that, up to MaxBackoff
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+	// Timeout is how long a receiver has to answer an attempt
+	Timeout time.Duration `yaml:"timeout"`
+	// QueueSize is how many deliveries per webhook may wait to be sent
+	QueueSize int `yaml:"queue_size"`
+	// DeadLetters is how many failed deliveries are kept
+	DeadLetters int `yaml:"dead_letters"`
+	// AllowLocalTargets lets webhooks be sent to loopback and link-local
+	// addresses
+	AllowLocalTargets bool `yaml:"allow_local_targets"`
+}
+
+type tracing struct {
+	// Enabled records spans for requests and list operations
+	Enabled bool `yaml:"enabled"`
+	// Exporter is where spans go: stdout or otlp
+	Exporter string `yaml:"exporter"`
+	// ServiceName names this process in traces
+	ServiceName string `yaml:"service_name"`
+	// SampleRatio is the share of new traces recorded; traces continued
+	// from a caller follow the caller's choice
+	SampleRatio float64 `yaml:"sample_ratio"`
+	OTLP        otlp    `yaml:"otlp"`
+}
+
+type otlp struct {
+	// Endpoint is the host:port of the collector
+	Endpoint string `yaml:"endpoint"`
+	// Protocol is grpc or http
+	Protocol string `yaml:"protocol"`
+	// Insecure talks to the collector without TLS
+	Insecure bool `yaml:"insecure"`
+}
+
+type tlsConfig struct {
+	// Enabled serves http and gRPC over TLS
+	Enabled  bool   `yaml:"enabled"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// MinVersion is the oldest TLS version accepted, like 1.2
+	MinVersion string `yaml:"min_version"`
+	// ClientCAFile, when set, requires clients to present a certificate
+	// signed by one of the CAs in this PEM bundle
+	ClientCAFile string `yaml:"client_ca_file"`
+}
+
+// Validate reports the first invalid setting in c.
+func (c Config) Validate() error {
+	if c.Server.Port == 0 || c.Server.Port > 65535 {
+		return fmt.Errorf("server.port: %d is not a valid port", c.Server.Port)
+	}
+	if c.GRPC.Port > 65535 || c.GRPC.Port == c.Server.Port {
+		return fmt.Errorf("grpc.port: %d is not a valid port", c.GRPC.Port)
+	}
+	if _, ok := MapLevel[strings.ToUpper(c.Logger.Level)]; !ok && c.Logger.Level != "" {
+		return fmt.Errorf("logger.level: unknown level %q", c.Logger.Level)
+	}
+	switch c.Lists.Backend {
+	case "", "linkedlist", "slice", "tree":
+	default:
+		return fmt.Errorf("lists.backend: unknown backend %q", c.Lists.Backend)
+	}
+	if c.Events.Buffer < 0 {
+		return fmt.Errorf("events.buffer: %d is negative", c.Events.Buffer)
+	}
+	for i, k := range c.Auth.APIKeys {
+		if k.Key == "" {
+			return fmt.Errorf("auth.api_keys[%d]: key is empty", i)
+		}
+		if k.Role != "reader" && k.Role != "writer" && k.Role != "admin" {
+			return fmt.Errorf("auth.api_keys[%d]: unknown role %q", i, k.Role)
+		}
+	}
+	if c.Auth.Enabled && len(c.Auth.APIKeys) == 0 && c.Auth.JWT.Secret == "" {
+		return fmt.Errorf("auth: enabled without api keys or a jwt secret")
+	}
+	for i, p := range c.Server.TrustedProxies {
+		_, _, err := net.ParseCIDR(p)
+		if err != nil && net.ParseIP(p) == nil {
+			return fmt.Errorf("server.trusted_proxies[%d]: %q is not an ip address or CIDR range", i, p)
+		}
+	}
+	if c.RateLimit.Key != "" && c.RateLimit.Key != "ip" && c.RateLimit.Key != "api_key" {
+		return fmt.Errorf("rate_limit.key: unknown key %q", c.RateLimit.Key)
+	}
+	for name, b := range map[string]bucket{"read": c.RateLimit.Read, "write": c.RateLimit.Write} {
+		if c.RateLimit.Enabled && (b.Rate <= 0 || b.Burst < 1) {
+			return fmt.Errorf("rate_limit.%s: rate and burst must be positive", name)
+		}
+	}
+	switch c.Replication.Role {
+	case "", "standalone":
+	case "leader":
+		if c.Replication.LogSize < 1 {
+			return fmt.Errorf("replication.log_size: a leader needs a positive log size")
+		}
+	case "follower":
+		u, err := url.Parse(c.Replication.Leader)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("replication.leader: %q is not an http url", c.Replication.Leader)
+		}
+	default:
+		return fmt.Errorf("replication.role: unknown role %q", c.Replication.Role)
+	}
+	if c.Cluster.Enabled {
+		if c.Cluster.NodeID == "" {
+			return fmt.Errorf("cluster.node_id: a member needs an id")
+		}
+		host, _, err := net.SplitHostPort(c.Cluster.Address)
+		if err != nil || host == "" || net.ParseIP(host).IsUnspecified() {
+			return fmt.Errorf("cluster.address: %q is not a host:port others can reach", c.Cluster.Address)
+		}
+		if c.Cluster.Dir == "" {
+			return fmt.Errorf("cluster.dir: a member needs a directory")
+		}
+		if c.Replication.Role != "" && c.Replication.Role != "standalone" {
+			return fmt.Errorf("cluster: can't be enabled with replication.role %s", c.Replication.Role)
+		}
+	}
+	if c.History.Retention < 0 {
+		return fmt.Errorf("history.retention: %s is negative", c.History.Retention)
+	}
+	if c.History.Retention > 0 && c.History.CompactInterval <= 0 {
+		return fmt.Errorf("history.compact_interval: must be positive while history is on")
+	}
+	if c.Audit.Enabled {
+		if c.Audit.Path == "" {
+			return fmt.Errorf("audit.path: the audit log needs a file")
+		}
+		if c.Audit.MaxSizeMB < 1 {
+			return fmt.Errorf("audit.max_size_mb: %d isn't positive", c.Audit.MaxSizeMB)
+		}
+		if c.Audit.MaxFiles < 0 {
+			return fmt.Errorf("audit.max_files: %d is negative", c.Audit.MaxFiles)
+		}
+	}
+	if w := c.Webhooks; w.Enabled {
+		if w.MaxAttempts < 1 {
+			return fmt.Errorf("webhooks.max_attempts: %d isn't positive", w.MaxAttempts)
+		}
+		if w.Backoff <= 0 || w.MaxBackoff < w.Backoff {
+			return fmt.Errorf("webhooks.backoff: must be positive and at most max_backoff")
+		}
+		if w.Timeout <= 0 {
+			return fmt.Errorf("webhooks.timeout: %s isn't positive", w.Timeout)
+		}
+		if w.QueueSize < 1 {
+			return fmt.Errorf("webhooks.queue_size: %d isn't positive", w.QueueSize)
+		}
+		if w.DeadLetters < 0 {
+			return fmt.Errorf("webhooks.dead_letters: %d is negative", w.DeadLetters)
+		}
+	}
+	if t := c.Tracing; t.Enabled {
+		if t.Exporter != "stdout" && t.Exporter != "otlp" {
+			return fmt.Errorf("tracing.exporter: unknown exporter %q", t.Exporter)
+		}
+		if t.Exporter == "otlp" && t.OTLP.Protocol != "grpc" && t.OTLP.Protocol != "http" {
+			return fmt.Errorf("tracing.otlp.protocol: unknown protocol %q", t.OTLP.Protocol)
+		}
+		if t.SampleRatio < 0 || t.SampleRatio > 1 {
+			return fmt.Errorf("tracing.sample_ratio: %g isn't between 0 and 1", t.SampleRatio)
+		}
+	}
+	switch c.TLS.MinVersion {
+	case "", "1.0", "1.1", "1.2", "1.3":
+	default:
+		return fmt.Errorf("tls.min_version: unknown version %q", c.TLS.MinVersion)
+	}
+	if c.TLS.Enabled && (c.TLS.CertFile == "" || c.TLS.KeyFile == "") {
+		return fmt.Errorf("tls: enabled without a cert_file and key_file")
+	}
+	if c.Idempotency.TTL < 0 {
+		return fmt.Errorf("idempotency.ttl: %s is negative", c.Idempotency.TTL)
+	}
+	return nil
+}
+
+// Read parses and validates the config at path without applying it.
+func Read(path string) (Config, error) {
+	c := Config{}
 	f, err := os.ReadFile(path)
 	if err != nil {
-		return err
+		return c, err
+	}
+	err = yaml.Unmarshal(f, &c)
+	if err != nil {
+		return c, err
 	}
+	return c, c.Validate()
+}
 
-	return yaml.Unmarshal(f, &Confs)
+func Load(path string) error {
+	c, err := Read(path)
+	if err != nil {
+		return err
+	}
+	Confs = c
+	return nil
 }
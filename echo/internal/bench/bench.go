@@ -0,0 +1,501 @@
+// Package bench drives a server with a mix of list operations and measures
+// how it answers.
+package bench
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/client"
+)
+
+const (
+	OpInsert = "insert"
+	OpRemove = "remove"
+	OpGet    = "get"
+	OpFind   = "find"
+)
+
+// Ops are the operations a mix can hold, in the order reports list them.
+var Ops = []string{OpInsert, OpRemove, OpGet, OpFind}
+
+// latencies are recorded in microseconds, up to a minute
+const (
+	minLatency = 1
+	maxLatency = int64(time.Minute / time.Microsecond)
+	sigFigs    = 3
+)
+
+// Mix is how often each operation is sent relative to the others.
+type Mix map[string]int
+
+// ParseMix reads a mix like "insert=1,remove=1,get=4,find=4". Operations
+// left out aren't sent.
+func ParseMix(s string) (Mix, error) {
+	mix := Mix{}
+	for _, part := range strings.Split(s, ",") {
+		op, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("bench: %q is not op=weight", part)
+		}
+		n, err := strconv.Atoi(weight)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("bench: weight of %s must be a non-negative integer", op)
+		}
+		if !isOp(op) {
+			return nil, fmt.Errorf("bench: unknown operation %q", op)
+		}
+		mix[op] = n
+	}
+	return mix, mix.validate()
+}
+
+func isOp(op string) bool {
+	for _, o := range Ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (m Mix) validate() error {
+	total := 0
+	for op, weight := range m {
+		if !isOp(op) {
+			return fmt.Errorf("bench: unknown operation %q", op)
+		}
+		total += weight
+	}
+	if total == 0 {
+		return errors.New("bench: the mix sends nothing")
+	}
+	return nil
+}
+
+// pick returns an operation with probability proportional to its weight.
+func (m Mix) pick() string {
+	total := 0
+	for _, op := range Ops {
+		total += m[op]
+	}
+	n := rand.IntN(total)
+	for _, op := range Ops {
+		if n < m[op] {
+			return op
+		}
+		n -= m[op]
+	}
+	return Ops[len(Ops)-1]
+}
+
+// Latency summarizes a histogram in milliseconds.
+type Latency struct {
+	Min  float64 `json:"min_ms"`
+	Mean float64 `json:"mean_ms"`
+	P50  float64 `json:"p50_ms"`
+	P90  float64 `json:"p90_ms"`
+	P99  float64 `json:"p99_ms"`
+	P999 float64 `json:"p999_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+func latencyOf(h *hdrhistogram.Histogram) Latency {
+	if h.TotalCount() == 0 {
+		return Latency{}
+	}
+	ms := func(us int64) float64 {
+		return float64(us) / 1000
+	}
+	return Latency{
+		Min:  ms(h.Min()),
+		Mean: h.Mean() / 1000,
+		P50:  ms(h.ValueAtQuantile(50)),
+		P90:  ms(h.ValueAtQuantile(90)),
+		P99:  ms(h.ValueAtQuantile(99)),
+		P999: ms(h.ValueAtQuantile(99.9)),
+		Max:  ms(h.Max()),
+	}
+}
+
+// OpReport is how one operation fared. Latency covers failed requests too.
+type OpReport struct {
+	Requests   int64   `json:"requests"`
+	Errors     int64   `json:"errors"`
+	Throughput float64 `json:"throughput"`
+	Latency    Latency `json:"latency"`
+}
+
+// Report is the outcome of a run. Throughput is in requests per second.
+type Report struct {
+	Duration    float64             `json:"duration_seconds"`
+	Concurrency int                 `json:"concurrency"`
+	Rate        float64             `json:"rate"`
+	Mix         Mix                 `json:"mix"`
+	Requests    int64               `json:"requests"`
+	Errors      int64               `json:"errors"`
+	Throughput  float64             `json:"throughput"`
+	Latency     Latency             `json:"latency"`
+	Ops         map[string]OpReport `json:"ops"`
+	// ErrorCodes counts failures by the code of the error response, or
+	// "transport" when there was none
+	ErrorCodes map[string]int64 `json:"error_codes"`
+}
+
+type Runner struct {
+	client      *client.Client
+	list        string
+	mix         Mix
+	concurrency int
+	rate        float64
+	duration    time.Duration
+	prefill     int
+	values      int
+
+	// size tracks how many elements the list holds, so indexes mostly
+	// land inside it
+	size atomic.Int64
+}
+
+type RunnerConfiguration func(*Runner) error
+
+func New(cfgs ...RunnerConfiguration) (*Runner, error) {
+	r := &Runner{
+		mix:         Mix{OpInsert: 1, OpRemove: 1, OpGet: 4, OpFind: 4},
+		concurrency: 8,
+		duration:    10 * time.Second,
+		values:      1000,
+	}
+
+	for _, cfg := range cfgs {
+		err := cfg(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.client == nil {
+		return nil, errors.New("bench: a client is required")
+	}
+
+	return r, nil
+}
+
+func WithClient(c *client.Client) RunnerConfiguration {
+	return func(r *Runner) error {
+		r.client = c
+		return nil
+	}
+}
+
+// WithList works on the named list instead of the global one.
+func WithList(name string) RunnerConfiguration {
+	return func(r *Runner) error {
+		r.list = name
+		return nil
+	}
+}
+
+func WithMix(m Mix) RunnerConfiguration {
+	return func(r *Runner) error {
+		err := m.validate()
+		if err != nil {
+			return err
+		}
+		r.mix = m
+		return nil
+	}
+}
+
+// WithConcurrency sends from n workers, each waiting for its answer before
+// the next request.
+func WithConcurrency(n int) RunnerConfiguration {
+	return func(r *Runner) error {
+		if n < 1 {
+			return errors.New("bench: concurrency must be positive")
+		}
+		r.concurrency = n
+		return nil
+	}
+}
+
+// WithRate caps the requests per second across all workers; zero sends as
+// fast as the server answers.
+func WithRate(rate float64) RunnerConfiguration {
+	return func(r *Runner) error {
+		if rate < 0 {
+			return errors.New("bench: rate can't be negative")
+		}
+		r.rate = rate
+		return nil
+	}
+}
+
+func WithDuration(d time.Duration) RunnerConfiguration {
+	return func(r *Runner) error {
+		if d <= 0 {
+			return errors.New("bench: duration must be positive")
+		}
+		r.duration = d
+		return nil
+	}
+}
+
+// WithPrefill appends n values before the run, so removes and gets have
+// something to work on.
+func WithPrefill(n int) RunnerConfiguration {
+	return func(r *Runner) error {
+		if n < 0 {
+			return errors.New("bench: prefill can't be negative")
+		}
+		r.prefill = n
+		return nil
+	}
+}
+
+// WithValues draws inserted and searched values from 1 to n.
+func WithValues(n int) RunnerConfiguration {
+	return func(r *Runner) error {
+		if n < 1 {
+			return errors.New("bench: values must be positive")
+		}
+		r.values = n
+		return nil
+	}
+}
+
+// worker holds what one worker measured, so workers never share a
+// histogram.
+type worker struct {
+	hists  map[string]*hdrhistogram.Histogram
+	errors map[string]int64
+	codes  map[string]int64
+}
+
+func newWorker() *worker {
+	w := &worker{
+		hists:  map[string]*hdrhistogram.Histogram{},
+		errors: map[string]int64{},
+		codes:  map[string]int64{},
+	}
+	for _, op := range Ops {
+		w.hists[op] = hdrhistogram.New(minLatency, maxLatency, sigFigs)
+	}
+	return w
+}
+
+// Run prefills the list, then sends the mix until the duration is up or
+// ctx is done.
+func (r *Runner) Run(ctx context.Context) (*Report, error) {
+	size, err := r.client.Count(ctx, r.list)
+	if err != nil {
+		return nil, err
+	}
+	if r.prefill > 0 {
+		var body bytes.Buffer
+		body.WriteString("[")
+		for i := 0; i < r.prefill; i++ {
+			if i > 0 {
+				body.WriteString(",")
+			}
+			body.WriteString(strconv.Itoa(r.value()))
+		}
+		body.WriteString("]")
+		_, err := r.client.Import(ctx, r.list, "json", "append", &body)
+		if err != nil {
+			return nil, err
+		}
+		size += uint(r.prefill)
+	}
+	r.size.Store(int64(size))
+
+	ctx, cancel := context.WithTimeout(ctx, r.duration)
+	defer cancel()
+	ticks := r.pace(ctx)
+
+	workers := make([]*worker, r.concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range workers {
+		w := newWorker()
+		workers[i] = w
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx, w, ticks)
+		}()
+	}
+	wg.Wait()
+	return r.report(workers, time.Since(start)), nil
+}
+
+// pace hands out the time each request is due at rate, or nil when the
+// rate is unlimited.
+func (r *Runner) pace(ctx context.Context) <-chan time.Time {
+	if r.rate == 0 {
+		return nil
+	}
+	ticks := make(chan time.Time)
+	interval := time.Duration(float64(time.Second) / r.rate)
+	go func() {
+		due := time.Now()
+		for {
+			select {
+			case ticks <- due:
+			case <-ctx.Done():
+				return
+			}
+			due = due.Add(interval)
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ticks
+}
+
+func (r *Runner) work(ctx context.Context, w *worker, ticks <-chan time.Time) {
+	for {
+		// with a rate, latency counts from when the request was due, so a
+		// slow server isn't hidden by requests queueing behind it
+		start := time.Now()
+		if ticks != nil {
+			select {
+			case start = <-ticks:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		op := r.mix.pick()
+		err := r.send(ctx, op)
+		if err != nil && ctx.Err() != nil {
+			// cut short by the end of the run
+			return
+		}
+		us := time.Since(start).Microseconds()
+		w.hists[op].RecordValue(min(max(us, minLatency), maxLatency))
+		if err != nil {
+			w.errors[op]++
+			var apiErr *client.Error
+			code := "transport"
+			if errors.As(err, &apiErr) {
+				code = apiErr.Code
+			}
+			w.codes[code]++
+		}
+	}
+}
+
+func (r *Runner) value() int {
+	return 1 + rand.IntN(r.values)
+}
+
+// index returns an index within the list, or right after it when past is
+// set.
+func (r *Runner) index(past bool) uint {
+	size := r.size.Load()
+	if past {
+		size++
+	}
+	if size <= 0 {
+		return 0
+	}
+	return uint(rand.Int64N(size))
+}
+
+func (r *Runner) send(ctx context.Context, op string) error {
+	switch op {
+	case OpInsert:
+		_, err := r.client.Insert(ctx, r.list, r.index(true), r.value(), 0)
+		if err == nil {
+			r.size.Add(1)
+		}
+		return err
+	case OpRemove:
+		_, err := r.client.Remove(ctx, r.list, r.index(false), 0)
+		if err == nil {
+			r.size.Add(-1)
+		}
+		return err
+	case OpGet:
+		_, _, err := r.client.Get(ctx, r.list, r.index(false))
+		return err
+	case OpFind:
+		_, _, err := r.client.Find(ctx, r.list, r.value())
+		return err
+	}
+	return fmt.Errorf("bench: unknown operation %q", op)
+}
+
+func (r *Runner) report(workers []*worker, elapsed time.Duration) *Report {
+	seconds := elapsed.Seconds()
+	rep := &Report{
+		Duration:    seconds,
+		Concurrency: r.concurrency,
+		Rate:        r.rate,
+		Mix:         r.mix,
+		Ops:         map[string]OpReport{},
+		ErrorCodes:  map[string]int64{},
+	}
+	all := hdrhistogram.New(minLatency, maxLatency, sigFigs)
+	for _, op := range Ops {
+		if r.mix[op] == 0 {
+			continue
+		}
+		h := hdrhistogram.New(minLatency, maxLatency, sigFigs)
+		var errs int64
+		for _, w := range workers {
+			h.Merge(w.hists[op])
+			errs += w.errors[op]
+		}
+		all.Merge(h)
+		rep.Ops[op] = OpReport{
+			Requests:   h.TotalCount(),
+			Errors:     errs,
+			Throughput: float64(h.TotalCount()) / seconds,
+			Latency:    latencyOf(h),
+		}
+		rep.Errors += errs
+	}
+	for _, w := range workers {
+		for code, n := range w.codes {
+			rep.ErrorCodes[code] += n
+		}
+	}
+	rep.Requests = all.TotalCount()
+	rep.Throughput = float64(rep.Requests) / seconds
+	rep.Latency = latencyOf(all)
+	return rep
+}
+
+// Codes returns the error codes of rep, the most frequent first.
+func (rep *Report) Codes() []string {
+	codes := make([]string, 0, len(rep.ErrorCodes))
+	for code := range rep.ErrorCodes {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if rep.ErrorCodes[codes[i]] != rep.ErrorCodes[codes[j]] {
+			return rep.ErrorCodes[codes[i]] > rep.ErrorCodes[codes[j]]
+		}
+		return codes[i] < codes[j]
+	})
+	return codes
+}
@@ -0,0 +1,45 @@
+package bench
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMix(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Mix
+		ok   bool
+	}{
+		{"insert=1,remove=1,get=4,find=4", Mix{OpInsert: 1, OpRemove: 1, OpGet: 4, OpFind: 4}, true},
+		{" get=1 , find=0", Mix{OpGet: 1, OpFind: 0}, true},
+		{"get", nil, false},
+		{"get=-1", nil, false},
+		{"set=1", nil, false},
+		{"get=0", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseMix(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseMix(%q) returned %v", tt.in, err)
+			continue
+		}
+		if tt.ok && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseMix(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPick(t *testing.T) {
+	mix := Mix{OpGet: 3, OpFind: 1}
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		counts[mix.pick()]++
+	}
+	if counts[OpInsert] != 0 || counts[OpRemove] != 0 {
+		t.Fatalf("Picked operations outside the mix: %v", counts)
+	}
+	if counts[OpGet] < 2700 || counts[OpGet] > 3300 {
+		t.Errorf("Picked get %d times out of 4000, want about 3000", counts[OpGet])
+	}
+}
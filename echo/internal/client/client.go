@@ -0,0 +1,245 @@
+// Package client talks to the http API of a running echo server.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Entity is an element of a list.
+type Entity struct {
+	Index uint `json:"index"`
+	Value int  `json:"value"`
+}
+
+// ListInfo describes a named list.
+type ListInfo struct {
+	Name    string `json:"name"`
+	Size    uint   `json:"size"`
+	MaxSize uint   `json:"max_size"`
+}
+
+// ImportResult is what an import did.
+type ImportResult struct {
+	Imported int    `json:"imported"`
+	Version  uint64 `json:"version"`
+}
+
+// Error is an error response of the server.
+type Error struct {
+	Status    int             `json:"-"`
+	Code      string          `json:"code"`
+	Message   string          `json:"message"`
+	Details   json.RawMessage `json:"details,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%d %s)", e.Message, e.Status, e.Code)
+}
+
+type Client struct {
+	server *url.URL
+	token  string
+	http   *http.Client
+}
+
+type ClientConfiguration func(*Client) error
+
+func New(cfgs ...ClientConfiguration) (*Client, error) {
+	c := &Client{
+		http: &http.Client{},
+	}
+
+	for _, cfg := range cfgs {
+		err := cfg(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.server == nil {
+		return nil, errors.New("client: a server is required")
+	}
+
+	return c, nil
+}
+
+// WithServer sends requests to the server at rawURL, like
+// http://127.0.0.1:8082.
+func WithServer(rawURL string) ClientConfiguration {
+	return func(c *Client) error {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return err
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("client: %q is not an http url", rawURL)
+		}
+		c.server = u
+		return nil
+	}
+}
+
+// WithToken authenticates with an api key or a JWT.
+func WithToken(token string) ClientConfiguration {
+	return func(c *Client) error {
+		c.token = token
+		return nil
+	}
+}
+
+// WithHTTPClient sends requests through h.
+func WithHTTPClient(h *http.Client) ClientConfiguration {
+	return func(c *Client) error {
+		c.http = h
+		return nil
+	}
+}
+
+// numbers is the path of the number routes of the named list, or of the
+// global one when name is empty.
+func numbers(name string) string {
+	if name == "" {
+		return "/api/v1/numbers"
+	}
+	return "/api/v1/lists/" + url.PathEscape(name) + "/numbers"
+}
+
+// Version is the list version an ETag names; zero if it names none.
+func Version(etag string) uint64 {
+	version, _ := strconv.ParseUint(strings.Trim(etag, `"`), 10, 64)
+	return version
+}
+
+// do sends a request and decodes a 2xx JSON answer into out, if it isn't
+// nil. Any other answer is returned as an *Error.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, header http.Header, body io.Reader, out any) (*http.Response, error) {
+	u := c.server.JoinPath(path)
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		e := &Error{Status: res.StatusCode}
+		err := json.NewDecoder(res.Body).Decode(e)
+		if err != nil || e.Code == "" {
+			e.Code = strings.ReplaceAll(strings.ToLower(http.StatusText(res.StatusCode)), " ", "_")
+			e.Message = http.StatusText(res.StatusCode)
+		}
+		return res, e
+	}
+	if out == nil {
+		return res, nil
+	}
+	defer res.Body.Close()
+	return res, json.NewDecoder(res.Body).Decode(out)
+}
+
+// matchHeader asks for the change to be made only at version, unless it is
+// zero.
+func matchHeader(version uint64) http.Header {
+	h := http.Header{"Content-Type": {"application/json"}}
+	if version != 0 {
+		h.Set("If-Match", strconv.Quote(strconv.FormatUint(version, 10)))
+	}
+	return h
+}
+
+// Insert puts value at index of the list and returns the version after it.
+// A non-zero ifVersion makes the insert fail unless the list is at it.
+func (c *Client) Insert(ctx context.Context, list string, index uint, value int, ifVersion uint64) (uint64, error) {
+	body, _ := json.Marshal(Entity{Index: index, Value: value})
+	res, err := c.do(ctx, http.MethodPut, numbers(list), nil, matchHeader(ifVersion), bytes.NewReader(body), nil)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+	return Version(res.Header.Get("ETag")), nil
+}
+
+// Remove deletes the element at index and returns the version after it.
+func (c *Client) Remove(ctx context.Context, list string, index uint, ifVersion uint64) (uint64, error) {
+	res, err := c.do(ctx, http.MethodDelete, numbers(list)+"/"+strconv.FormatUint(uint64(index), 10), nil, matchHeader(ifVersion), nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+	return Version(res.Header.Get("ETag")), nil
+}
+
+// Get returns the element at index and the version it was read at.
+func (c *Client) Get(ctx context.Context, list string, index uint) (Entity, uint64, error) {
+	var e Entity
+	res, err := c.do(ctx, http.MethodGet, numbers(list)+"/index/"+strconv.FormatUint(uint64(index), 10), nil, nil, nil, &e)
+	if err != nil {
+		return Entity{}, 0, err
+	}
+	return e, Version(res.Header.Get("ETag")), nil
+}
+
+// Find returns the first element holding value and the version it was
+// found at.
+func (c *Client) Find(ctx context.Context, list string, value int) (Entity, uint64, error) {
+	var e Entity
+	res, err := c.do(ctx, http.MethodGet, numbers(list)+"/value/"+strconv.Itoa(value), nil, nil, nil, &e)
+	if err != nil {
+		return Entity{}, 0, err
+	}
+	return e, Version(res.Header.Get("ETag")), nil
+}
+
+// Lists returns the named lists.
+func (c *Client) Lists(ctx context.Context) ([]ListInfo, error) {
+	var lists []ListInfo
+	_, err := c.do(ctx, http.MethodGet, "/api/v1/lists", nil, nil, nil, &lists)
+	return lists, err
+}
+
+// Export copies the list to w in format: json, csv or ndjson.
+func (c *Client) Export(ctx context.Context, list, format string, w io.Writer) error {
+	res, err := c.do(ctx, http.MethodGet, numbers(list)+"/export", url.Values{"format": {format}}, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, err = io.Copy(w, res.Body)
+	return err
+}
+
+// Import sends the rows read from r in format to the list, appending them
+// or replacing its elements with them as mode says.
+func (c *Client) Import(ctx context.Context, list, format, mode string, r io.Reader) (ImportResult, error) {
+	var result ImportResult
+	query := url.Values{"format": {format}, "mode": {mode}}
+	_, err := c.do(ctx, http.MethodPost, numbers(list)+"/import", query, nil, r, &result)
+	return result, err
+}
+
+// Count returns how many elements the list holds.
+func (c *Client) Count(ctx context.Context, list string) (uint, error) {
+	var count struct {
+		Count uint `json:"count"`
+	}
+	_, err := c.do(ctx, http.MethodGet, numbers(list)+"/search", url.Values{"count_only": {"true"}}, nil, nil, &count)
+	return count.Count, err
+}
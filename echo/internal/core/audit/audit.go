@@ -0,0 +1,343 @@
+// Package audit keeps an append-only log of who changed which list, as
+// rotating files of JSON lines.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+)
+
+// Record is one change to a list and who made it. Old is set for removed
+// and updated elements, New for inserted and updated ones.
+type Record struct {
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"time"`
+	Actor     string    `json:"actor"`
+	RequestID string    `json:"request_id,omitempty"`
+	List      string    `json:"list,omitempty"`
+	Op        string    `json:"op"`
+	Index     uint      `json:"index"`
+	Old       *int      `json:"old,omitempty"`
+	New       *int      `json:"new,omitempty"`
+	Version   uint64    `json:"version"`
+}
+
+// Request is who made a change and through which request.
+type Request struct {
+	Actor     string
+	RequestID string
+	// List is the name of the list; empty for the global one
+	List string
+}
+
+// Log writes records to the file at path, moving it to path.1, path.2 and
+// so on once it outgrows maxSize and keeping maxFiles of those.
+type Log struct {
+	// the embedded lock guards the files
+	sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+	seq      uint64
+	now      func() time.Time
+}
+
+type LogConfiguration func(*Log) error
+
+func New(cfgs ...LogConfiguration) (*Log, error) {
+	l := &Log{
+		maxSize:  10 << 20,
+		maxFiles: 5,
+		now:      time.Now,
+	}
+
+	for _, cfg := range cfgs {
+		err := cfg(l)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if l.path == "" {
+		return nil, errors.New("audit: a path is required")
+	}
+	err := os.MkdirAll(filepath.Dir(l.path), 0o755)
+	if err != nil {
+		return nil, err
+	}
+	// carry on numbering where the last run stopped
+	for _, path := range l.files() {
+		last, err := lastRecord(path)
+		if err != nil {
+			return nil, err
+		}
+		if last != nil {
+			l.seq = last.Seq
+			break
+		}
+	}
+	err = l.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func WithPath(path string) LogConfiguration {
+	return func(l *Log) error {
+		l.path = path
+		return nil
+	}
+}
+
+// WithMaxSize rotates the file once it would grow past n bytes.
+func WithMaxSize(n int64) LogConfiguration {
+	return func(l *Log) error {
+		if n < 1 {
+			return fmt.Errorf("audit: max size %d isn't positive", n)
+		}
+		l.maxSize = n
+		return nil
+	}
+}
+
+// WithMaxFiles keeps n rotated files besides the current one.
+func WithMaxFiles(n int) LogConfiguration {
+	return func(l *Log) error {
+		if n < 0 {
+			return fmt.Errorf("audit: max files %d is negative", n)
+		}
+		l.maxFiles = n
+		return nil
+	}
+}
+
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file = f
+	l.size = info.Size()
+	return nil
+}
+
+// files returns the files of the log, newest first.
+func (l *Log) files() []string {
+	paths := []string{l.path}
+	for i := 1; i <= l.maxFiles; i++ {
+		paths = append(paths, l.path+"."+strconv.Itoa(i))
+	}
+	return paths
+}
+
+// rotate expects l to be locked.
+func (l *Log) rotate() error {
+	err := l.file.Close()
+	if err != nil {
+		return err
+	}
+	paths := l.files()
+	os.Remove(paths[len(paths)-1])
+	for i := len(paths) - 1; i > 0; i-- {
+		err = os.Rename(paths[i-1], paths[i])
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return l.open()
+}
+
+// Commit writes a record for each of events, all made by req. The
+// events are those a list.Collector gathered for the request.
+func (l *Log) Commit(events []list.Event, req Request) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	now := l.now().UTC()
+	for _, e := range events {
+		l.seq++
+		r := Record{
+			Seq:       l.seq,
+			Time:      now,
+			Actor:     req.Actor,
+			RequestID: req.RequestID,
+			List:      req.List,
+			Op:        e.Op,
+			Index:     e.Index,
+			Version:   e.Version,
+		}
+		value := e.Value
+		switch e.Op {
+		case list.OpInsert:
+			r.New = &value
+		case list.OpRemove:
+			r.Old = &value
+		case list.OpSet:
+			r.Old, r.New = e.Old, &value
+		}
+		enc.Encode(r)
+	}
+	if l.size > 0 && l.size+int64(buf.Len()) > l.maxSize {
+		err := l.rotate()
+		if err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(buf.Bytes())
+	l.size += int64(n)
+	return err
+}
+
+// Close closes the current file.
+func (l *Log) Close() error {
+	l.Lock()
+	defer l.Unlock()
+	return l.file.Close()
+}
+
+// GlobalList is the Filter.List that matches the records of the global
+// list, which have no list name.
+const GlobalList = "-"
+
+// Filter picks records; zero fields match every record.
+type Filter struct {
+	Actor     string
+	RequestID string
+	// List is the name of a list, or GlobalList
+	List  string
+	Op    string
+	Since time.Time
+	Until time.Time
+	// Before only matches records older than this seq, to page backwards
+	Before uint64
+	Limit  int
+}
+
+func (f Filter) matches(r *Record) bool {
+	return (f.Actor == "" || r.Actor == f.Actor) &&
+		(f.RequestID == "" || r.RequestID == f.RequestID) &&
+		(f.List == "" || r.List == f.List || (f.List == GlobalList && r.List == "")) &&
+		(f.Op == "" || r.Op == f.Op) &&
+		(f.Since.IsZero() || !r.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || !r.Time.After(f.Until)) &&
+		(f.Before == 0 || r.Seq < f.Before)
+}
+
+// Query returns up to f.Limit records matching f, newest first, and whether
+// there are more.
+func (l *Log) Query(f Filter) ([]Record, bool, error) {
+	files, err := l.snapshot()
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	records := []Record{}
+	for _, file := range files {
+		matches, err := scan(file, f)
+		if err != nil {
+			return nil, false, err
+		}
+		for i := len(matches) - 1; i >= 0; i-- {
+			if f.Limit > 0 && len(records) == f.Limit {
+				return records, true, nil
+			}
+			records = append(records, matches[i])
+		}
+	}
+	return records, false, nil
+}
+
+// snapshot opens the files of the log, newest first, with the current one
+// cut off at its size, so they can be read without holding up Commit: a
+// rotation renames and removes files, which leaves open ones as they were.
+func (l *Log) snapshot() ([]io.ReadCloser, error) {
+	l.Lock()
+	defer l.Unlock()
+	var files []io.ReadCloser
+	for i, path := range l.files() {
+		file, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, err
+		}
+		if i == 0 {
+			files = append(files, struct {
+				io.Reader
+				io.Closer
+			}{io.LimitReader(file, l.size), file})
+			continue
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// scan returns the records read from r matching f, oldest first.
+func scan(r io.Reader, f Filter) ([]Record, error) {
+	var matches []Record
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 4096), 1<<20)
+	for s.Scan() {
+		var r Record
+		// a line cut short by a crash is skipped rather than failing
+		// every query
+		if json.Unmarshal(s.Bytes(), &r) != nil {
+			continue
+		}
+		if f.matches(&r) {
+			matches = append(matches, r)
+		}
+	}
+	return matches, s.Err()
+}
+
+// lastRecord returns the last record in the file at path, or nil if it
+// holds none.
+func lastRecord(path string) (*Record, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := scan(file, Filter{})
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return &records[len(records)-1], nil
+}
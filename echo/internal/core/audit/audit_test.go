@@ -0,0 +1,155 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+)
+
+func newLog(t *testing.T, cfgs ...LogConfiguration) *Log {
+	l, err := New(append([]LogConfiguration{WithPath(filepath.Join(t.TempDir(), "audit.log"))}, cfgs...)...)
+	if err != nil {
+		t.Fatalf("Can't create log: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func TestCommit(t *testing.T) {
+	l := newLog(t)
+	old := 1
+	err := l.Commit([]list.Event{
+		{Version: 1, Op: list.OpInsert, Index: 0, Value: 1},
+		{Version: 2, Op: list.OpSet, Index: 0, Value: 2, Old: &old},
+	}, Request{Actor: "ci", RequestID: "a", List: "numbers"})
+	if err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	err = l.Commit([]list.Event{{Version: 3, Op: list.OpRemove, Index: 0, Value: 2}}, Request{Actor: "10.0.0.1", RequestID: "b"})
+	if err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	records, more, err := l.Query(Filter{})
+	if err != nil || more || len(records) != 3 {
+		t.Fatalf("Query() = %+v, %t, %v, want 3 records", records, more, err)
+	}
+	remove, set, insert := records[0], records[1], records[2]
+	if insert.Seq != 1 || insert.Op != list.OpInsert || insert.Old != nil || *insert.New != 1 || insert.Actor != "ci" {
+		t.Errorf("insert record = %+v", insert)
+	}
+	if set.Op != list.OpSet || *set.Old != 1 || *set.New != 2 || set.List != "numbers" || set.Version != 2 {
+		t.Errorf("set record = %+v", set)
+	}
+	if remove.Seq != 3 || *remove.Old != 2 || remove.New != nil || remove.RequestID != "b" {
+		t.Errorf("remove record = %+v", remove)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		filter Filter
+		want   []uint64
+	}{
+		{"actor", Filter{Actor: "ci"}, []uint64{2, 1}},
+		{"request", Filter{RequestID: "b"}, []uint64{3}},
+		{"list", Filter{List: "numbers"}, []uint64{2, 1}},
+		{"global list", Filter{List: GlobalList}, []uint64{3}},
+		{"op", Filter{Op: list.OpSet}, []uint64{2}},
+		{"before", Filter{Before: 3, Limit: 1}, []uint64{2}},
+		{"until", Filter{Until: insert.Time.Add(-time.Second)}, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			records, _, err := l.Query(tt.filter)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			if len(records) != len(tt.want) {
+				t.Fatalf("Query() = %+v, want seqs %v", records, tt.want)
+			}
+			for i, r := range records {
+				if r.Seq != tt.want[i] {
+					t.Errorf("Query()[%d].Seq = %d, want %d", i, r.Seq, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRotate(t *testing.T) {
+	l := newLog(t, WithMaxSize(300), WithMaxFiles(2))
+	for v := uint64(1); v <= 10; v++ {
+		err := l.Commit([]list.Event{{Version: v, Op: list.OpInsert, Value: int(v)}}, Request{Actor: "ci"})
+		if err != nil {
+			t.Fatalf("Commit() error = %v", err)
+		}
+	}
+
+	for _, path := range l.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Rotated file is missing: %v", err)
+		}
+		if info.Size() > 300 {
+			t.Errorf("%s is %d bytes, more than the max size", path, info.Size())
+		}
+	}
+	if _, err := os.Stat(l.path + ".3"); err == nil {
+		t.Errorf("More rotated files were kept than max files")
+	}
+
+	// the oldest records were dropped with the oldest file
+	records, more, err := l.Query(Filter{Limit: 2})
+	if err != nil || !more || records[0].Seq != 10 || records[1].Seq != 9 {
+		t.Errorf("Query() = %+v, %t, %v", records, more, err)
+	}
+	all, _, _ := l.Query(Filter{})
+	if len(all) >= 10 || all[len(all)-1].Seq == 1 {
+		t.Errorf("Query() kept %d records, oldest %d", len(all), all[len(all)-1].Seq)
+	}
+
+	// a new log carries on numbering
+	l.Close()
+	reopened, err := New(WithPath(l.path), WithMaxSize(300), WithMaxFiles(2))
+	if err != nil {
+		t.Fatalf("Can't reopen log: %v", err)
+	}
+	defer reopened.Close()
+	reopened.Commit([]list.Event{{Version: 11, Op: list.OpInsert}}, Request{Actor: "ci"})
+	records, _, _ = reopened.Query(Filter{Limit: 1})
+	if len(records) != 1 || records[0].Seq != 11 {
+		t.Errorf("Query() after reopening = %+v, want seq 11", records)
+	}
+}
+
+func TestQueryWhileRotating(t *testing.T) {
+	l := newLog(t, WithMaxSize(300), WithMaxFiles(2))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for v := uint64(1); v <= 200; v++ {
+			l.Commit([]list.Event{{Version: v, Op: list.OpInsert, Value: int(v)}}, Request{Actor: "ci"})
+		}
+	}()
+
+	// the files are read without the lock, so a query racing rotations
+	// must still see each record once, newest first
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		records, _, err := l.Query(Filter{})
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		for i := 1; i < len(records); i++ {
+			if records[i].Seq >= records[i-1].Seq {
+				t.Fatalf("Query() returned seq %d after %d", records[i].Seq, records[i-1].Seq)
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrNoCredentials      = errors.New("missing credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type Role string
+
+const (
+	// RoleReader may only use the read routes
+	RoleReader Role = "reader"
+	// RoleWriter may also change lists
+	RoleWriter Role = "writer"
+	// RoleAdmin may also change the members of the cluster
+	RoleAdmin Role = "admin"
+)
+
+func ParseRole(s string) (Role, error) {
+	switch r := Role(s); r {
+	case RoleReader, RoleWriter, RoleAdmin:
+		return r, nil
+	}
+	return "", fmt.Errorf("unknown role %q", s)
+}
+
+// Allows reports whether the role may send a request with method.
+func (r Role) Allows(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return r == RoleReader || r.Writes()
+	}
+	return r.Writes()
+}
+
+// Writes reports whether the role may change lists.
+func (r Role) Writes() bool {
+	return r == RoleWriter || r == RoleAdmin
+}
+
+// Principal is who a request was authenticated as.
+type Principal struct {
+	Name string
+	Role Role
+}
+
+type claims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+type AuthService struct {
+	sync.RWMutex
+	enabled bool
+	// keys is indexed by the sha256 of the key so lookups don't compare the
+	// secret itself
+	keys   map[[sha256.Size]byte]Principal
+	secret []byte
+	issuer string
+}
+
+type AuthConfiguration func(*AuthService) error
+
+func New(cfgs ...AuthConfiguration) (*AuthService, error) {
+	a := &AuthService{}
+	err := a.Reload(cfgs...)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Reload replaces the whole configuration of a, or leaves it untouched if
+// any of cfgs fails.
+func (a *AuthService) Reload(cfgs ...AuthConfiguration) error {
+	next := &AuthService{
+		keys: map[[sha256.Size]byte]Principal{},
+	}
+	for _, cfg := range cfgs {
+		err := cfg(next)
+		if err != nil {
+			return err
+		}
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	a.enabled = next.enabled
+	a.keys = next.keys
+	a.secret = next.secret
+	a.issuer = next.issuer
+	return nil
+}
+
+// WithEnabled turns authentication on; without it every request is allowed.
+func WithEnabled(enabled bool) AuthConfiguration {
+	return func(a *AuthService) error {
+		a.enabled = enabled
+		return nil
+	}
+}
+
+func WithAPIKey(name, key string, role Role) AuthConfiguration {
+	return func(a *AuthService) error {
+		if key == "" {
+			return fmt.Errorf("api key %q is empty", name)
+		}
+		a.keys[sha256.Sum256([]byte(key))] = Principal{
+			Name: name,
+			Role: role,
+		}
+		return nil
+	}
+}
+
+// WithJWT accepts HMAC-signed tokens carrying a role claim. If issuer is set
+// the iss claim has to match it.
+func WithJWT(secret, issuer string) AuthConfiguration {
+	return func(a *AuthService) error {
+		a.secret = []byte(secret)
+		a.issuer = issuer
+		return nil
+	}
+}
+
+func (a *AuthService) Enabled() bool {
+	a.RLock()
+	defer a.RUnlock()
+	return a.enabled
+}
+
+// Authenticate resolves a bearer token, either an API key or a JWT.
+func (a *AuthService) Authenticate(token string) (Principal, error) {
+	if token == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	a.RLock()
+	defer a.RUnlock()
+	if p, ok := a.keys[sha256.Sum256([]byte(token))]; ok {
+		return p, nil
+	}
+	if len(a.secret) == 0 {
+		return Principal{}, ErrInvalidCredentials
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}),
+		jwt.WithExpirationRequired(),
+	}
+	if a.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.issuer))
+	}
+	c := &claims{}
+	_, err := jwt.ParseWithClaims(token, c, func(*jwt.Token) (interface{}, error) {
+		return a.secret, nil
+	}, opts...)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	role, err := ParseRole(c.Role)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	return Principal{
+		Name: c.Subject,
+		Role: role,
+	}, nil
+}
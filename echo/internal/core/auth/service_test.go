@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func sign(t *testing.T, method jwt.SigningMethod, secret string, c claims) string {
+	token, err := jwt.NewWithClaims(method, c).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("Can't sign token: %v", err)
+	}
+	return token
+}
+
+func TestAuthenticate(t *testing.T) {
+	a, err := New(
+		WithEnabled(true),
+		WithAPIKey("ci", "key", RoleWriter),
+		WithJWT("secret", "echo"),
+	)
+	if err != nil {
+		t.Fatalf("Can't create auth service: %v", err)
+	}
+
+	valid := claims{
+		Role: "reader",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "alice",
+			Issuer:    "echo",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+	expired := valid
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	otherIssuer := valid
+	otherIssuer.Issuer = "someone"
+	badRole := valid
+	badRole.Role = "root"
+
+	tests := []struct {
+		name  string
+		token string
+		want  Principal
+		err   error
+	}{
+		{"no token", "", Principal{}, ErrNoCredentials},
+		{"api key", "key", Principal{"ci", RoleWriter}, nil},
+		{"unknown key", "other", Principal{}, ErrInvalidCredentials},
+		{"jwt", sign(t, jwt.SigningMethodHS256, "secret", valid), Principal{"alice", RoleReader}, nil},
+		{"wrong secret", sign(t, jwt.SigningMethodHS256, "other", valid), Principal{}, ErrInvalidCredentials},
+		{"expired", sign(t, jwt.SigningMethodHS256, "secret", expired), Principal{}, ErrInvalidCredentials},
+		{"issuer", sign(t, jwt.SigningMethodHS256, "secret", otherIssuer), Principal{}, ErrInvalidCredentials},
+		{"role", sign(t, jwt.SigningMethodHS512, "secret", badRole), Principal{}, ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		p, err := a.Authenticate(tt.token)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%s: error should be %v but is %v", tt.name, tt.err, err)
+		}
+		if p != tt.want {
+			t.Fatalf("%s: principal should be %+v but is %+v", tt.name, tt.want, p)
+		}
+	}
+
+	// a reload drops the old key
+	err = a.Reload(WithEnabled(true), WithAPIKey("ci", "new-key", RoleReader))
+	if err != nil {
+		t.Fatalf("Can't reload: %v", err)
+	}
+	if _, err := a.Authenticate("key"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Old key should be rejected after reload, got %v", err)
+	}
+}
+
+func TestRoleAllows(t *testing.T) {
+	if !RoleReader.Allows(http.MethodGet) || RoleReader.Allows(http.MethodPut) || RoleReader.Allows(http.MethodDelete) {
+		t.Fatalf("Reader should only be allowed to read")
+	}
+	if !RoleWriter.Allows(http.MethodGet) || !RoleWriter.Allows(http.MethodPut) || !RoleWriter.Allows(http.MethodDelete) {
+		t.Fatalf("Writer should be allowed to read and write")
+	}
+	if !RoleAdmin.Allows(http.MethodGet) || !RoleAdmin.Allows(http.MethodPut) || !RoleAdmin.Writes() {
+		t.Fatalf("Admin should be allowed to read and write")
+	}
+}
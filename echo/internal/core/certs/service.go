@@ -0,0 +1,122 @@
+// Package certs serves TLS with certificates that can be replaced while
+// the servers run.
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+var versions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseVersion turns "1.2" and the like into a tls version.
+func ParseVersion(s string) (uint16, error) {
+	v, ok := versions[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown tls version %q", s)
+	}
+	return v, nil
+}
+
+// CertService hands every new connection the latest loaded configuration.
+// Connections already established keep the certificate they started with.
+type CertService struct {
+	current atomic.Pointer[tls.Config]
+}
+
+type CertConfiguration func(*tls.Config) error
+
+func New(cfgs ...CertConfiguration) (*CertService, error) {
+	c := &CertService{}
+	err := c.Reload(cfgs...)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload replaces the whole configuration of c, or leaves it untouched if
+// any of cfgs fails, like when a certificate is being rewritten.
+func (c *CertService) Reload(cfgs ...CertConfiguration) error {
+	next := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	for _, cfg := range cfgs {
+		err := cfg(next)
+		if err != nil {
+			return err
+		}
+	}
+	if len(next.Certificates) == 0 {
+		return errors.New("certs: a certificate is required")
+	}
+	c.current.Store(next)
+	return nil
+}
+
+// WithKeyPair serves the certificate in the PEM file certFile, with the key
+// in keyFile.
+func WithKeyPair(certFile, keyFile string) CertConfiguration {
+	return func(cfg *tls.Config) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("certs: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+		return nil
+	}
+}
+
+// WithCertificate serves cert, like one made by SelfSigned.
+func WithCertificate(cert tls.Certificate) CertConfiguration {
+	return func(cfg *tls.Config) error {
+		cfg.Certificates = []tls.Certificate{cert}
+		return nil
+	}
+}
+
+func WithMinVersion(v uint16) CertConfiguration {
+	return func(cfg *tls.Config) error {
+		cfg.MinVersion = v
+		return nil
+	}
+}
+
+// WithClientCA requires clients to present a certificate signed by one of
+// the PEM certificates in the file at path.
+func WithClientCA(path string) CertConfiguration {
+	return func(cfg *tls.Config) error {
+		bundle, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("certs: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return fmt.Errorf("certs: no certificates in %s", path)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		return nil
+	}
+}
+
+// TLSConfig returns a configuration for servers that looks up the current
+// one on every handshake, so a reload applies to the next connection.
+func (c *CertService) TLSConfig() *tls.Config {
+	return &tls.Config{
+		NextProtos: []string{"h2", "http/1.1"},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return c.current.Load(), nil
+		},
+	}
+}
@@ -0,0 +1,209 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/hashicorp/raft"
+)
+
+// member is a node with the lists it applies the log to.
+type member struct {
+	node  *Node
+	list  *list.ListService
+	lists *list.Registry
+}
+
+func freeAddress(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Can't find a free port: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// startMember starts a node on address that keeps its state in dir, with
+// timeouts short enough for tests.
+func startMember(t *testing.T, id, address, dir string, bootstrap bool) *member {
+	n, err := New(WithID(id), WithAddress(address), WithDir(dir), WithBootstrap(bootstrap), WithLogLevel("off"))
+	if err != nil {
+		t.Fatalf("Can't create node %s: %v", id, err)
+	}
+	n.tune = func(c *raft.Config) {
+		c.HeartbeatTimeout = 100 * time.Millisecond
+		c.ElectionTimeout = 100 * time.Millisecond
+		c.LeaderLeaseTimeout = 50 * time.Millisecond
+		c.CommitTimeout = 5 * time.Millisecond
+	}
+	l, err := list.New(list.BootList(), list.WithProposer(n.Propose))
+	if err != nil {
+		t.Fatalf("Can't create list: %v", err)
+	}
+	r, err := list.NewRegistry(list.WithRegistryProposer(n.Propose))
+	if err != nil {
+		t.Fatalf("Can't create registry: %v", err)
+	}
+	err = n.Start(l, r)
+	if err != nil {
+		t.Fatalf("Can't start node %s: %v", id, err)
+	}
+	m := &member{node: n, list: l, lists: r}
+	t.Cleanup(func() {
+		m.node.Shutdown()
+	})
+	return m
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// leaderOf waits until one of members leads and returns it.
+func leaderOf(t *testing.T, members ...*member) *member {
+	var leader *member
+	waitFor(t, "a leader", func() bool {
+		for _, m := range members {
+			if m.node.raft.State() == raft.Leader {
+				leader = m
+				return true
+			}
+		}
+		return false
+	})
+	return leader
+}
+
+// values returns the global list and the list named a of m.
+func values(m *member) ([]int, []int) {
+	global, _ := m.list.Values()
+	var named []int
+	if l, ok := m.lists.Get("a"); ok {
+		named, _ = l.Values()
+	}
+	return global, named
+}
+
+func TestCluster(t *testing.T) {
+	dir := t.TempDir()
+	members := make([]*member, 3)
+	for i := range members {
+		id := fmt.Sprintf("node%d", i+1)
+		members[i] = startMember(t, id, freeAddress(t), filepath.Join(dir, id), i == 0)
+	}
+	leader := leaderOf(t, members[0])
+	for _, m := range members[1:] {
+		err := leader.node.Join(m.node.id, m.node.address)
+		if err != nil {
+			t.Fatalf("Can't add %s: %v", m.node.id, err)
+		}
+	}
+	status, err := leader.node.Status()
+	if err != nil {
+		t.Fatalf("Can't read the status: %v", err)
+	}
+	if len(status.Members) != 3 || status.Leader != "node1" {
+		t.Fatalf("Status is %+v, want 3 members led by node1", status)
+	}
+
+	for i := 1; i <= 3; i++ {
+		_, err := leader.list.Insert(context.Background(), 0, i, nil)
+		if err != nil {
+			t.Fatalf("Can't insert on the leader: %v", err)
+		}
+	}
+	_, err = leader.lists.Create("a", 0)
+	if err != nil {
+		t.Fatalf("Can't create a list on the leader: %v", err)
+	}
+	a, _ := leader.lists.Get("a")
+	// the events made where the change was applied come back to the caller
+	ctx, events := list.Collect(context.Background())
+	_, err = a.Insert(ctx, 0, 10, nil)
+	if err != nil {
+		t.Fatalf("Can't insert into a: %v", err)
+	}
+	if got := events.Events(); len(got) != 1 || got[0].Value != 10 {
+		t.Errorf("Collected %+v, want the insert of 10", got)
+	}
+	for _, m := range members {
+		waitFor(t, m.node.id+" to apply the changes", func() bool {
+			global, named := values(m)
+			return reflect.DeepEqual(global, []int{3, 2, 1}) && reflect.DeepEqual(named, []int{10})
+		})
+	}
+
+	follower := members[1]
+	if _, err := follower.list.Insert(context.Background(), 0, 4, nil); !errors.Is(err, ErrNotLeader) {
+		t.Errorf("Insert on a follower returned %v, want %v", err, ErrNotLeader)
+	}
+	if err := follower.node.Linearize(); !errors.Is(err, ErrNotLeader) {
+		t.Errorf("Linearize on a follower returned %v, want %v", err, ErrNotLeader)
+	}
+	if err := leader.node.Linearize(); err != nil {
+		t.Errorf("Linearize on the leader returned %v", err)
+	}
+	stale := func(version uint64) bool { return version == 1 }
+	if _, err := leader.list.Remove(context.Background(), 0, stale); !errors.Is(err, list.ErrVersionMismatch) {
+		t.Errorf("Remove with a stale version returned %v, want %v", err, list.ErrVersionMismatch)
+	}
+
+	// the others elect a new leader that keeps taking changes
+	leader.node.Shutdown()
+	next := leaderOf(t, members[1], members[2])
+	_, err = next.list.Remove(context.Background(), 0, nil)
+	if err != nil {
+		t.Fatalf("Can't remove on the new leader: %v", err)
+	}
+	for _, m := range members[1:] {
+		waitFor(t, m.node.id+" to apply the remove", func() bool {
+			global, _ := values(m)
+			return reflect.DeepEqual(global, []int{2, 1})
+		})
+	}
+}
+
+func TestRestart(t *testing.T) {
+	dir := t.TempDir()
+	address := freeAddress(t)
+	m := startMember(t, "node1", address, dir, true)
+	leaderOf(t, m)
+	for i := 1; i <= 3; i++ {
+		m.list.Insert(context.Background(), 0, i, nil)
+	}
+	m.lists.Create("a", 5)
+	// part of the state comes from a snapshot, the rest from the log
+	err := m.node.raft.Snapshot().Error()
+	if err != nil {
+		t.Fatalf("Can't take a snapshot: %v", err)
+	}
+	a, _ := m.lists.Get("a")
+	a.Insert(context.Background(), 0, 10, nil)
+	m.node.Shutdown()
+
+	restarted := startMember(t, "node1", address, dir, true)
+	// the log after the snapshot is applied once the node leads again
+	waitFor(t, "the log to be applied", func() bool {
+		global, named := values(restarted)
+		return reflect.DeepEqual(global, []int{3, 2, 1}) && reflect.DeepEqual(named, []int{10})
+	})
+	if version := restarted.list.Version(); version != 3 {
+		t.Errorf("Restarted at version %d, want 3", version)
+	}
+	if a, _ := restarted.lists.Get("a"); a.MaxSize() != 5 {
+		t.Errorf("Restarted a with max size %d, want 5", a.MaxSize())
+	}
+}
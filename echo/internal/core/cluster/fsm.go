@@ -0,0 +1,99 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/hashicorp/raft"
+)
+
+// fsm applies the commands of the raft log to the lists of this node.
+type fsm struct {
+	global   *list.ListService
+	registry *list.Registry
+}
+
+// result is what applying a command returned, handed back to the node that
+// proposed it.
+type result struct {
+	version uint64
+	events  []list.Event
+	err     error
+}
+
+func (f *fsm) Apply(entry *raft.Log) interface{} {
+	var cmd list.Command
+	err := json.Unmarshal(entry.Data, &cmd)
+	if err != nil {
+		return result{err: err}
+	}
+	switch cmd.Op {
+	case list.OpCreate, list.OpDelete, list.OpRename:
+		return result{err: f.registry.Apply(cmd)}
+	}
+	l, err := f.list(cmd.List)
+	if err != nil {
+		return result{err: err}
+	}
+	ctx, events := list.Collect(context.Background())
+	version, err := l.Apply(ctx, cmd)
+	return result{version: version, events: events.Events(), err: err}
+}
+
+// list returns the list with id; zero is the global one.
+func (f *fsm) list(id uint64) (*list.ListService, error) {
+	if id == 0 {
+		return f.global, nil
+	}
+	l, ok := f.registry.ByID(id)
+	if !ok {
+		return nil, list.ErrListNotFound
+	}
+	return l, nil
+}
+
+// state is every list of the node as snapshots hold it.
+type state struct {
+	Global list.ListState   `json:"global"`
+	LastID uint64           `json:"last_id"`
+	Lists  []list.ListState `json:"lists"`
+}
+
+// Snapshot copies the lists; raft doesn't apply anything until it returns,
+// so they are all read at the same point of the log.
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	values, version := f.global.Values()
+	s := &snapshot{state: state{
+		Global: list.ListState{Version: version, Values: values},
+	}}
+	s.state.LastID, s.state.Lists = f.registry.State()
+	return s, nil
+}
+
+func (f *fsm) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+	var s state
+	err := json.NewDecoder(rc).Decode(&s)
+	if err != nil {
+		return err
+	}
+	f.global.Restore(s.Global.Values, s.Global.Version)
+	return f.registry.Restore(s.LastID, s.Lists)
+}
+
+type snapshot struct {
+	state state
+}
+
+func (s *snapshot) Persist(sink raft.SnapshotSink) error {
+	err := json.NewEncoder(sink).Encode(s.state)
+	if err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *snapshot) Release() {}
@@ -0,0 +1,361 @@
+// Package cluster replicates the lists of a node across a cluster through a
+// raft log, so every change is applied on a majority before it is
+// acknowledged and every node applies the same changes in the same order.
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb/v2"
+)
+
+var (
+	ErrNotLeader  = errors.New("not the leader")
+	ErrNotStarted = errors.New("cluster node is not started")
+	ErrNoMember   = errors.New("no such cluster member")
+)
+
+const (
+	// applyTimeout bounds how long a change waits to be committed
+	applyTimeout = 10 * time.Second
+	// retainSnapshots is how many snapshots are kept on disk
+	retainSnapshots = 2
+	// maxPool is how many connections are kept open to every other member
+	maxPool = 3
+)
+
+// Member is a server of the cluster configuration.
+type Member struct {
+	ID      string `json:"id"`
+	Address string `json:"address"`
+	Voter   bool   `json:"voter"`
+	Leader  bool   `json:"leader"`
+}
+
+// Status is how a node sees the cluster.
+type Status struct {
+	ID      string   `json:"id"`
+	State   string   `json:"state"`
+	Leader  string   `json:"leader,omitempty"`
+	Term    uint64   `json:"term"`
+	Applied uint64   `json:"applied"`
+	Members []Member `json:"members"`
+}
+
+// Node is this process as a member of the cluster.
+type Node struct {
+	id        string
+	address   string
+	dir       string
+	bootstrap bool
+	logger    hclog.Logger
+	// tune adjusts the raft settings, so tests can use short timeouts
+	tune func(*raft.Config)
+
+	raft      *raft.Raft
+	fsm       *fsm
+	store     *raftboltdb.BoltStore
+	transport *raft.NetworkTransport
+
+	// mu lets changes without a precondition be proposed side by side,
+	// while one with a precondition has the log to itself from its check
+	// until it is applied
+	mu sync.RWMutex
+	// caughtUp is the term in which this node last applied everything
+	// committed before it became the leader
+	caughtUp atomic.Uint64
+}
+
+type NodeConfiguration func(*Node) error
+
+// New sets a node up without joining the cluster; Start does that once the
+// lists that take the node's proposer exist.
+func New(cfgs ...NodeConfiguration) (*Node, error) {
+	n := &Node{
+		logger: hclog.New(&hclog.LoggerOptions{
+			Name:  "raft",
+			Level: hclog.Error,
+		}),
+	}
+
+	for _, cfg := range cfgs {
+		err := cfg(n)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if n.id == "" || n.address == "" || n.dir == "" {
+		return nil, errors.New("cluster: a node id, address and directory are required")
+	}
+
+	return n, nil
+}
+
+// WithID names the node; the name must stay the same across restarts.
+func WithID(id string) NodeConfiguration {
+	return func(n *Node) error {
+		n.id = id
+		return nil
+	}
+}
+
+// WithAddress is the host:port the node listens on for the other members,
+// which is also the address they reach it at.
+func WithAddress(address string) NodeConfiguration {
+	return func(n *Node) error {
+		n.address = address
+		return nil
+	}
+}
+
+// WithDir keeps the raft log and snapshots in dir.
+func WithDir(dir string) NodeConfiguration {
+	return func(n *Node) error {
+		n.dir = dir
+		return nil
+	}
+}
+
+// WithBootstrap starts a new cluster with the node as its only member,
+// unless dir already holds one.
+func WithBootstrap(bootstrap bool) NodeConfiguration {
+	return func(n *Node) error {
+		n.bootstrap = bootstrap
+		return nil
+	}
+}
+
+// WithLogLevel sets the level of the raft logs: debug, info, warn or error.
+func WithLogLevel(level string) NodeConfiguration {
+	return func(n *Node) error {
+		n.logger.SetLevel(hclog.LevelFromString(level))
+		return nil
+	}
+}
+
+// Start loads what dir holds into global and registry and joins the
+// cluster. Changes proposed by the lists are applied to them from now on.
+func (n *Node) Start(global *list.ListService, registry *list.Registry) error {
+	err := os.MkdirAll(n.dir, 0o700)
+	if err != nil {
+		return err
+	}
+	n.fsm = &fsm{global: global, registry: registry}
+
+	store, err := raftboltdb.NewBoltStore(filepath.Join(n.dir, "raft.db"))
+	if err != nil {
+		return err
+	}
+	snaps, err := raft.NewFileSnapshotStoreWithLogger(n.dir, retainSnapshots, n.logger)
+	if err != nil {
+		store.Close()
+		return err
+	}
+	advertise, err := net.ResolveTCPAddr("tcp", n.address)
+	if err != nil {
+		store.Close()
+		return err
+	}
+	transport, err := raft.NewTCPTransportWithLogger(n.address, advertise, maxPool, applyTimeout, n.logger)
+	if err != nil {
+		store.Close()
+		return err
+	}
+
+	conf := raft.DefaultConfig()
+	conf.LocalID = raft.ServerID(n.id)
+	conf.Logger = n.logger
+	if n.tune != nil {
+		n.tune(conf)
+	}
+
+	if n.bootstrap {
+		existing, err := raft.HasExistingState(store, store, snaps)
+		if err == nil && !existing {
+			err = raft.BootstrapCluster(conf, store, store, snaps, transport, raft.Configuration{
+				Servers: []raft.Server{{
+					Suffrage: raft.Voter,
+					ID:       conf.LocalID,
+					Address:  transport.LocalAddr(),
+				}},
+			})
+		}
+		if err != nil {
+			transport.Close()
+			store.Close()
+			return err
+		}
+	}
+
+	r, err := raft.NewRaft(conf, n.fsm, store, store, snaps, transport)
+	if err != nil {
+		transport.Close()
+		store.Close()
+		return err
+	}
+	n.raft = r
+	n.store = store
+	n.transport = transport
+	return nil
+}
+
+// Shutdown leaves the cluster without telling the other members, so the
+// node rejoins when it starts again.
+func (n *Node) Shutdown() error {
+	if n.raft == nil {
+		return nil
+	}
+	err := n.raft.Shutdown().Error()
+	return errors.Join(err, n.transport.Close(), n.store.Close())
+}
+
+// Propose is the list.Proposer of the node. Only the leader takes changes;
+// every other node returns ErrNotLeader.
+func (n *Node) Propose(ctx context.Context, cmd list.Command, match list.Match) (uint64, error) {
+	if match == nil {
+		n.mu.RLock()
+		defer n.mu.RUnlock()
+	} else {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+	}
+	err := n.catchUp()
+	if err != nil {
+		return 0, err
+	}
+	if match != nil {
+		l, err := n.fsm.list(cmd.List)
+		if err != nil {
+			return 0, err
+		}
+		version := l.Version()
+		if !match(version) {
+			return version, list.ErrVersionMismatch
+		}
+	}
+
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return 0, err
+	}
+	f := n.raft.Apply(data, applyTimeout)
+	err = f.Error()
+	if err != nil {
+		return 0, leaderError(err)
+	}
+	res := f.Response().(result)
+	list.Collected(ctx, res.events...)
+	return res.version, res.err
+}
+
+// catchUp makes sure a node that just became the leader applied everything
+// its predecessor committed, so the lists here are the latest.
+func (n *Node) catchUp() error {
+	if n.raft == nil {
+		return ErrNotStarted
+	}
+	if n.raft.State() != raft.Leader {
+		return ErrNotLeader
+	}
+	term := n.raft.CurrentTerm()
+	if n.caughtUp.Load() == term {
+		return nil
+	}
+	err := n.raft.Barrier(applyTimeout).Error()
+	if err != nil {
+		return leaderError(err)
+	}
+	n.caughtUp.Store(term)
+	return nil
+}
+
+// Linearize returns once reads on this node see every change acknowledged
+// before it was called. Only the leader can tell; every other node returns
+// ErrNotLeader.
+func (n *Node) Linearize() error {
+	err := n.catchUp()
+	if err != nil {
+		return err
+	}
+	return leaderError(n.raft.VerifyLeader().Error())
+}
+
+func leaderError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, raft.ErrNotLeader), errors.Is(err, raft.ErrLeadershipTransferInProgress):
+		return ErrNotLeader
+	}
+	return fmt.Errorf("cluster: %w", err)
+}
+
+// Status reports the state of the node and the members it knows about.
+func (n *Node) Status() (Status, error) {
+	if n.raft == nil {
+		return Status{}, ErrNotStarted
+	}
+	f := n.raft.GetConfiguration()
+	err := f.Error()
+	if err != nil {
+		return Status{}, err
+	}
+	_, leader := n.raft.LeaderWithID()
+	s := Status{
+		ID:      n.id,
+		State:   n.raft.State().String(),
+		Leader:  string(leader),
+		Term:    n.raft.CurrentTerm(),
+		Applied: n.raft.AppliedIndex(),
+	}
+	for _, server := range f.Configuration().Servers {
+		s.Members = append(s.Members, Member{
+			ID:      string(server.ID),
+			Address: string(server.Address),
+			Voter:   server.Suffrage == raft.Voter,
+			Leader:  server.ID == leader,
+		})
+	}
+	return s, nil
+}
+
+// Join adds a voting member reachable at address. Only the leader changes
+// the membership.
+func (n *Node) Join(id, address string) error {
+	if n.raft == nil {
+		return ErrNotStarted
+	}
+	return leaderError(n.raft.AddVoter(raft.ServerID(id), raft.ServerAddress(address), 0, applyTimeout).Error())
+}
+
+// Leave removes the member with id, which may be the leader itself.
+func (n *Node) Leave(id string) error {
+	if n.raft == nil {
+		return ErrNotStarted
+	}
+	f := n.raft.GetConfiguration()
+	err := f.Error()
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, server := range f.Configuration().Servers {
+		found = found || server.ID == raft.ServerID(id)
+	}
+	if !found {
+		return ErrNoMember
+	}
+	return leaderError(n.raft.RemoveServer(raft.ServerID(id), 0, applyTimeout).Error())
+}
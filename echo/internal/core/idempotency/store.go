@@ -0,0 +1,135 @@
+package idempotency
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInProgress = errors.New("a request with this key is still in progress")
+	ErrMismatch   = errors.New("key was used for a different request")
+)
+
+// Response is what gets replayed for a repeated key.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+type entry struct {
+	fingerprint string
+	// response is nil while the first request is still running
+	response *Response
+	expires  time.Time
+}
+
+// Store remembers the response of each idempotency key for a TTL. Expired
+// keys are dropped lazily while the store is used.
+type Store struct {
+	sync.Mutex
+	entries   map[string]*entry
+	ttl       time.Duration
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type StoreConfiguration func(*Store) error
+
+func New(cfgs ...StoreConfiguration) (*Store, error) {
+	s := &Store{
+		entries: map[string]*entry{},
+		now:     time.Now,
+	}
+
+	for _, cfg := range cfgs {
+		err := cfg(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func WithTTL(ttl time.Duration) StoreConfiguration {
+	return func(s *Store) error {
+		s.ttl = ttl
+		return nil
+	}
+}
+
+// SetTTL changes how long keys stored from now on are kept.
+func (s *Store) SetTTL(ttl time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+	s.ttl = ttl
+}
+
+// Enabled reports whether keys are kept at all.
+func (s *Store) Enabled() bool {
+	s.Lock()
+	defer s.Unlock()
+	return s.ttl > 0
+}
+
+// Begin claims key for the request identified by fingerprint. It returns the
+// stored response if the key was already used for the same request, or nil
+// if the caller should run the request and then call Finish or Abort.
+func (s *Store) Begin(key, fingerprint string) (*Response, error) {
+	s.Lock()
+	defer s.Unlock()
+	now := s.now()
+	s.sweep(now)
+
+	e, ok := s.entries[key]
+	if ok && now.Before(e.expires) {
+		if e.fingerprint != fingerprint {
+			return nil, ErrMismatch
+		}
+		if e.response == nil {
+			return nil, ErrInProgress
+		}
+		return e.response, nil
+	}
+
+	s.entries[key] = &entry{
+		fingerprint: fingerprint,
+		expires:     now.Add(s.ttl),
+	}
+	return nil, nil
+}
+
+// Finish stores the response of the request that claimed key.
+func (s *Store) Finish(key string, r Response) {
+	s.Lock()
+	defer s.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	e.response = &r
+	e.expires = s.now().Add(s.ttl)
+}
+
+// Abort releases key so the request can be retried, e.g. after a server error.
+func (s *Store) Abort(key string) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.entries, key)
+}
+
+// sweep drops expired keys at most once per TTL. It expects s to be locked.
+func (s *Store) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.ttl {
+		return
+	}
+	s.lastSweep = now
+	for key, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
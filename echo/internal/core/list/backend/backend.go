@@ -0,0 +1,71 @@
+// Package backend holds the data structures a list can keep its elements
+// in. They all behave the same; they only differ in what they are fast at.
+package backend
+
+import "fmt"
+
+// Backend is an ordered sequence of ints addressed by position. It isn't
+// safe for concurrent use; the list service locks around it.
+type Backend interface {
+	// Insert puts value at index, shifting the elements after it. index may
+	// be Len() to append; anything past that is refused.
+	Insert(index uint, value int) bool
+	// Remove drops the element at index, shifting the elements after it.
+	Remove(index uint) bool
+	// Find returns the first index holding value.
+	Find(value int) (uint, bool)
+	Get(index uint) (int, bool)
+	Len() uint
+	// Iterate calls fn with every element in order until fn returns false.
+	Iterate(fn func(index uint, value int) bool)
+}
+
+// Kind names a Backend implementation in the config.
+type Kind string
+
+const (
+	KindLinkedList Kind = "linkedlist"
+	KindSlice      Kind = "slice"
+	KindTree       Kind = "tree"
+)
+
+// Kinds lists every implementation New knows.
+var Kinds = []Kind{KindLinkedList, KindSlice, KindTree}
+
+// New returns an empty backend of kind; an empty kind is a linked list.
+func New(kind Kind) (Backend, error) {
+	switch kind {
+	case KindLinkedList, "":
+		return NewLinkedList(), nil
+	case KindSlice:
+		return NewSlice(), nil
+	case KindTree:
+		return NewTree(), nil
+	}
+	return nil, fmt.Errorf("backend: unknown kind %q", kind)
+}
+
+// Empty returns an empty backend of the same kind as b.
+func Empty(b Backend) Backend {
+	switch b.(type) {
+	case *Slice:
+		return NewSlice()
+	case *Tree:
+		return NewTree()
+	}
+	return NewLinkedList()
+}
+
+// Append adds values after the elements of b in order. A linked list is
+// filled from its head when it is empty, since reaching its end walks it.
+func Append(b Backend, values []int) {
+	if l, ok := b.(*LinkedList); ok && l.Len() == 0 {
+		for i := len(values) - 1; i >= 0; i-- {
+			l.Insert(0, values[i])
+		}
+		return
+	}
+	for _, value := range values {
+		b.Insert(b.Len(), value)
+	}
+}
@@ -0,0 +1,178 @@
+package backend
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/alipourhabibi/exercises-journal/linkedlist"
+)
+
+// TestConformance runs the same checks against every backend.
+func TestConformance(t *testing.T) {
+	backends := map[string]func() Backend{
+		"wrapped linkedlist": func() Backend { return WrapLinkedList(linkedlist.New()) },
+	}
+	for _, kind := range Kinds {
+		backends[string(kind)] = func() Backend {
+			b, err := New(kind)
+			if err != nil {
+				t.Fatalf("New(%q) error = %v", kind, err)
+			}
+			return b
+		}
+	}
+	for name, newBackend := range backends {
+		t.Run(name, func(t *testing.T) {
+			t.Run("empty", func(t *testing.T) {
+				testEmpty(t, newBackend())
+			})
+			t.Run("bounds", func(t *testing.T) {
+				testBounds(t, newBackend())
+			})
+			t.Run("iterate stops", func(t *testing.T) {
+				testIterateStops(t, newBackend())
+			})
+			t.Run("append", func(t *testing.T) {
+				testAppend(t, newBackend())
+			})
+			t.Run("matches a slice", func(t *testing.T) {
+				testRandom(t, newBackend())
+			})
+		})
+	}
+}
+
+func testEmpty(t *testing.T, b Backend) {
+	if b.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", b.Len())
+	}
+	if _, ok := b.Get(0); ok {
+		t.Error("Get(0) found an element")
+	}
+	if _, ok := b.Find(0); ok {
+		t.Error("Find(0) found an element")
+	}
+	if b.Remove(0) {
+		t.Error("Remove(0) succeeded")
+	}
+	b.Iterate(func(uint, int) bool {
+		t.Error("Iterate called fn")
+		return true
+	})
+}
+
+func testBounds(t *testing.T, b Backend) {
+	if b.Insert(1, 1) {
+		t.Error("Insert(1) into an empty backend succeeded")
+	}
+	for i, v := range []int{10, 20, 30} {
+		if !b.Insert(uint(i), v) {
+			t.Fatalf("Insert(%d) failed", i)
+		}
+	}
+	if b.Insert(4, 40) {
+		t.Error("Insert past the end succeeded")
+	}
+	if b.Remove(3) {
+		t.Error("Remove past the end succeeded")
+	}
+	if _, ok := b.Get(3); ok {
+		t.Error("Get past the end found an element")
+	}
+	if b.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", b.Len())
+	}
+}
+
+func testIterateStops(t *testing.T, b Backend) {
+	for i := 0; i < 5; i++ {
+		b.Insert(uint(i), i)
+	}
+	calls := 0
+	b.Iterate(func(index uint, value int) bool {
+		calls++
+		return index < 1
+	})
+	if calls != 2 {
+		t.Errorf("Iterate called fn %d times after it returned false, want 2", calls)
+	}
+}
+
+func testAppend(t *testing.T, b Backend) {
+	for _, values := range [][]int{{1, 2}, {3, 4, 5}} {
+		Append(b, values)
+	}
+	Append(b, nil)
+	got := []int{}
+	b.Iterate(func(_ uint, value int) bool {
+		got = append(got, value)
+		return true
+	})
+	if want := []int{1, 2, 3, 4, 5}; !slices.Equal(got, want) {
+		t.Errorf("after Append, Iterate() = %v, want %v", got, want)
+	}
+
+	empty := Empty(b)
+	if empty.Len() != 0 {
+		t.Errorf("Empty(b).Len() = %d, want 0", empty.Len())
+	}
+	if fmt.Sprintf("%T", empty) != fmt.Sprintf("%T", b) {
+		t.Errorf("Empty(b) is a %T, want a %T", empty, b)
+	}
+}
+
+// testRandom applies the same random operations to b and a plain slice and
+// compares them after every step.
+func testRandom(t *testing.T, b Backend) {
+	r := rand.New(rand.NewPCG(1, 2))
+	want := []int{}
+	for step := 0; step < 2000; step++ {
+		switch r.IntN(3) {
+		case 0, 1:
+			index := uint(r.IntN(len(want) + 1))
+			value := r.IntN(50)
+			if !b.Insert(index, value) {
+				t.Fatalf("step %d: Insert(%d) failed", step, index)
+			}
+			want = slices.Insert(want, int(index), value)
+		case 2:
+			if len(want) == 0 {
+				continue
+			}
+			index := uint(r.IntN(len(want)))
+			if !b.Remove(index) {
+				t.Fatalf("step %d: Remove(%d) failed", step, index)
+			}
+			want = slices.Delete(want, int(index), int(index)+1)
+		}
+
+		if b.Len() != uint(len(want)) {
+			t.Fatalf("step %d: Len() = %d, want %d", step, b.Len(), len(want))
+		}
+		value := r.IntN(50)
+		index, ok := b.Find(value)
+		wantIndex := slices.Index(want, value)
+		if ok != (wantIndex >= 0) || (ok && index != uint(wantIndex)) {
+			t.Fatalf("step %d: Find(%d) = %d, %v, want %d", step, value, index, ok, wantIndex)
+		}
+	}
+
+	got := []int{}
+	b.Iterate(func(index uint, value int) bool {
+		if index != uint(len(got)) {
+			t.Fatalf("Iterate gave index %d at position %d", index, len(got))
+		}
+		got = append(got, value)
+		return true
+	})
+	if !slices.Equal(got, want) {
+		t.Fatalf("Iterate() = %v, want %v", got, want)
+	}
+	for i, v := range want {
+		if got, ok := b.Get(uint(i)); !ok || got != v {
+			t.Fatalf("Get(%d) = %d, %v, want %d", i, got, ok, v)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package backend
+
+import "github.com/alipourhabibi/exercises-journal/linkedlist"
+
+// LinkedList adapts the linkedlist package. Every access walks from the
+// head, so it is only quick near the front.
+type LinkedList struct {
+	l *linkedlist.LinkedList
+	// the linked list doesn't expose its length, so it is tracked here
+	size uint
+}
+
+func NewLinkedList() *LinkedList {
+	return &LinkedList{l: linkedlist.New()}
+}
+
+// WrapLinkedList adapts an existing linked list, counting it once.
+func WrapLinkedList(l *linkedlist.LinkedList) *LinkedList {
+	b := &LinkedList{l: l}
+	l.Iterate(func(uint, int) bool {
+		b.size++
+		return true
+	})
+	return b
+}
+
+func (b *LinkedList) Insert(index uint, value int) bool {
+	if index > b.size || !b.l.Insert(index, value) {
+		return false
+	}
+	b.size++
+	return true
+}
+
+func (b *LinkedList) Remove(index uint) bool {
+	if index >= b.size || !b.l.Remove(index) {
+		return false
+	}
+	b.size--
+	return true
+}
+
+func (b *LinkedList) Find(value int) (uint, bool) {
+	return b.l.Find(value)
+}
+
+func (b *LinkedList) Get(index uint) (int, bool) {
+	return b.l.Get(index)
+}
+
+func (b *LinkedList) Len() uint {
+	return b.size
+}
+
+func (b *LinkedList) Iterate(fn func(index uint, value int) bool) {
+	b.l.Iterate(fn)
+}
@@ -0,0 +1,56 @@
+package backend
+
+import "slices"
+
+// Slice keeps the elements in one array: reads are constant time while
+// inserts and removes move everything after the index.
+type Slice struct {
+	values []int
+}
+
+func NewSlice() *Slice {
+	return &Slice{}
+}
+
+func (b *Slice) Insert(index uint, value int) bool {
+	if index > uint(len(b.values)) {
+		return false
+	}
+	b.values = slices.Insert(b.values, int(index), value)
+	return true
+}
+
+func (b *Slice) Remove(index uint) bool {
+	if index >= uint(len(b.values)) {
+		return false
+	}
+	b.values = slices.Delete(b.values, int(index), int(index)+1)
+	return true
+}
+
+func (b *Slice) Find(value int) (uint, bool) {
+	i := slices.Index(b.values, value)
+	if i < 0 {
+		return 0, false
+	}
+	return uint(i), true
+}
+
+func (b *Slice) Get(index uint) (int, bool) {
+	if index >= uint(len(b.values)) {
+		return 0, false
+	}
+	return b.values[index], true
+}
+
+func (b *Slice) Len() uint {
+	return uint(len(b.values))
+}
+
+func (b *Slice) Iterate(fn func(index uint, value int) bool) {
+	for i, value := range b.values {
+		if !fn(uint(i), value) {
+			return
+		}
+	}
+}
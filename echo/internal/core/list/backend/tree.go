@@ -0,0 +1,138 @@
+package backend
+
+import "math/rand/v2"
+
+// Tree is a treap ordered by position rather than by value: a randomly
+// balanced binary tree where each node knows the size of its subtree.
+// Inserts, removes and reads at any index take logarithmic time.
+type Tree struct {
+	root *treeNode
+}
+
+type treeNode struct {
+	value int
+	// priority keeps the tree balanced: parents outrank their children
+	priority    uint64
+	size        uint
+	left, right *treeNode
+}
+
+func NewTree() *Tree {
+	return &Tree{}
+}
+
+func sizeOf(n *treeNode) uint {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *treeNode) update() {
+	n.size = sizeOf(n.left) + 1 + sizeOf(n.right)
+}
+
+// split cuts n into its first k elements and the rest.
+func split(n *treeNode, k uint) (*treeNode, *treeNode) {
+	if n == nil {
+		return nil, nil
+	}
+	if sizeOf(n.left) >= k {
+		left, right := split(n.left, k)
+		n.left = right
+		n.update()
+		return left, n
+	}
+	left, right := split(n.right, k-sizeOf(n.left)-1)
+	n.right = left
+	n.update()
+	return n, right
+}
+
+// merge joins a and b, with every element of a before those of b.
+func merge(a, b *treeNode) *treeNode {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.priority > b.priority {
+		a.right = merge(a.right, b)
+		a.update()
+		return a
+	}
+	b.left = merge(a, b.left)
+	b.update()
+	return b
+}
+
+func (b *Tree) Insert(index uint, value int) bool {
+	if index > sizeOf(b.root) {
+		return false
+	}
+	n := &treeNode{value: value, priority: rand.Uint64(), size: 1}
+	left, right := split(b.root, index)
+	b.root = merge(merge(left, n), right)
+	return true
+}
+
+func (b *Tree) Remove(index uint) bool {
+	if index >= sizeOf(b.root) {
+		return false
+	}
+	left, right := split(b.root, index)
+	_, right = split(right, 1)
+	b.root = merge(left, right)
+	return true
+}
+
+func (b *Tree) Find(value int) (uint, bool) {
+	var found uint
+	ok := false
+	b.Iterate(func(index uint, v int) bool {
+		if v == value {
+			found, ok = index, true
+			return false
+		}
+		return true
+	})
+	return found, ok
+}
+
+func (b *Tree) Get(index uint) (int, bool) {
+	n := b.root
+	for n != nil {
+		left := sizeOf(n.left)
+		switch {
+		case index < left:
+			n = n.left
+		case index == left:
+			return n.value, true
+		default:
+			index -= left + 1
+			n = n.right
+		}
+	}
+	return 0, false
+}
+
+func (b *Tree) Len() uint {
+	return sizeOf(b.root)
+}
+
+func (b *Tree) Iterate(fn func(index uint, value int) bool) {
+	var index uint
+	var walk func(n *treeNode) bool
+	walk = func(n *treeNode) bool {
+		if n == nil {
+			return true
+		}
+		if !walk(n.left) || !fn(index, n.value) {
+			return false
+		}
+		index++
+		return walk(n.right)
+	}
+	walk(b.root)
+}
@@ -0,0 +1,115 @@
+package list
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	OpInsert = "insert"
+	OpRemove = "remove"
+	OpSet    = "set"
+)
+
+var (
+	ErrInvalidIndex  = errors.New("invalid index")
+	ErrIndexNotFound = errors.New("index not found")
+	ErrValueNotFound = errors.New("value not found")
+	ErrListFull      = errors.New("list is full")
+)
+
+// Operation is one step of a batch. Value is ignored for removes.
+type Operation struct {
+	Op    string `json:"op" validate:"required,oneof=insert remove set"`
+	Index uint   `json:"index"`
+	Value int    `json:"value"`
+}
+
+// BatchError tells which operation of a batch failed and why.
+type BatchError struct {
+	Operation int
+	Err       error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Operation, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Batch applies ops in order under a single lock. If any of them fails the
+// ones already applied are undone, so either all of ops take effect or none.
+// Each operation is its own version, but nobody sees the versions in between.
+func (l *ListService) Batch(ctx context.Context, ops []Operation, match Match) (version uint64, err error) {
+	ctx, span := startSpan(ctx, OpBatch, attribute.Int("list.operations", len(ops)))
+	defer func() { endSpan(span, version, err) }()
+	if l.propose != nil {
+		return l.propose(ctx, Command{Op: OpBatch, Operations: ops}, match)
+	}
+	return l.batch(ctx, ops, match)
+}
+
+func (l *ListService) batch(ctx context.Context, ops []Operation, match Match) (uint64, error) {
+	l.lock(ctx)
+	defer l.Unlock()
+	if !match.accepts(l.version) {
+		return l.version, ErrVersionMismatch
+	}
+
+	undo := make([]Operation, 0, len(ops))
+	for i, op := range ops {
+		u, err := l.apply(op)
+		if err != nil {
+			for j := len(undo) - 1; j >= 0; j-- {
+				// undoing a successful step can't fail
+				l.apply(undo[j])
+			}
+			return l.version, &BatchError{Operation: i, Err: err}
+		}
+		undo = append(undo, u)
+	}
+	for i, op := range ops {
+		e := Event{Op: op.Op, Index: op.Index, Value: op.Value}
+		switch op.Op {
+		case OpRemove:
+			e.Value = undo[i].Value
+		case OpSet:
+			e.Old = &undo[i].Value
+		}
+		l.commit(ctx, e)
+	}
+	return l.version, nil
+}
+
+// apply runs op and returns the operation that reverts it. It expects l to
+// be locked.
+func (l *ListService) apply(op Operation) (Operation, error) {
+	switch op.Op {
+	case OpInsert:
+		err := l.insert(op.Index, op.Value)
+		if err != nil {
+			return Operation{}, err
+		}
+		return Operation{Op: OpRemove, Index: op.Index}, nil
+	case OpRemove:
+		old, err := l.remove(op.Index)
+		if err != nil {
+			return Operation{}, err
+		}
+		return Operation{Op: OpInsert, Index: op.Index, Value: old}, nil
+	case OpSet:
+		old, ok := l.backend.Get(op.Index)
+		if !ok {
+			return Operation{}, ErrIndexNotFound
+		}
+		l.backend.Remove(op.Index)
+		l.backend.Insert(op.Index, op.Value)
+		return Operation{Op: OpSet, Index: op.Index, Value: old}, nil
+	}
+	return Operation{}, fmt.Errorf("unknown operation %q", op.Op)
+}
@@ -0,0 +1,41 @@
+package list
+
+import (
+	"context"
+	"sync"
+)
+
+type collectorKey struct{}
+
+// Collector gathers the events of the changes made with a context, so
+// whoever made them learns exactly what they did, however many there were.
+type Collector struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// Collect returns a context whose changes are gathered by the returned
+// Collector.
+func Collect(ctx context.Context) (context.Context, *Collector) {
+	c := &Collector{}
+	return context.WithValue(ctx, collectorKey{}, c), c
+}
+
+// Collected hands events to the Collector of ctx, if it has one. Proposers
+// call it with the events their command made where it was applied.
+func Collected(ctx context.Context, events ...Event) {
+	c, ok := ctx.Value(collectorKey{}).(*Collector)
+	if !ok || len(events) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, events...)
+}
+
+// Events returns the events gathered so far, in version order.
+func (c *Collector) Events() []Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Event(nil), c.events...)
+}
@@ -0,0 +1,152 @@
+package list
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	OpBatch  = "batch"
+	OpImport = "import"
+)
+
+// Command is a change to a list or to the set of lists, in a form that can
+// be sent to other processes. List is the id of the list a list change is
+// for; zero is the list that isn't held by a registry.
+type Command struct {
+	Op         string      `json:"op"`
+	List       uint64      `json:"list,omitempty"`
+	Index      uint        `json:"index,omitempty"`
+	Value      int         `json:"value,omitempty"`
+	Operations []Operation `json:"operations,omitempty"`
+	Values     []int       `json:"values,omitempty"`
+	Mode       ImportMode  `json:"mode,omitempty"`
+	Name       string      `json:"name,omitempty"`
+	To         string      `json:"to,omitempty"`
+	MaxSize    uint        `json:"max_size,omitempty"`
+}
+
+// Proposer sends cmd through a replicated log and returns what applying it
+// returned once it was applied here, handing the events it made to the
+// Collector of ctx. match is checked against the list cmd is for with no
+// change in between; it is nil for registry commands.
+type Proposer func(ctx context.Context, cmd Command, match Match) (uint64, error)
+
+// WithProposer sends every change of the list through p instead of making
+// it directly. Whatever applies the log calls Apply.
+func WithProposer(p Proposer) ListConfiguration {
+	return func(ls *ListService) error {
+		ls.propose = p
+		return nil
+	}
+}
+
+// Apply makes the list change cmd here, without proposing it. The events
+// it makes go to the Collector of ctx.
+func (l *ListService) Apply(ctx context.Context, cmd Command) (uint64, error) {
+	switch cmd.Op {
+	case OpInsert:
+		return l.insertMatch(ctx, cmd.Index, cmd.Value, nil)
+	case OpRemove:
+		return l.removeMatch(ctx, cmd.Index, nil)
+	case OpBatch:
+		return l.batch(ctx, cmd.Operations, nil)
+	case OpImport:
+		return l.importValues(ctx, cmd.Values, cmd.Mode, nil)
+	}
+	return l.Version(), fmt.Errorf("unknown list command %q", cmd.Op)
+}
+
+// WithRegistryProposer sends every change to the set of lists, and to the
+// lists created from now on, through p.
+func WithRegistryProposer(p Proposer) RegistryConfiguration {
+	return func(r *Registry) error {
+		r.propose = p
+		return nil
+	}
+}
+
+// Apply makes the registry change cmd here, without proposing it.
+func (r *Registry) Apply(cmd Command) error {
+	switch cmd.Op {
+	case OpCreate:
+		_, err := r.create(cmd.Name, cmd.MaxSize)
+		return err
+	case OpDelete:
+		return r.delete(cmd.Name)
+	case OpRename:
+		return r.rename(cmd.Name, cmd.To)
+	}
+	return fmt.Errorf("unknown registry command %q", cmd.Op)
+}
+
+// ByID returns the list with id.
+func (r *Registry) ByID(id uint64) (*ListService, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	for l, lid := range r.ids {
+		if lid == id {
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// Name returns the name of the list with id.
+func (r *Registry) Name(id uint64) (string, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	for name, l := range r.lists {
+		if r.ids[l] == id {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// ListState is a list as a snapshot of a registry holds it.
+type ListState struct {
+	ID      uint64 `json:"id"`
+	Name    string `json:"name"`
+	MaxSize uint   `json:"max_size"`
+	Version uint64 `json:"version"`
+	Values  []int  `json:"values"`
+}
+
+// State returns a copy of every list and the last id handed out.
+func (r *Registry) State() (uint64, []ListState) {
+	r.RLock()
+	defer r.RUnlock()
+	lists := make([]ListState, 0, len(r.lists))
+	for name, l := range r.lists {
+		values, version := l.Values()
+		lists = append(lists, ListState{
+			ID:      r.ids[l],
+			Name:    name,
+			MaxSize: l.MaxSize(),
+			Version: version,
+			Values:  values,
+		})
+	}
+	return r.lastID, lists
+}
+
+// Restore replaces every list with lists, keeping their ids, so that r
+// reads like the registry State was taken from. Observers aren't told.
+func (r *Registry) Restore(lastID uint64, lists []ListState) error {
+	r.Lock()
+	defer r.Unlock()
+	r.lists = map[string]*ListService{}
+	r.ids = map[*ListService]uint64{}
+	for _, s := range lists {
+		l, err := r.newList(s.ID, s.MaxSize)
+		if err != nil {
+			return err
+		}
+		l.Restore(s.Values, s.Version)
+		r.lists[s.Name] = l
+		r.ids[l] = s.ID
+	}
+	r.lastID = lastID
+	return nil
+}
@@ -0,0 +1,136 @@
+package list
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrEventsGone means the events after the requested version are no longer
+// buffered, so the subscriber has to read the list again before following it.
+var ErrEventsGone = errors.New("events are no longer buffered")
+
+// subscriberBuffer is how far a subscriber may fall behind before the feed
+// drops it. A dropped subscriber can resume from the replay buffer.
+const subscriberBuffer = 64
+
+// Feed fans list events out to subscribers and keeps the latest ones in a
+// bounded buffer so a subscriber can resume after a disconnect.
+type Feed struct {
+	sync.Mutex
+	buffer []Event
+	// next is where the following event goes once the buffer is full
+	next int
+	// latest is the version of the last published event
+	latest      uint64
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives events published after it was created. C is closed
+// when the subscription is closed or couldn't keep up.
+type Subscription struct {
+	// Replay holds the buffered events the subscriber asked to resume from
+	Replay []Event
+	// From is the version the subscription starts after, replay aside
+	From uint64
+	C    <-chan Event
+	c    chan Event
+	feed *Feed
+}
+
+func NewFeed(size int) *Feed {
+	if size < 1 {
+		size = 1
+	}
+	return &Feed{
+		buffer:      make([]Event, 0, size),
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// Publish buffers e and sends it to every subscriber. It never blocks:
+// subscribers that are too far behind are dropped instead.
+func (f *Feed) Publish(e Event) {
+	f.Lock()
+	defer f.Unlock()
+	if len(f.buffer) < cap(f.buffer) {
+		f.buffer = append(f.buffer, e)
+	} else {
+		f.buffer[f.next] = e
+		f.next = (f.next + 1) % len(f.buffer)
+	}
+	f.latest = e.Version
+
+	for s := range f.subscribers {
+		select {
+		case s.c <- e:
+		default:
+			f.drop(s)
+		}
+	}
+}
+
+// Latest returns the version of the last published event.
+func (f *Feed) Latest() uint64 {
+	f.Lock()
+	defer f.Unlock()
+	return f.latest
+}
+
+// Subscribe follows the feed from now on.
+func (f *Feed) Subscribe() *Subscription {
+	f.Lock()
+	defer f.Unlock()
+	return f.subscribe(nil)
+}
+
+// Resume follows the feed starting with the event after version. It fails
+// with ErrEventsGone if some of those events were already evicted.
+func (f *Feed) Resume(version uint64) (*Subscription, error) {
+	f.Lock()
+	defer f.Unlock()
+	if version > f.latest {
+		return nil, ErrEventsGone
+	}
+
+	replay := []Event{}
+	for i := range f.buffer {
+		e := f.buffer[(f.next+i)%len(f.buffer)]
+		if e.Version > version {
+			replay = append(replay, e)
+		}
+	}
+	// versions are consecutive, so a gap before the oldest buffered event
+	// means some were evicted
+	if version < f.latest && (len(replay) == 0 || replay[0].Version != version+1) {
+		return nil, ErrEventsGone
+	}
+	return f.subscribe(replay), nil
+}
+
+// subscribe expects f to be locked.
+func (f *Feed) subscribe(replay []Event) *Subscription {
+	c := make(chan Event, subscriberBuffer)
+	s := &Subscription{
+		Replay: replay,
+		From:   f.latest,
+		C:      c,
+		c:      c,
+		feed:   f,
+	}
+	f.subscribers[s] = struct{}{}
+	return s
+}
+
+// drop expects f to be locked.
+func (f *Feed) drop(s *Subscription) {
+	if _, ok := f.subscribers[s]; ok {
+		delete(f.subscribers, s)
+		close(s.c)
+	}
+}
+
+func (s *Subscription) Close() {
+	s.feed.Lock()
+	defer s.feed.Unlock()
+	s.feed.drop(s)
+}
@@ -0,0 +1,95 @@
+package list
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFeedResume(t *testing.T) {
+	l, err := New(BootList(), WithFeed(3))
+	if err != nil {
+		t.Fatalf("Can't create list: %v", err)
+	}
+	sub := l.Feed().Subscribe()
+	defer sub.Close()
+
+	for i := 0; i < 5; i++ {
+		_, err := l.Insert(context.Background(), 0, i+1, nil)
+		if err != nil {
+			t.Fatalf("Error inserting value %d: %v", i+1, err)
+		}
+	}
+	for i := uint64(1); i <= 5; i++ {
+		e := <-sub.C
+		if e.Version != i || e.Op != OpInsert || e.Value != int(i) {
+			t.Fatalf("Event %d should be insert of %d but is %+v", i, i, e)
+		}
+	}
+
+	// the buffer only holds versions 3 to 5
+	resumed, err := l.Feed().Resume(2)
+	if err != nil {
+		t.Fatalf("Should be able to resume after version 2: %v", err)
+	}
+	defer resumed.Close()
+	if len(resumed.Replay) != 3 || resumed.Replay[0].Version != 3 || resumed.Replay[2].Version != 5 {
+		t.Fatalf("Replay should be versions 3 to 5 but is %+v", resumed.Replay)
+	}
+
+	_, err = l.Feed().Resume(1)
+	if !errors.Is(err, ErrEventsGone) {
+		t.Fatalf("Resuming after an evicted version should fail, got %v", err)
+	}
+	_, err = l.Feed().Resume(6)
+	if !errors.Is(err, ErrEventsGone) {
+		t.Fatalf("Resuming after a future version should fail, got %v", err)
+	}
+
+	current, err := l.Feed().Resume(5)
+	if err != nil || len(current.Replay) != 0 {
+		t.Fatalf("Resuming at the latest version should replay nothing, got %v %+v", err, current.Replay)
+	}
+	current.Close()
+}
+
+func TestBatchEvents(t *testing.T) {
+	l, err := New(BootList(), WithFeed(10))
+	if err != nil {
+		t.Fatalf("Can't create list: %v", err)
+	}
+	sub := l.Feed().Subscribe()
+	defer sub.Close()
+
+	_, err = l.Batch(context.Background(), []Operation{
+		{Op: OpInsert, Index: 0, Value: 1},
+		{Op: OpRemove, Index: 5},
+	}, nil)
+	if err == nil {
+		t.Fatalf("Batch with an invalid remove should fail")
+	}
+	version, err := l.Batch(context.Background(), []Operation{
+		{Op: OpInsert, Index: 0, Value: 1},
+		{Op: OpSet, Index: 0, Value: 2},
+		{Op: OpRemove, Index: 0},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Batch should apply: %v", err)
+	}
+	if version != 3 {
+		t.Fatalf("Version should be 3 but is %d", version)
+	}
+
+	old := 1
+	want := []Event{
+		{Version: 1, Op: OpInsert, Index: 0, Value: 1},
+		{Version: 2, Op: OpSet, Index: 0, Value: 2, Old: &old},
+		{Version: 3, Op: OpRemove, Index: 0, Value: 2},
+	}
+	for _, w := range want {
+		if e := <-sub.C; !reflect.DeepEqual(e, w) {
+			t.Fatalf("Event should be %+v but is %+v", w, e)
+		}
+	}
+}
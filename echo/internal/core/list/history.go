@@ -0,0 +1,201 @@
+package list
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list/backend"
+)
+
+var (
+	ErrNoHistory       = errors.New("list keeps no history")
+	ErrHistoryGone     = errors.New("version is no longer kept")
+	ErrVersionNotFound = errors.New("version not reached yet")
+)
+
+// Record is a change to a list and when it was made.
+type Record struct {
+	Event
+	Time time.Time `json:"time"`
+}
+
+// History keeps the changes of a list for a while, so the list can be read
+// as it was at an earlier version. Changes older than the retention are
+// folded into a base copy of the list when Compact runs.
+type History struct {
+	sync.Mutex
+	retention time.Duration
+	// base is the list as it was at baseVersion, right before records
+	base        []int
+	baseVersion uint64
+	// baseTime is when the list reached baseVersion; zero if unknown
+	baseTime time.Time
+	records  []Record
+	now      func() time.Time
+}
+
+func NewHistory(retention time.Duration) *History {
+	return &History{
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// WithHistory records every change of the list in a history that keeps them
+// for retention.
+func WithHistory(retention time.Duration) ListConfiguration {
+	return func(ls *ListService) error {
+		ls.history = NewHistory(retention)
+		ls.history.baseTime = ls.history.now()
+		ls.observers = append(ls.observers, ls.history.record)
+		return nil
+	}
+}
+
+func (h *History) record(e Event) {
+	h.Lock()
+	defer h.Unlock()
+	h.records = append(h.records, Record{Event: e, Time: h.now()})
+}
+
+// reset starts the history over from values at version, like after a
+// snapshot was loaded.
+func (h *History) reset(values []int, version uint64) {
+	h.Lock()
+	defer h.Unlock()
+	h.base = append([]int(nil), values...)
+	h.baseVersion = version
+	h.baseTime = h.now()
+	h.records = nil
+}
+
+// SetRetention changes how long changes are kept from the next Compact on.
+func (h *History) SetRetention(retention time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+	h.retention = retention
+}
+
+// Compact folds the changes older than the retention into the base and
+// returns how many it dropped.
+func (h *History) Compact() int {
+	h.Lock()
+	defer h.Unlock()
+	cutoff := h.now().Add(-h.retention)
+	n := sort.Search(len(h.records), func(i int) bool {
+		return !h.records[i].Time.Before(cutoff)
+	})
+	if n == 0 {
+		return 0
+	}
+	for _, r := range h.records[:n] {
+		h.base = replayValues(h.base, r.Event)
+	}
+	h.baseVersion = h.records[n-1].Version
+	h.baseTime = h.records[n-1].Time
+	// copy so the dropped records can be freed
+	h.records = append([]Record(nil), h.records[n:]...)
+	return n
+}
+
+// Oldest returns the earliest version the history can still read.
+func (h *History) Oldest() uint64 {
+	h.Lock()
+	defer h.Unlock()
+	return h.baseVersion
+}
+
+// Records returns up to limit changes after version, and whether there are
+// more. Changes no longer kept give ErrHistoryGone.
+func (h *History) Records(after uint64, limit int) ([]Record, bool, error) {
+	h.Lock()
+	defer h.Unlock()
+	if after < h.baseVersion {
+		return nil, false, ErrHistoryGone
+	}
+	start := int(after - h.baseVersion)
+	if start > len(h.records) {
+		return []Record{}, false, nil
+	}
+	end := min(start+limit, len(h.records))
+	return append([]Record{}, h.records[start:end]...), end < len(h.records), nil
+}
+
+// valuesAt rebuilds the list at version.
+func (h *History) valuesAt(version uint64) ([]int, error) {
+	h.Lock()
+	defer h.Unlock()
+	if version < h.baseVersion {
+		return nil, ErrHistoryGone
+	}
+	if version-h.baseVersion > uint64(len(h.records)) {
+		return nil, ErrVersionNotFound
+	}
+	values := append([]int(nil), h.base...)
+	for _, r := range h.records[:version-h.baseVersion] {
+		values = replayValues(values, r.Event)
+	}
+	return values, nil
+}
+
+// versionAt returns the version the list was at at t.
+func (h *History) versionAt(t time.Time) (uint64, error) {
+	h.Lock()
+	defer h.Unlock()
+	if t.Before(h.baseTime) {
+		return 0, ErrHistoryGone
+	}
+	n := sort.Search(len(h.records), func(i int) bool {
+		return h.records[i].Time.After(t)
+	})
+	return h.baseVersion + uint64(n), nil
+}
+
+// replayValues applies e to values. Recorded events were valid when they
+// were made, so indexes are in range.
+func replayValues(values []int, e Event) []int {
+	switch e.Op {
+	case OpInsert:
+		values = append(values, 0)
+		copy(values[e.Index+1:], values[e.Index:])
+		values[e.Index] = e.Value
+	case OpRemove:
+		values = append(values[:e.Index], values[e.Index+1:]...)
+	case OpSet:
+		values[e.Index] = e.Value
+	}
+	return values
+}
+
+// History returns the history of the list, or nil if it keeps none.
+func (l *ListService) History() *History {
+	return l.history
+}
+
+// VersionAt returns the version the list was at at t.
+func (l *ListService) VersionAt(t time.Time) (uint64, error) {
+	if l.history == nil {
+		return 0, ErrNoHistory
+	}
+	return l.history.versionAt(t)
+}
+
+// AsOf returns a copy of the list as it was at version, for reading.
+func (l *ListService) AsOf(version uint64) (*ListService, error) {
+	if l.history == nil {
+		return nil, ErrNoHistory
+	}
+	values, err := l.history.valuesAt(version)
+	if err != nil {
+		return nil, err
+	}
+	b := backend.NewSlice()
+	backend.Append(b, values)
+	return &ListService{
+		backend: b,
+		maxSize: l.maxSize,
+		version: version,
+	}, nil
+}
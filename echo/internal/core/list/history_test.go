@@ -0,0 +1,108 @@
+package list
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list/backend"
+)
+
+func TestHistory(t *testing.T) {
+	l, err := New(BootBackend(backend.KindLinkedList), WithHistory(time.Minute))
+	if err != nil {
+		t.Fatalf("Can't create list: %v", err)
+	}
+	// a clock that only moves when told to
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.history.now = func() time.Time { return now }
+	l.history.baseTime = now
+	tick := func() {
+		now = now.Add(time.Second)
+	}
+
+	tick()
+	l.Insert(context.Background(), 0, 1, nil)
+	tick()
+	l.Insert(context.Background(), 1, 2, nil)
+	tick()
+	l.Insert(context.Background(), 0, 3, nil)
+	tick()
+	l.Batch(context.Background(), []Operation{{Op: OpSet, Index: 1, Value: 4}, {Op: OpRemove, Index: 2}}, nil)
+
+	want := map[uint64][]int{
+		0: {},
+		1: {1},
+		2: {1, 2},
+		3: {3, 1, 2},
+		4: {3, 4, 2},
+		5: {3, 4},
+	}
+	for version, values := range want {
+		old, err := l.AsOf(version)
+		if err != nil {
+			t.Fatalf("AsOf(%d) error = %v", version, err)
+		}
+		got, v := old.Values()
+		if !reflect.DeepEqual(got, values) || v != version {
+			t.Errorf("AsOf(%d) = %v at %d, want %v", version, got, v, values)
+		}
+	}
+	if _, err := l.AsOf(6); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("AsOf(6) error = %v, want %v", err, ErrVersionNotFound)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for at, version := range map[time.Duration]uint64{
+		0:                 0,
+		time.Second:       1,
+		2*time.Second + 1: 2,
+		4 * time.Second:   5,
+		time.Hour:         5,
+	} {
+		got, err := l.VersionAt(start.Add(at))
+		if err != nil || got != version {
+			t.Errorf("VersionAt(+%s) = %d, %v, want %d", at, got, err, version)
+		}
+	}
+
+	records, more, err := l.History().Records(1, 2)
+	if err != nil || !more || len(records) != 2 || records[0].Version != 2 || records[1].Version != 3 {
+		t.Errorf("Records(1, 2) = %+v, %t, %v", records, more, err)
+	}
+
+	// the first two changes fall out of the retention
+	now = start.Add(time.Minute + 3*time.Second)
+	if n := l.History().Compact(); n != 2 {
+		t.Errorf("Compact() = %d, want 2", n)
+	}
+	if oldest := l.History().Oldest(); oldest != 2 {
+		t.Errorf("Oldest() = %d, want 2", oldest)
+	}
+	if _, err := l.AsOf(1); !errors.Is(err, ErrHistoryGone) {
+		t.Errorf("AsOf(1) error = %v, want %v", err, ErrHistoryGone)
+	}
+	if _, err := l.VersionAt(start.Add(time.Second)); !errors.Is(err, ErrHistoryGone) {
+		t.Errorf("VersionAt(+1s) error = %v, want %v", err, ErrHistoryGone)
+	}
+	if _, _, err := l.History().Records(1, 2); !errors.Is(err, ErrHistoryGone) {
+		t.Errorf("Records(1, 2) error = %v, want %v", err, ErrHistoryGone)
+	}
+	old, err := l.AsOf(3)
+	if got, _ := old.Values(); err != nil || !reflect.DeepEqual(got, want[3]) {
+		t.Errorf("AsOf(3) after Compact() = %v, %v, want %v", got, err, want[3])
+	}
+
+	// a restored list starts its history over
+	l.Restore([]int{7}, 9)
+	if oldest := l.History().Oldest(); oldest != 9 {
+		t.Errorf("Oldest() after Restore() = %d, want 9", oldest)
+	}
+
+	plain, _ := New(BootBackend(backend.KindLinkedList))
+	if _, err := plain.AsOf(0); !errors.Is(err, ErrNoHistory) {
+		t.Errorf("AsOf() without history error = %v, want %v", err, ErrNoHistory)
+	}
+}
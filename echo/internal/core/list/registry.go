@@ -0,0 +1,378 @@
+package list
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list/backend"
+)
+
+var (
+	ErrListExists   = errors.New("list already exists")
+	ErrListNotFound = errors.New("list not found")
+	ErrInvalidName  = errors.New("invalid list name")
+)
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ListInfo describes a named list held by a Registry.
+type ListInfo struct {
+	Name    string `json:"name" validate:"required"`
+	Size    uint   `json:"size"`
+	MaxSize uint   `json:"max_size"`
+}
+
+const (
+	OpCreate = "create"
+	OpDelete = "delete"
+	OpRename = "rename"
+)
+
+// RegistryEvent describes a change to the set of named lists. ID stays the
+// same for a list across renames; Name is the new name of a renamed list.
+type RegistryEvent struct {
+	Op      string
+	ID      uint64
+	Name    string
+	MaxSize uint
+}
+
+// RegistryObserver is told about every change to the set of lists in
+// order. It runs while the registry is locked, so it must not call back
+// into it or block.
+type RegistryObserver func(RegistryEvent)
+
+// ListObserver is told about every change to the list with id, like an
+// Observer.
+type ListObserver func(id uint64, e Event)
+
+// Registry holds independent named lists. The registry lock only guards the
+// name table; every ListService keeps its own lock for its elements.
+type Registry struct {
+	sync.RWMutex
+	lists map[string]*ListService
+	ids   map[*ListService]uint64
+	// lastID is the id of the last list created; ids are never reused
+	lastID uint64
+	// maxSize is the limit for lists created without one
+	maxSize uint
+	// feedSize is the event buffer of every list; zero means no feed
+	feedSize int
+	lockWait LockWaitObserver
+	// backend is what new lists keep their elements in
+	backend backend.Kind
+	// retention is how long new lists keep their history; zero keeps none
+	retention time.Duration
+
+	observers     []RegistryObserver
+	listObservers []ListObserver
+	// propose replicates changes before they are made; nil makes them
+	// directly
+	propose Proposer
+}
+
+type RegistryConfiguration func(*Registry) error
+
+func NewRegistry(cfgs ...RegistryConfiguration) (*Registry, error) {
+	r := &Registry{
+		lists: map[string]*ListService{},
+		ids:   map[*ListService]uint64{},
+	}
+
+	for _, cfg := range cfgs {
+		err := cfg(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// WithDefaultMaxSize sets the size limit for lists created without one.
+func WithDefaultMaxSize(n uint) RegistryConfiguration {
+	return func(r *Registry) error {
+		r.maxSize = n
+		return nil
+	}
+}
+
+// WithFeedSize gives every list created from now on a change feed that
+// buffers size events.
+func WithFeedSize(size int) RegistryConfiguration {
+	return func(r *Registry) error {
+		r.feedSize = size
+		return nil
+	}
+}
+
+// WithListLockWait times the lock waits of every list created from now on.
+func WithListLockWait(o LockWaitObserver) RegistryConfiguration {
+	return func(r *Registry) error {
+		r.lockWait = o
+		return nil
+	}
+}
+
+// WithBackendKind keeps the elements of lists created from now on in a
+// backend of kind.
+func WithBackendKind(kind backend.Kind) RegistryConfiguration {
+	return func(r *Registry) error {
+		_, err := backend.New(kind)
+		if err != nil {
+			return err
+		}
+		r.backend = kind
+		return nil
+	}
+}
+
+// WithHistoryRetention makes every list created from now on keep its
+// changes for retention.
+func WithHistoryRetention(retention time.Duration) RegistryConfiguration {
+	return func(r *Registry) error {
+		r.retention = retention
+		return nil
+	}
+}
+
+// WithRegistryObserver tells o about lists being created, deleted and
+// renamed.
+func WithRegistryObserver(o RegistryObserver) RegistryConfiguration {
+	return func(r *Registry) error {
+		r.observers = append(r.observers, o)
+		return nil
+	}
+}
+
+// WithListObserver tells o about the changes to every list created from
+// now on.
+func WithListObserver(o ListObserver) RegistryConfiguration {
+	return func(r *Registry) error {
+		r.listObservers = append(r.listObservers, o)
+		return nil
+	}
+}
+
+// notify expects r to be locked.
+func (r *Registry) notify(e RegistryEvent) {
+	for _, o := range r.observers {
+		o(e)
+	}
+}
+
+// SetBackendKind changes the backend of lists created from now on; the
+// existing lists keep theirs.
+func (r *Registry) SetBackendKind(kind backend.Kind) error {
+	_, err := backend.New(kind)
+	if err != nil {
+		return err
+	}
+	r.Lock()
+	defer r.Unlock()
+	r.backend = kind
+	return nil
+}
+
+// SetDefaultMaxSize changes the limit for lists created from now on.
+func (r *Registry) SetDefaultMaxSize(n uint) {
+	r.Lock()
+	defer r.Unlock()
+	r.maxSize = n
+}
+
+// SetHistoryRetention changes how long the lists keep their changes. It
+// doesn't turn history on or off for lists that exist.
+func (r *Registry) SetHistoryRetention(retention time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+	r.retention = retention
+	for l := range r.ids {
+		if l.history != nil {
+			l.history.SetRetention(retention)
+		}
+	}
+}
+
+// Create adds an empty list. A zero maxSize uses the registry default.
+func (r *Registry) Create(name string, maxSize uint) (*ListService, error) {
+	if !validName.MatchString(name) {
+		return nil, ErrInvalidName
+	}
+	if r.propose == nil {
+		return r.create(name, maxSize)
+	}
+
+	// the default is taken here so every copy creates the same list
+	if maxSize == 0 {
+		r.RLock()
+		maxSize = r.maxSize
+		r.RUnlock()
+	}
+	_, err := r.propose(context.Background(), Command{Op: OpCreate, Name: name, MaxSize: maxSize}, nil)
+	if err != nil {
+		return nil, err
+	}
+	l, ok := r.Get(name)
+	if !ok {
+		return nil, ErrListNotFound
+	}
+	return l, nil
+}
+
+func (r *Registry) create(name string, maxSize uint) (*ListService, error) {
+	if !validName.MatchString(name) {
+		return nil, ErrInvalidName
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.lists[name]; ok {
+		return nil, ErrListExists
+	}
+	if maxSize == 0 {
+		maxSize = r.maxSize
+	}
+	id := r.lastID + 1
+	l, err := r.newList(id, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	r.lastID = id
+	r.lists[name] = l
+	r.ids[l] = id
+	r.notify(RegistryEvent{Op: OpCreate, ID: id, Name: name, MaxSize: maxSize})
+	return l, nil
+}
+
+// newList builds the list with id. It expects r to be locked.
+func (r *Registry) newList(id uint64, maxSize uint) (*ListService, error) {
+	cfgs := []ListConfiguration{
+		BootBackend(r.backend),
+		WithMaxSize(maxSize),
+	}
+	if r.feedSize > 0 {
+		cfgs = append(cfgs, WithFeed(r.feedSize))
+	}
+	if r.lockWait != nil {
+		cfgs = append(cfgs, WithLockWait(r.lockWait))
+	}
+	if r.retention > 0 {
+		cfgs = append(cfgs, WithHistory(r.retention))
+	}
+	for _, o := range r.listObservers {
+		cfgs = append(cfgs, WithObserver(func(e Event) {
+			o(id, e)
+		}))
+	}
+	if r.propose != nil {
+		cfgs = append(cfgs, WithProposer(func(ctx context.Context, cmd Command, match Match) (uint64, error) {
+			cmd.List = id
+			return r.propose(ctx, cmd, match)
+		}))
+	}
+	return New(cfgs...)
+}
+
+func (r *Registry) Get(name string) (*ListService, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	l, ok := r.lists[name]
+	return l, ok
+}
+
+func (r *Registry) Delete(name string) error {
+	if r.propose != nil {
+		_, err := r.propose(context.Background(), Command{Op: OpDelete, Name: name}, nil)
+		return err
+	}
+	return r.delete(name)
+}
+
+func (r *Registry) delete(name string) error {
+	r.Lock()
+	defer r.Unlock()
+	l, ok := r.lists[name]
+	if !ok {
+		return ErrListNotFound
+	}
+	delete(r.lists, name)
+	r.notify(RegistryEvent{Op: OpDelete, ID: r.ids[l]})
+	delete(r.ids, l)
+	return nil
+}
+
+func (r *Registry) Rename(from, to string) error {
+	if !validName.MatchString(to) {
+		return ErrInvalidName
+	}
+	if r.propose != nil {
+		_, err := r.propose(context.Background(), Command{Op: OpRename, Name: from, To: to}, nil)
+		return err
+	}
+	return r.rename(from, to)
+}
+
+func (r *Registry) rename(from, to string) error {
+	if !validName.MatchString(to) {
+		return ErrInvalidName
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	l, ok := r.lists[from]
+	if !ok {
+		return ErrListNotFound
+	}
+	if from == to {
+		return nil
+	}
+	if _, ok := r.lists[to]; ok {
+		return ErrListExists
+	}
+	delete(r.lists, from)
+	r.lists[to] = l
+	r.notify(RegistryEvent{Op: OpRename, ID: r.ids[l], Name: to})
+	return nil
+}
+
+// Named is a list held by a Registry.
+type Named struct {
+	ID   uint64
+	Name string
+	List *ListService
+}
+
+// View calls fn with every list while holding the registry lock, so the set
+// of lists can't change until fn returns. fn must not call into r.
+func (r *Registry) View(fn func(lists []Named)) {
+	r.RLock()
+	defer r.RUnlock()
+	lists := make([]Named, 0, len(r.lists))
+	for name, l := range r.lists {
+		lists = append(lists, Named{ID: r.ids[l], Name: name, List: l})
+	}
+	fn(lists)
+}
+
+// Info returns the lists sorted by name.
+func (r *Registry) Info() []ListInfo {
+	r.RLock()
+	defer r.RUnlock()
+	infos := make([]ListInfo, 0, len(r.lists))
+	for name, l := range r.lists {
+		infos = append(infos, ListInfo{
+			Name:    name,
+			Size:    l.Len(),
+			MaxSize: l.MaxSize(),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
@@ -0,0 +1,51 @@
+package list
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list/backend"
+)
+
+// Restore replaces the elements with values and sets the version, so a
+// replica can load a snapshot of another list. Subscribers aren't told;
+// they see a version jump and read the list again.
+func (l *ListService) Restore(values []int, version uint64) {
+	l.lock(context.Background())
+	defer l.Unlock()
+	l.backend = backend.Empty(l.backend)
+	backend.Append(l.backend, values)
+	l.version = version
+	if l.history != nil {
+		l.history.reset(values, version)
+	}
+}
+
+// Replay applies e, a change made to another copy of the list, keeping its
+// version. e must be the change right after the current version, or
+// ErrVersionMismatch is returned. The size limit isn't checked since the
+// change was already accepted where it was made.
+func (l *ListService) Replay(e Event) error {
+	l.lock(context.Background())
+	defer l.Unlock()
+	if e.Version != l.version+1 {
+		return ErrVersionMismatch
+	}
+
+	ok := false
+	switch e.Op {
+	case OpInsert:
+		ok = l.backend.Insert(e.Index, e.Value)
+	case OpRemove:
+		ok = l.backend.Remove(e.Index)
+	case OpSet:
+		ok = l.backend.Remove(e.Index) && l.backend.Insert(e.Index, e.Value)
+	default:
+		return fmt.Errorf("unknown operation %q", e.Op)
+	}
+	if !ok {
+		return fmt.Errorf("replaying %s at %d: %w", e.Op, e.Index, ErrIndexNotFound)
+	}
+	l.commit(context.Background(), e)
+	return nil
+}
@@ -0,0 +1,80 @@
+package list
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Query selects elements of a list. Nil filters match everything, and
+// every filter that is set has to match.
+type Query struct {
+	Value *int
+	Min   *int
+	Max   *int
+	// From and To bound the indexes looked at; To is inclusive
+	From uint
+	To   *uint
+	// Limit caps the matches returned; zero means no cap
+	Limit uint
+	// CountOnly only counts the matches, ignoring Limit
+	CountOnly bool
+}
+
+func (q Query) matches(value int) bool {
+	return (q.Value == nil || value == *q.Value) &&
+		(q.Min == nil || value >= *q.Min) &&
+		(q.Max == nil || value <= *q.Max)
+}
+
+// SearchResult holds the matches of a Query and the version of the list
+// they were read from.
+type SearchResult struct {
+	Matches []ListEntity
+	Count   uint
+	// Next is where to continue from when Limit cut the matches short
+	Next    *uint
+	Version uint64
+}
+
+// Search finds every element matching q while holding the lock once, so
+// the result is one consistent view of the list.
+func (l *ListService) Search(ctx context.Context, q Query) (res SearchResult) {
+	ctx, span := startSpan(ctx, "search")
+	defer func() {
+		span.SetAttributes(attribute.Int("list.matches", int(res.Count)))
+		endSpan(span, res.Version, nil)
+	}()
+	l.lock(ctx)
+	defer l.Unlock()
+
+	end := l.backend.Len()
+	if q.To != nil && *q.To < end {
+		end = *q.To + 1
+	}
+	res = SearchResult{
+		Matches: []ListEntity{},
+		Version: l.version,
+	}
+	l.backend.Iterate(func(i uint, value int) bool {
+		if i >= end {
+			return false
+		}
+		if i < q.From || !q.matches(value) {
+			return true
+		}
+		res.Count++
+		if q.CountOnly {
+			return true
+		}
+		if q.Limit > 0 && uint(len(res.Matches)) == q.Limit {
+			next := i
+			res.Next = &next
+			res.Count--
+			return false
+		}
+		res.Matches = append(res.Matches, ListEntity{Index: i, Value: value})
+		return true
+	})
+	return res
+}
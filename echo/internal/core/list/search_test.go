@@ -0,0 +1,67 @@
+package list
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	l, err := New(BootList())
+	if err != nil {
+		t.Fatalf("Can't create list: %v", err)
+	}
+	for i, v := range []int{4, 7, 4, 1, 9, 4} {
+		_, err := l.Insert(context.Background(), uint(i), v, nil)
+		if err != nil {
+			t.Fatalf("Can't insert: %v", err)
+		}
+	}
+	value, low, high := 4, 2, 8
+	two, four, five := uint(2), uint(4), uint(5)
+
+	indexes := func(res SearchResult) []uint {
+		out := []uint{}
+		for _, m := range res.Matches {
+			out = append(out, m.Index)
+		}
+		return out
+	}
+	tests := []struct {
+		name  string
+		q     Query
+		want  []uint
+		count uint
+		next  *uint
+	}{
+		{"value", Query{Value: &value}, []uint{0, 2, 5}, 3, nil},
+		{"range", Query{Min: &low, Max: &high}, []uint{0, 1, 2, 5}, 4, nil},
+		{"window", Query{Value: &value, From: 1, To: &four}, []uint{2}, 1, nil},
+		{"page", Query{Value: &value, Limit: 2}, []uint{0, 2}, 2, &five},
+		{"next page", Query{Value: &value, From: 5, Limit: 2}, []uint{5}, 1, nil},
+		{"count", Query{Min: &low, CountOnly: true, Limit: 1}, []uint{}, 5, nil},
+		{"empty window", Query{From: 3, To: &two}, []uint{}, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := l.Search(context.Background(), tt.q)
+			got := indexes(res)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Got %v, want %v", got, tt.want)
+				}
+			}
+			if res.Count != tt.count {
+				t.Fatalf("Got count %d, want %d", res.Count, tt.count)
+			}
+			if (res.Next == nil) != (tt.next == nil) || (res.Next != nil && *res.Next != *tt.next) {
+				t.Fatalf("Got next %v, want %v", res.Next, tt.next)
+			}
+			if res.Version != 6 {
+				t.Fatalf("Got version %d, want 6", res.Version)
+			}
+		})
+	}
+}
@@ -1,11 +1,19 @@
 package list
 
 import (
+	"context"
+	"errors"
 	"sync"
+	"time"
 
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list/backend"
 	"github.com/alipourhabibi/exercises-journal/linkedlist"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var ErrVersionMismatch = errors.New("version mismatch")
+
 type ListEntity struct {
 	Index uint `json:"index"`
 	Value int  `json:"value" validate:"required"`
@@ -13,7 +21,43 @@ type ListEntity struct {
 
 type ListService struct {
 	sync.Mutex
-	linkedlist *linkedlist.LinkedList
+	backend backend.Backend
+	// maxSize caps the number of elements; zero means unlimited
+	maxSize uint
+	// version goes up with every change to the list
+	version   uint64
+	observers []Observer
+	feed      *Feed
+	lockWait  LockWaitObserver
+	// propose replicates changes before they are made; nil makes them
+	// directly
+	propose Proposer
+	history *History
+}
+
+// Event describes one change to a list. Value is the inserted, removed or
+// new value depending on Op; Old is the value a set replaced.
+type Event struct {
+	Version uint64 `json:"version"`
+	Op      string `json:"op"`
+	Index   uint   `json:"index"`
+	Value   int    `json:"value"`
+	Old     *int   `json:"old,omitempty"`
+}
+
+// Observer is told about every change in version order. It runs while the
+// list is locked, so it must not call back into the list or block.
+type Observer func(Event)
+
+// LockWaitObserver is told how long each operation waited for the list lock.
+type LockWaitObserver func(wait time.Duration)
+
+// Match decides whether a mutation may run against the current version of
+// the list. A nil Match accepts every version.
+type Match func(version uint64) bool
+
+func (m Match) accepts(version uint64) bool {
+	return m == nil || m(version)
 }
 
 type ListConfiguration func(*ListService) error
@@ -32,40 +76,214 @@ func New(cfgs ...ListConfiguration) (*ListService, error) {
 }
 
 func WithList(l *linkedlist.LinkedList) ListConfiguration {
+	return WithBackend(backend.WrapLinkedList(l))
+}
+
+// WithBackend keeps the elements in b.
+func WithBackend(b backend.Backend) ListConfiguration {
 	return func(ls *ListService) error {
-		ls.linkedlist = l
+		ls.backend = b
 		return nil
 	}
 }
 
+// BootList starts the list empty, in a linked list.
 func BootList() ListConfiguration {
+	return WithBackend(backend.NewLinkedList())
+}
+
+// BootBackend starts the list empty, in a backend of kind.
+func BootBackend(kind backend.Kind) ListConfiguration {
 	return func(ls *ListService) error {
-		l := linkedlist.New()
-		ls.linkedlist = l
+		b, err := backend.New(kind)
+		if err != nil {
+			return err
+		}
+		ls.backend = b
 		return nil
 	}
 }
 
-func (l *ListService) Insert(index uint, value int) bool {
-	l.Lock()
+func WithObserver(o Observer) ListConfiguration {
+	return func(ls *ListService) error {
+		ls.observers = append(ls.observers, o)
+		return nil
+	}
+}
+
+// WithFeed keeps the last size changes so clients can follow the list and
+// resume where they left off.
+func WithFeed(size int) ListConfiguration {
+	return func(ls *ListService) error {
+		ls.feed = NewFeed(size)
+		ls.observers = append(ls.observers, ls.feed.Publish)
+		return nil
+	}
+}
+
+func WithLockWait(o LockWaitObserver) ListConfiguration {
+	return func(ls *ListService) error {
+		ls.lockWait = o
+		return nil
+	}
+}
+
+func WithMaxSize(n uint) ListConfiguration {
+	return func(ls *ListService) error {
+		ls.maxSize = n
+		return nil
+	}
+}
+
+// Insert returns the version of the list after the insert, or the current
+// one if it failed.
+func (l *ListService) Insert(ctx context.Context, index uint, value int, match Match) (version uint64, err error) {
+	ctx, span := startSpan(ctx, OpInsert, attribute.Int("list.index", int(index)))
+	defer func() { endSpan(span, version, err) }()
+	if l.propose != nil {
+		return l.propose(ctx, Command{Op: OpInsert, Index: index, Value: value}, match)
+	}
+	return l.insertMatch(ctx, index, value, match)
+}
+
+func (l *ListService) insertMatch(ctx context.Context, index uint, value int, match Match) (uint64, error) {
+	l.lock(ctx)
 	defer l.Unlock()
-	return l.linkedlist.Insert(index, value)
+	if !match.accepts(l.version) {
+		return l.version, ErrVersionMismatch
+	}
+	err := l.insert(index, value)
+	if err != nil {
+		return l.version, err
+	}
+	l.commit(ctx, Event{Op: OpInsert, Index: index, Value: value})
+	return l.version, nil
 }
 
-func (l *ListService) Remove(index uint) bool {
+// lock takes the list lock, timing the wait if anyone is asking: the lock
+// wait observer, or the span in ctx, which gets a child span for the wait.
+func (l *ListService) lock(ctx context.Context) {
+	traced := trace.SpanFromContext(ctx).IsRecording()
+	if l.lockWait == nil && !traced {
+		l.Lock()
+		return
+	}
+	start := time.Now()
 	l.Lock()
+	wait := time.Since(start)
+	if l.lockWait != nil {
+		l.lockWait(wait)
+	}
+	if traced {
+		_, span := tracer().Start(ctx, "list.lock", trace.WithTimestamp(start))
+		span.End(trace.WithTimestamp(start.Add(wait)))
+	}
+}
+
+// insert expects l to be locked.
+func (l *ListService) insert(index uint, value int) error {
+	if l.maxSize != 0 && l.backend.Len() >= l.maxSize {
+		return ErrListFull
+	}
+	if !l.backend.Insert(index, value) {
+		return ErrInvalidIndex
+	}
+	return nil
+}
+
+// Remove returns the version of the list after the remove, or the current
+// one if it failed.
+func (l *ListService) Remove(ctx context.Context, index uint, match Match) (version uint64, err error) {
+	ctx, span := startSpan(ctx, OpRemove, attribute.Int("list.index", int(index)))
+	defer func() { endSpan(span, version, err) }()
+	if l.propose != nil {
+		return l.propose(ctx, Command{Op: OpRemove, Index: index}, match)
+	}
+	return l.removeMatch(ctx, index, match)
+}
+
+func (l *ListService) removeMatch(ctx context.Context, index uint, match Match) (uint64, error) {
+	l.lock(ctx)
 	defer l.Unlock()
-	return l.linkedlist.Remove(index)
+	if !match.accepts(l.version) {
+		return l.version, ErrVersionMismatch
+	}
+	old, err := l.remove(index)
+	if err != nil {
+		return l.version, err
+	}
+	l.commit(ctx, Event{Op: OpRemove, Index: index, Value: old})
+	return l.version, nil
 }
 
-func (l *ListService) Find(value int) (uint, bool) {
-	l.Lock()
+// remove returns the removed value. It expects l to be locked.
+func (l *ListService) remove(index uint) (int, error) {
+	old, ok := l.backend.Get(index)
+	if !ok {
+		return 0, ErrIndexNotFound
+	}
+	l.backend.Remove(index)
+	return old, nil
+}
+
+// commit bumps the version for e and tells the observers and the
+// Collector of ctx. It expects l to be locked.
+func (l *ListService) commit(ctx context.Context, e Event) {
+	l.version++
+	e.Version = l.version
+	for _, o := range l.observers {
+		o(e)
+	}
+	Collected(ctx, e)
+}
+
+// Find returns the first index of value and the version of the list it
+// searched, which is also returned with ErrValueNotFound.
+func (l *ListService) Find(ctx context.Context, value int) (index uint, version uint64, err error) {
+	ctx, span := startSpan(ctx, "find")
+	defer func() { endSpan(span, version, err) }()
+	l.lock(ctx)
+	defer l.Unlock()
+	index, ok := l.backend.Find(value)
+	if !ok {
+		return 0, l.version, ErrValueNotFound
+	}
+	return index, l.version, nil
+}
+
+// Get returns the value at index and the version of the list it read,
+// which is also returned with ErrIndexNotFound.
+func (l *ListService) Get(ctx context.Context, index uint) (value int, version uint64, err error) {
+	ctx, span := startSpan(ctx, "get", attribute.Int("list.index", int(index)))
+	defer func() { endSpan(span, version, err) }()
+	l.lock(ctx)
 	defer l.Unlock()
-	return l.linkedlist.Find(value)
+	value, ok := l.backend.Get(index)
+	if !ok {
+		return 0, l.version, ErrIndexNotFound
+	}
+	return value, l.version, nil
 }
 
-func (l *ListService) Get(index uint) (int, bool) {
+// Len takes the lock without timing the wait, so the metrics scrapes
+// reading it don't show up in the lock wait they report.
+func (l *ListService) Len() uint {
 	l.Lock()
 	defer l.Unlock()
-	return l.linkedlist.Get(index)
+	return l.backend.Len()
+}
+
+func (l *ListService) MaxSize() uint {
+	return l.maxSize
+}
+
+func (l *ListService) Version() uint64 {
+	l.lock(context.Background())
+	defer l.Unlock()
+	return l.version
+}
+
+// Feed returns the change feed of the list, or nil if it has none.
+func (l *ListService) Feed() *Feed {
+	return l.feed
 }
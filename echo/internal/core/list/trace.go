@@ -0,0 +1,33 @@
+package list
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer is taken from the global tracer provider on every span, so list
+// operations record nothing until one is installed and follow it when it
+// is replaced.
+func tracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer("github.com/alipourhabibi/exercises-journal/echo/internal/core/list")
+}
+
+// startSpan starts the span of operation op under the span in ctx.
+func startSpan(ctx context.Context, op string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "list."+op, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends span with the version the operation left the list at,
+// marking it failed if err is set.
+func endSpan(span trace.Span, version uint64, err error) {
+	span.SetAttributes(attribute.Int64("list.version", int64(version)))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,72 @@
+package list
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list/backend"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prev)
+	})
+	l, err := New(BootBackend(backend.KindSlice))
+	if err != nil {
+		t.Fatalf("Can't create list: %v", err)
+	}
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	l.Insert(ctx, 0, 1, nil)
+	l.Get(ctx, 5)
+	parent.End()
+
+	spans := recorder.Ended()
+	names := []string{}
+	for _, s := range spans {
+		names = append(names, s.Name())
+	}
+	want := []string{"list.lock", "list.insert", "list.lock", "list.get", "request"}
+	if len(names) != len(want) {
+		t.Fatalf("Spans = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("Spans = %v, want %v", names, want)
+		}
+	}
+	lock, insert, get := spans[0], spans[1], spans[3]
+	if lock.Parent().SpanID() != insert.SpanContext().SpanID() {
+		t.Errorf("The lock wait isn't a child of the insert")
+	}
+	if insert.Parent().SpanID() != parent.SpanContext().SpanID() || insert.Status().Code == codes.Error {
+		t.Errorf("insert span = %+v", insert)
+	}
+	if get.Status().Code != codes.Error {
+		t.Errorf("get of a missing index has status %v, want an error", get.Status())
+	}
+}
+
+func TestLockWait(t *testing.T) {
+	waits := 0
+	l, err := New(BootBackend(backend.KindSlice), WithLockWait(func(time.Duration) { waits++ }))
+	if err != nil {
+		t.Fatalf("Can't create list: %v", err)
+	}
+	l.Insert(context.Background(), 0, 1, nil)
+	if waits != 1 {
+		t.Fatalf("Insert observed %d lock waits, want 1", waits)
+	}
+	// sizes are read by metrics scrapes, which aren't operations
+	if l.Len() != 1 || waits != 1 {
+		t.Errorf("Len observed %d lock waits, want none", waits-1)
+	}
+}
@@ -0,0 +1,79 @@
+package list
+
+import (
+	"context"
+	"errors"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list/backend"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ImportMode says what an import does with the elements already in a list.
+type ImportMode string
+
+const (
+	ImportAppend  ImportMode = "append"
+	ImportReplace ImportMode = "replace"
+)
+
+var ErrInvalidImportMode = errors.New("invalid import mode")
+
+// Values returns a copy of the elements and the version they were read at.
+func (l *ListService) Values() ([]int, uint64) {
+	l.lock(context.Background())
+	defer l.Unlock()
+	values := make([]int, 0, l.backend.Len())
+	l.backend.Iterate(func(_ uint, value int) bool {
+		values = append(values, value)
+		return true
+	})
+	return values, l.version
+}
+
+// Import appends values to the list, or replaces its elements with them,
+// under a single lock. Nothing is changed unless all of values fit, and
+// like a batch each step is its own version that nobody sees in between.
+func (l *ListService) Import(ctx context.Context, values []int, mode ImportMode, match Match) (version uint64, err error) {
+	ctx, span := startSpan(ctx, OpImport, attribute.Int("list.values", len(values)), attribute.String("list.mode", string(mode)))
+	defer func() { endSpan(span, version, err) }()
+	if l.propose != nil {
+		return l.propose(ctx, Command{Op: OpImport, Values: values, Mode: mode}, match)
+	}
+	return l.importValues(ctx, values, mode, match)
+}
+
+func (l *ListService) importValues(ctx context.Context, values []int, mode ImportMode, match Match) (uint64, error) {
+	l.lock(ctx)
+	defer l.Unlock()
+	if mode != ImportAppend && mode != ImportReplace {
+		return l.version, ErrInvalidImportMode
+	}
+	if !match.accepts(l.version) {
+		return l.version, ErrVersionMismatch
+	}
+
+	start := l.backend.Len()
+	if mode == ImportReplace {
+		start = 0
+	}
+	if l.maxSize > 0 && start+uint(len(values)) > l.maxSize {
+		return l.version, ErrListFull
+	}
+
+	if mode == ImportReplace {
+		old := make([]int, 0, l.backend.Len())
+		l.backend.Iterate(func(_ uint, value int) bool {
+			old = append(old, value)
+			return true
+		})
+		l.backend = backend.Empty(l.backend)
+		for _, value := range old {
+			l.commit(ctx, Event{Op: OpRemove, Index: 0, Value: value})
+		}
+	}
+	backend.Append(l.backend, values)
+	for i, value := range values {
+		l.commit(ctx, Event{Op: OpInsert, Index: start + uint(i), Value: value})
+	}
+	return l.version, nil
+}
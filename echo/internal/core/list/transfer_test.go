@@ -0,0 +1,75 @@
+package list
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list/backend"
+)
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    ImportMode
+		values  []int
+		want    []int
+		version uint64
+		err     error
+	}{
+		{"append", ImportAppend, []int{3, 4}, []int{1, 2, 3, 4}, 4, nil},
+		{"replace", ImportReplace, []int{3, 4, 5}, []int{3, 4, 5}, 7, nil},
+		{"replace with nothing", ImportReplace, nil, []int{}, 4, nil},
+		{"too many", ImportAppend, []int{3, 4, 5}, []int{1, 2}, 2, ErrListFull},
+		{"bad mode", "merge", []int{3}, []int{1, 2}, 2, ErrInvalidImportMode},
+	}
+	for _, kind := range backend.Kinds {
+		for _, tt := range tests {
+			t.Run(string(kind)+"/"+tt.name, func(t *testing.T) {
+				l, err := New(BootBackend(kind), WithMaxSize(4), WithFeed(16))
+				if err != nil {
+					t.Fatalf("Can't create list: %v", err)
+				}
+				l.Insert(context.Background(), 0, 1, nil)
+				l.Insert(context.Background(), 1, 2, nil)
+
+				version, err := l.Import(context.Background(), tt.values, tt.mode, nil)
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Import() error = %v, want %v", err, tt.err)
+				}
+				if version != tt.version {
+					t.Errorf("Import() version = %d, want %d", version, tt.version)
+				}
+				got, _ := l.Values()
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Values() = %v, want %v", got, tt.want)
+				}
+				if l.Len() != uint(len(tt.want)) {
+					t.Errorf("Len() = %d, want %d", l.Len(), len(tt.want))
+				}
+			})
+		}
+	}
+}
+
+func TestImportCollect(t *testing.T) {
+	l, err := New(BootList())
+	if err != nil {
+		t.Fatalf("Can't create list: %v", err)
+	}
+	values := make([]int, 3000)
+	ctx, events := Collect(context.Background())
+	version, err := l.Import(ctx, values, ImportAppend, nil)
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	// every change is handed back, however many one request makes
+	got := events.Events()
+	if len(got) != len(values) || got[len(got)-1].Version != version {
+		t.Errorf("Collected %d events up to version %d, want %d up to %d", len(got), got[len(got)-1].Version, len(values), version)
+	}
+	if _, err := l.Insert(context.Background(), 0, 1, nil); err != nil || len(events.Events()) != len(values) {
+		t.Errorf("A change made without ctx was collected")
+	}
+}
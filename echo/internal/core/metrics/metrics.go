@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsService collects what /metrics exposes. It outlives the http
+// servers so counters carry on across reloads.
+type MetricsService struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	lockWait prometheus.Histogram
+}
+
+type MetricsConfiguration func(*MetricsService) error
+
+func New(cfgs ...MetricsConfiguration) (*MetricsService, error) {
+	m := &MetricsService{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "echo_http_requests_total",
+			Help: "HTTP requests by route and status.",
+		}, []string{"method", "route", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "echo_http_request_duration_seconds",
+			Help:    "HTTP request latency by route and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		lockWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "echo_list_lock_wait_seconds",
+			Help: "Time list operations spent waiting for the list lock.",
+			// waits are usually far below the default buckets
+			Buckets: prometheus.ExponentialBuckets(1e-6, 4, 12),
+		}),
+	}
+	m.registry.MustRegister(
+		m.requests,
+		m.latency,
+		m.lockWait,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	for _, cfg := range cfgs {
+		err := cfg(m)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// WithLists reports the size of the global list, labelled "", and of
+// every list in r.
+func WithLists(global *list.ListService, r *list.Registry) MetricsConfiguration {
+	return func(m *MetricsService) error {
+		return m.registry.Register(&listCollector{global: global, lists: r})
+	}
+}
+
+// ObserveRequest records one finished request. route is the route pattern,
+// not the path, so parameters don't blow up the label values.
+func (m *MetricsService) ObserveRequest(method, route string, status int, d time.Duration) {
+	s := strconv.Itoa(status)
+	m.requests.WithLabelValues(method, route, s).Inc()
+	m.latency.WithLabelValues(method, route, s).Observe(d.Seconds())
+}
+
+// ObserveLockWait is a list.LockWaitObserver.
+func (m *MetricsService) ObserveLockWait(wait time.Duration) {
+	m.lockWait.Observe(wait.Seconds())
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *MetricsService) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+var listSize = prometheus.NewDesc(
+	"echo_list_size",
+	"Number of elements in a list; the global list has an empty name.",
+	[]string{"list"}, nil,
+)
+
+// listCollector reads list sizes at scrape time, so lists created or
+// deleted through the API show up without registering anything.
+type listCollector struct {
+	global *list.ListService
+	lists  *list.Registry
+}
+
+func (c *listCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- listSize
+}
+
+func (c *listCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(listSize, prometheus.GaugeValue, float64(c.global.Len()), "")
+	for _, info := range c.lists.Info() {
+		ch <- prometheus.MustNewConstMetric(listSize, prometheus.GaugeValue, float64(info.Size), info.Name)
+	}
+}
@@ -0,0 +1,172 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket: Burst requests at once, refilled at Rate per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// KeyBy tells how clients are told apart.
+type KeyBy string
+
+const (
+	KeyByIP KeyBy = "ip"
+	// KeyByAPIKey uses who the client authenticated as, falling back to the
+	// IP for requests without credentials
+	KeyByAPIKey KeyBy = "api_key"
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// LimiterService keeps a read and a write bucket per client in memory.
+type LimiterService struct {
+	sync.Mutex
+	enabled   bool
+	keyBy     KeyBy
+	read      Limit
+	write     Limit
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type LimiterConfiguration func(*LimiterService) error
+
+func New(cfgs ...LimiterConfiguration) (*LimiterService, error) {
+	l := &LimiterService{
+		now: time.Now,
+	}
+	err := l.Reload(cfgs...)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload replaces the limits and starts every client with a full bucket.
+// Nothing changes if any of cfgs fails.
+func (l *LimiterService) Reload(cfgs ...LimiterConfiguration) error {
+	next := &LimiterService{}
+	for _, cfg := range cfgs {
+		err := cfg(next)
+		if err != nil {
+			return err
+		}
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	l.enabled = next.enabled
+	l.keyBy = next.keyBy
+	l.read = next.read
+	l.write = next.write
+	l.buckets = map[string]*bucket{}
+	return nil
+}
+
+func WithEnabled(enabled bool) LimiterConfiguration {
+	return func(l *LimiterService) error {
+		l.enabled = enabled
+		return nil
+	}
+}
+
+func WithKeyBy(k KeyBy) LimiterConfiguration {
+	return func(l *LimiterService) error {
+		l.keyBy = k
+		return nil
+	}
+}
+
+func WithReadLimit(limit Limit) LimiterConfiguration {
+	return func(l *LimiterService) error {
+		l.read = limit
+		return nil
+	}
+}
+
+func WithWriteLimit(limit Limit) LimiterConfiguration {
+	return func(l *LimiterService) error {
+		l.write = limit
+		return nil
+	}
+}
+
+func (l *LimiterService) Enabled() bool {
+	l.Lock()
+	defer l.Unlock()
+	return l.enabled
+}
+
+func (l *LimiterService) KeyBy() KeyBy {
+	l.Lock()
+	defer l.Unlock()
+	return l.keyBy
+}
+
+// Allow takes a token from the read or write bucket of client. When the
+// bucket is empty it returns how long until the next token.
+func (l *LimiterService) Allow(client string, write bool) (bool, time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+	if !l.enabled {
+		return true, 0
+	}
+
+	limit, key := l.read, "r:"+client
+	if write {
+		limit, key = l.write, "w:"+client
+	}
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{
+			tokens: float64(limit.Burst),
+			last:   now,
+		}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if limit.Rate <= 0 {
+		// never refills; tell the client to come back much later
+		return false, time.Hour
+	}
+	wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets that have refilled completely, since a new bucket
+// would be the same. It runs at most once a minute and expects l to be
+// locked.
+func (l *LimiterService) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		limit := l.read
+		if key[0] == 'w' {
+			limit = l.write
+		}
+		if b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= float64(limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	l, err := New(
+		WithEnabled(true),
+		WithReadLimit(Limit{Rate: 1, Burst: 2}),
+		WithWriteLimit(Limit{Rate: 0.5, Burst: 1}),
+	)
+	if err != nil {
+		t.Fatalf("Can't create limiter: %v", err)
+	}
+	now := time.Unix(0, 0)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("a", false); !ok {
+			t.Fatalf("Read %d was limited within the burst", i)
+		}
+	}
+	ok, wait := l.Allow("a", false)
+	if ok || wait != time.Second {
+		t.Fatalf("Got %v, %v after the burst, want false, 1s", ok, wait)
+	}
+
+	// reads and writes have their own budgets, and so do clients
+	if ok, _ := l.Allow("a", true); !ok {
+		t.Fatal("Write was limited by the read budget")
+	}
+	if ok, wait := l.Allow("a", true); ok || wait != 2*time.Second {
+		t.Fatalf("Got %v, %v for a second write, want false, 2s", ok, wait)
+	}
+	if ok, _ := l.Allow("b", false); !ok {
+		t.Fatal("Another client was limited")
+	}
+
+	now = now.Add(time.Second)
+	if ok, _ := l.Allow("a", false); !ok {
+		t.Fatal("Read was limited after refilling")
+	}
+
+	err = l.Reload(WithEnabled(false))
+	if err != nil {
+		t.Fatalf("Can't reload limiter: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if ok, _ := l.Allow("a", true); !ok {
+			t.Fatal("Disabled limiter limited a request")
+		}
+	}
+}
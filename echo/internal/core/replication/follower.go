@@ -0,0 +1,291 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+)
+
+// The routes a leader serves its snapshot and log on.
+const (
+	PathSnapshot = "/replication/snapshot"
+	PathLog      = "/replication/log"
+)
+
+// maxRetry caps the wait between attempts to reach the leader.
+const maxRetry = 5 * time.Second
+
+// errDiverged means an entry didn't apply to the follower's lists, which
+// only a snapshot can fix.
+var errDiverged = errors.New("follower diverged from the leader")
+
+// Follower keeps its lists a copy of the leader's.
+type Follower struct {
+	leader *url.URL
+	token  string
+	client *http.Client
+	retry  time.Duration
+
+	list  *list.ListService
+	lists *list.Registry
+	// seq is the last entry applied, in the run of the leader the snapshot
+	// came from
+	run string
+	seq uint64
+	// ids maps the leader's list ids to the local lists and their names
+	ids   map[uint64]*list.ListService
+	names map[uint64]string
+
+	synced     chan struct{}
+	syncedOnce sync.Once
+}
+
+type FollowerConfiguration func(*Follower) error
+
+func NewFollower(cfgs ...FollowerConfiguration) (*Follower, error) {
+	f := &Follower{
+		client: &http.Client{},
+		retry:  100 * time.Millisecond,
+		synced: make(chan struct{}),
+	}
+	for _, cfg := range cfgs {
+		err := cfg(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if f.leader == nil || f.list == nil || f.lists == nil {
+		return nil, errors.New("replication: a leader and lists to follow into are required")
+	}
+	return f, nil
+}
+
+// WithLeader follows the leader serving http at rawURL, authenticating
+// with token if it isn't empty.
+func WithLeader(rawURL, token string) FollowerConfiguration {
+	return func(f *Follower) error {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("replication: leader %q is not an http url", rawURL)
+		}
+		f.leader = u
+		f.token = token
+		return nil
+	}
+}
+
+// WithTarget copies the leader's global list into global and its named
+// lists into registry, whose own lists are dropped.
+func WithTarget(global *list.ListService, registry *list.Registry) FollowerConfiguration {
+	return func(f *Follower) error {
+		f.list = global
+		f.lists = registry
+		return nil
+	}
+}
+
+// WithRetry waits d before reaching the leader again after it failed,
+// doubling every time up to a few seconds.
+func WithRetry(d time.Duration) FollowerConfiguration {
+	return func(f *Follower) error {
+		f.retry = d
+		return nil
+	}
+}
+
+// Synced is closed once the first snapshot was loaded.
+func (f *Follower) Synced() <-chan struct{} {
+	return f.synced
+}
+
+// Run follows the leader until ctx is done. It loads a snapshot first and
+// whenever the log it needs is gone or didn't apply; otherwise it resumes
+// the log where it stopped.
+func (f *Follower) Run(ctx context.Context) error {
+	resync := true
+	wait := f.retry
+	for {
+		seq := f.seq
+		var err error
+		if resync {
+			err = f.sync(ctx)
+			if err == nil {
+				resync = false
+				f.syncedOnce.Do(func() {
+					close(f.synced)
+				})
+			}
+		}
+		if err == nil {
+			err = f.follow(ctx)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, ErrLogGone) || errors.Is(err, errDiverged) {
+			resync = true
+		}
+		if f.seq != seq {
+			// the leader was reachable, so start over with short waits
+			wait = f.retry
+		}
+		slog.Warn("replication: lost the leader stream", "error", err, "seq", f.seq, "retry_in", wait)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, maxRetry)
+	}
+}
+
+// get asks the leader for path; the caller closes the body.
+func (f *Follower) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	u := f.leader.JoinPath(path)
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	}
+	res, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch res.StatusCode {
+	case http.StatusOK:
+		return res, nil
+	case http.StatusGone:
+		res.Body.Close()
+		return nil, ErrLogGone
+	}
+	res.Body.Close()
+	return nil, fmt.Errorf("replication: leader answered %s", res.Status)
+}
+
+func (f *Follower) sync(ctx context.Context) error {
+	res, err := f.get(ctx, PathSnapshot, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	var s Snapshot
+	err = json.NewDecoder(res.Body).Decode(&s)
+	if err != nil {
+		return err
+	}
+	return f.restore(s)
+}
+
+// restore replaces every local list with the ones in s.
+func (f *Follower) restore(s Snapshot) error {
+	for _, info := range f.lists.Info() {
+		f.lists.Delete(info.Name)
+	}
+	f.ids = map[uint64]*list.ListService{}
+	f.names = map[uint64]string{}
+	for _, ls := range s.Lists {
+		if ls.ID == GlobalList {
+			f.list.Restore(ls.Values, ls.Version)
+			f.ids[GlobalList] = f.list
+			continue
+		}
+		l, err := f.lists.Create(ls.Name, ls.MaxSize)
+		if err != nil {
+			return err
+		}
+		l.Restore(ls.Values, ls.Version)
+		f.ids[ls.ID] = l
+		f.names[ls.ID] = ls.Name
+	}
+	f.run = s.Run
+	f.seq = s.Seq
+	slog.Info("replication: loaded snapshot", "run", s.Run, "seq", s.Seq, "lists", len(s.Lists))
+	return nil
+}
+
+// follow applies the log from the leader until the stream ends.
+func (f *Follower) follow(ctx context.Context) error {
+	res, err := f.get(ctx, PathLog, url.Values{
+		"run":   {f.run},
+		"after": {strconv.FormatUint(f.seq, 10)},
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	dec := json.NewDecoder(res.Body)
+	for {
+		var e Entry
+		err := dec.Decode(&e)
+		if errors.Is(err, io.EOF) {
+			return errors.New("replication: the leader closed the stream")
+		}
+		if err != nil {
+			return err
+		}
+		err = f.apply(e)
+		if err != nil {
+			return fmt.Errorf("%w: entry %d: %w", errDiverged, e.Seq, err)
+		}
+	}
+}
+
+// apply makes the change of e unless the snapshot already had it.
+func (f *Follower) apply(e Entry) error {
+	if e.Seq <= f.seq {
+		return nil
+	}
+	switch e.Op {
+	case list.OpCreate:
+		l, err := f.lists.Create(e.Name, e.MaxSize)
+		if err != nil {
+			return err
+		}
+		f.ids[e.List] = l
+		f.names[e.List] = e.Name
+	case list.OpDelete:
+		if name, ok := f.names[e.List]; ok {
+			err := f.lists.Delete(name)
+			if err != nil {
+				return err
+			}
+			delete(f.ids, e.List)
+			delete(f.names, e.List)
+		}
+	case list.OpRename:
+		if name, ok := f.names[e.List]; ok {
+			err := f.lists.Rename(name, e.Name)
+			if err != nil {
+				return err
+			}
+			f.names[e.List] = e.Name
+		}
+	default:
+		l, ok := f.ids[e.List]
+		if ok && e.Version > l.Version() {
+			err := l.Replay(list.Event{Version: e.Version, Op: e.Op, Index: e.Index, Value: e.Value})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	f.seq = e.Seq
+	return nil
+}
@@ -0,0 +1,144 @@
+package replication
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+)
+
+// ListSnapshot is the state of one list at Version.
+type ListSnapshot struct {
+	ID      uint64 `json:"id"`
+	Name    string `json:"name,omitempty"`
+	MaxSize uint   `json:"max_size,omitempty"`
+	Version uint64 `json:"version"`
+	Values  []int  `json:"values"`
+}
+
+// Snapshot is every list of the leader. It contains every entry up to Seq,
+// and some after it: those are the list changes at or below the version of
+// their list in the snapshot. Run is the run of the leader Seq counts in.
+type Snapshot struct {
+	Run   string         `json:"run"`
+	Seq   uint64         `json:"seq"`
+	Lists []ListSnapshot `json:"lists"`
+}
+
+// Leader records the changes to its lists for followers.
+type Leader struct {
+	// run tells this start of the leader from earlier ones, whose sequence
+	// numbers mean nothing to its log
+	run   string
+	log   *Log
+	list  *list.ListService
+	lists *list.Registry
+}
+
+type LeaderConfiguration func(*Leader) error
+
+// NewLeader builds a leader. Its observers have to be installed on the
+// lists as they are created, so the lists themselves are given afterwards
+// with WithSource.
+func NewLeader(cfgs ...LeaderConfiguration) (*Leader, error) {
+	run := make([]byte, 8)
+	_, err := rand.Read(run)
+	if err != nil {
+		return nil, err
+	}
+	l := &Leader{run: hex.EncodeToString(run)}
+	for _, cfg := range cfgs {
+		err := cfg(l)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if l.log == nil {
+		l.log = NewLog(0)
+	}
+	return l, nil
+}
+
+// WithLogSize keeps the last size entries for followers to resume from.
+func WithLogSize(size int) LeaderConfiguration {
+	return func(l *Leader) error {
+		if size < 1 {
+			return errors.New("replication: the log size must be positive")
+		}
+		l.log = NewLog(size)
+		return nil
+	}
+}
+
+// WithSource snapshots the global list and the named lists of registry.
+// They must report their changes to Observe, ObserveGlobal and
+// ObserveRegistry.
+func WithSource(global *list.ListService, registry *list.Registry) LeaderConfiguration {
+	return func(l *Leader) error {
+		l.list = global
+		l.lists = registry
+		return nil
+	}
+}
+
+// ObserveGlobal is the list.Observer of the global list.
+func (l *Leader) ObserveGlobal(e list.Event) {
+	l.Observe(GlobalList, e)
+}
+
+// Observe is the list.ListObserver of the named lists.
+func (l *Leader) Observe(id uint64, e list.Event) {
+	l.log.Append(Entry{
+		List:    id,
+		Op:      e.Op,
+		Index:   e.Index,
+		Value:   e.Value,
+		Version: e.Version,
+	})
+}
+
+// ObserveRegistry is the list.RegistryObserver of the named lists.
+func (l *Leader) ObserveRegistry(e list.RegistryEvent) {
+	l.log.Append(Entry{
+		List:    e.ID,
+		Op:      e.Op,
+		Name:    e.Name,
+		MaxSize: e.MaxSize,
+	})
+}
+
+// Snapshot reads every list. The registry stays locked meanwhile, so no
+// list is created, deleted or renamed after Seq was taken; changes to the
+// lists can still happen and are told apart by their version.
+func (l *Leader) Snapshot() Snapshot {
+	s := Snapshot{Run: l.run}
+	l.lists.View(func(named []list.Named) {
+		s.Seq = l.log.Latest()
+		s.Lists = append(s.Lists, snapshot(GlobalList, "", l.list))
+		for _, n := range named {
+			s.Lists = append(s.Lists, snapshot(n.ID, n.Name, n.List))
+		}
+	})
+	return s
+}
+
+func snapshot(id uint64, name string, l *list.ListService) ListSnapshot {
+	values, version := l.Values()
+	return ListSnapshot{
+		ID:      id,
+		Name:    name,
+		MaxSize: l.MaxSize(),
+		Version: version,
+		Values:  values,
+	}
+}
+
+// Resume follows the log after seq of run; see Log.Resume. Sequence
+// numbers of another run fail with ErrLogGone.
+func (l *Leader) Resume(run string, seq uint64) (*Subscription, error) {
+	if run != l.run {
+		return nil, ErrLogGone
+	}
+	return l.log.Resume(seq)
+}
@@ -0,0 +1,142 @@
+// Package replication keeps follower instances in step with a leader. The
+// leader records every change to its lists in an ordered log; followers
+// load a snapshot of the lists and then apply the log from where the
+// snapshot left off.
+package replication
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrLogGone means the entries after the requested sequence number are no
+// longer kept, so the follower has to load a snapshot again.
+var ErrLogGone = errors.New("replication log entries are no longer kept")
+
+// subscriberBuffer is how far a follower may fall behind the live log
+// before its stream is cut; it can resume from the kept entries.
+const subscriberBuffer = 256
+
+// GlobalList is the id of the unnamed list in entries and snapshots; named
+// lists use their registry id.
+const GlobalList uint64 = 0
+
+// Entry is one change in the log. List changes carry the list id, index,
+// value and the list version; create, delete and rename carry the name and
+// size limit.
+type Entry struct {
+	Seq     uint64 `json:"seq"`
+	List    uint64 `json:"list"`
+	Op      string `json:"op"`
+	Index   uint   `json:"index,omitempty"`
+	Value   int    `json:"value,omitempty"`
+	Version uint64 `json:"version,omitempty"`
+	Name    string `json:"name,omitempty"`
+	MaxSize uint   `json:"max_size,omitempty"`
+}
+
+// Log numbers entries in the order they happen, keeps the latest ones in a
+// bounded buffer and streams them to subscribers.
+type Log struct {
+	sync.Mutex
+	buffer []Entry
+	// next is where the following entry goes once the buffer is full
+	next        int
+	latest      uint64
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives the entries appended after it was created. C is
+// closed when the subscription is closed or couldn't keep up.
+type Subscription struct {
+	// Replay holds the kept entries the subscriber asked to resume from
+	Replay []Entry
+	C      <-chan Entry
+	c      chan Entry
+	log    *Log
+}
+
+func NewLog(size int) *Log {
+	if size < 1 {
+		size = 1
+	}
+	return &Log{
+		buffer:      make([]Entry, 0, size),
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// Append numbers e, keeps it and sends it to every subscriber. It never
+// blocks: subscribers that are too far behind are dropped instead.
+func (l *Log) Append(e Entry) {
+	l.Lock()
+	defer l.Unlock()
+	l.latest++
+	e.Seq = l.latest
+	if len(l.buffer) < cap(l.buffer) {
+		l.buffer = append(l.buffer, e)
+	} else {
+		l.buffer[l.next] = e
+		l.next = (l.next + 1) % len(l.buffer)
+	}
+
+	for s := range l.subscribers {
+		select {
+		case s.c <- e:
+		default:
+			l.drop(s)
+		}
+	}
+}
+
+// Latest returns the sequence number of the last entry.
+func (l *Log) Latest() uint64 {
+	l.Lock()
+	defer l.Unlock()
+	return l.latest
+}
+
+// Resume follows the log starting with the entry after seq. It fails with
+// ErrLogGone if some of those entries are no longer kept.
+func (l *Log) Resume(seq uint64) (*Subscription, error) {
+	l.Lock()
+	defer l.Unlock()
+	if seq > l.latest {
+		return nil, ErrLogGone
+	}
+
+	replay := []Entry{}
+	for i := range l.buffer {
+		e := l.buffer[(l.next+i)%len(l.buffer)]
+		if e.Seq > seq {
+			replay = append(replay, e)
+		}
+	}
+	if seq < l.latest && (len(replay) == 0 || replay[0].Seq != seq+1) {
+		return nil, ErrLogGone
+	}
+
+	c := make(chan Entry, subscriberBuffer)
+	s := &Subscription{
+		Replay: replay,
+		C:      c,
+		c:      c,
+		log:    l,
+	}
+	l.subscribers[s] = struct{}{}
+	return s, nil
+}
+
+// drop expects l to be locked.
+func (l *Log) drop(s *Subscription) {
+	if _, ok := l.subscribers[s]; ok {
+		delete(l.subscribers, s)
+		close(s.c)
+	}
+}
+
+func (s *Subscription) Close() {
+	s.log.Lock()
+	defer s.log.Unlock()
+	s.log.drop(s)
+}
@@ -0,0 +1,265 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+)
+
+// newLeader builds a leader with its lists wired up like serve does.
+func newLeader(t *testing.T, logSize int) (*Leader, *list.ListService, *list.Registry) {
+	leader, err := NewLeader(WithLogSize(logSize))
+	if err != nil {
+		t.Fatalf("Can't create leader: %v", err)
+	}
+	l, err := list.New(list.BootList(), list.WithObserver(leader.ObserveGlobal))
+	if err != nil {
+		t.Fatalf("Can't create list: %v", err)
+	}
+	r, err := list.NewRegistry(
+		list.WithListObserver(leader.Observe),
+		list.WithRegistryObserver(leader.ObserveRegistry),
+	)
+	if err != nil {
+		t.Fatalf("Can't create registry: %v", err)
+	}
+	err = WithSource(l, r)(leader)
+	if err != nil {
+		t.Fatalf("Can't set the leader source: %v", err)
+	}
+	return leader, l, r
+}
+
+func newFollower(t *testing.T) *Follower {
+	l, err := list.New(list.BootList())
+	if err != nil {
+		t.Fatalf("Can't create list: %v", err)
+	}
+	r, err := list.NewRegistry()
+	if err != nil {
+		t.Fatalf("Can't create registry: %v", err)
+	}
+	// stale lists of the follower are dropped by the snapshot
+	r.Create("stale", 0)
+	f, err := NewFollower(WithLeader("http://127.0.0.1:1", ""), WithTarget(l, r))
+	if err != nil {
+		t.Fatalf("Can't create follower: %v", err)
+	}
+	return f
+}
+
+// state is every list by name with its version and values.
+func state(l *list.ListService, r *list.Registry) map[string]ListSnapshot {
+	out := map[string]ListSnapshot{}
+	values, version := l.Values()
+	out[""] = ListSnapshot{Version: version, Values: values}
+	for _, info := range r.Info() {
+		named, _ := r.Get(info.Name)
+		values, version := named.Values()
+		out[info.Name] = ListSnapshot{Version: version, Values: values}
+	}
+	return out
+}
+
+// catchUp applies every kept entry after f.seq, like Follower.follow.
+func catchUp(t *testing.T, leader *Leader, f *Follower) {
+	sub, err := leader.Resume(f.run, f.seq)
+	if err != nil {
+		t.Fatalf("Can't resume after %d: %v", f.seq, err)
+	}
+	defer sub.Close()
+	for _, e := range sub.Replay {
+		err := f.apply(e)
+		if err != nil {
+			t.Fatalf("Can't apply %+v: %v", e, err)
+		}
+	}
+}
+
+func TestFollowerCatchesUp(t *testing.T) {
+	leader, l, r := newLeader(t, 100)
+	f := newFollower(t)
+
+	l.Insert(context.Background(), 0, 1, nil)
+	a, _ := r.Create("a", 5)
+	a.Insert(context.Background(), 0, 2, nil)
+	err := f.restore(leader.Snapshot())
+	if err != nil {
+		t.Fatalf("Can't restore: %v", err)
+	}
+
+	// a change made while the snapshot was read is in both the snapshot
+	// and the log after its seq, and must only be applied once
+	seq := leader.Snapshot().Seq
+	a.Insert(context.Background(), 1, 3, nil)
+	s := leader.Snapshot()
+	s.Seq = seq
+	err = f.restore(s)
+	if err != nil {
+		t.Fatalf("Can't restore: %v", err)
+	}
+
+	r.Rename("a", "b")
+	b, _ := r.Get("b")
+	b.Batch(context.Background(), []list.Operation{{Op: list.OpSet, Index: 0, Value: 9}, {Op: list.OpRemove, Index: 1}}, nil)
+	c, _ := r.Create("c", 0)
+	c.Insert(context.Background(), 0, 4, nil)
+	r.Delete("c")
+	l.Import(context.Background(), []int{5, 6}, list.ImportReplace, nil)
+	catchUp(t, leader, f)
+
+	got, want := state(f.list, f.lists), state(l, r)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Follower has %+v, want %+v", got, want)
+	}
+}
+
+func TestFollowerDiverged(t *testing.T) {
+	_, l, _ := newLeader(t, 100)
+	f := newFollower(t)
+	err := f.restore(Snapshot{Lists: []ListSnapshot{{ID: GlobalList}}})
+	if err != nil {
+		t.Fatalf("Can't restore: %v", err)
+	}
+	l.Insert(context.Background(), 0, 1, nil)
+
+	// version 2 can't follow version 0
+	err = f.apply(Entry{Seq: 1, List: GlobalList, Op: list.OpInsert, Version: 2})
+	if !errors.Is(err, list.ErrVersionMismatch) {
+		t.Fatalf("Applying a version out of order should fail, got %v", err)
+	}
+}
+
+func TestLogResume(t *testing.T) {
+	log := NewLog(3)
+	for i := 0; i < 5; i++ {
+		log.Append(Entry{Op: list.OpInsert, Value: i})
+	}
+
+	sub, err := log.Resume(2)
+	if err != nil {
+		t.Fatalf("Should be able to resume after entry 2: %v", err)
+	}
+	if len(sub.Replay) != 3 || sub.Replay[0].Seq != 3 || sub.Replay[2].Seq != 5 {
+		t.Fatalf("Replay should be entries 3 to 5 but is %+v", sub.Replay)
+	}
+	log.Append(Entry{Op: list.OpRemove})
+	if e := <-sub.C; e.Seq != 6 || e.Op != list.OpRemove {
+		t.Fatalf("Live entry should be remove 6 but is %+v", e)
+	}
+	sub.Close()
+
+	for _, seq := range []uint64{1, 7} {
+		_, err = log.Resume(seq)
+		if !errors.Is(err, ErrLogGone) {
+			t.Fatalf("Resuming after %d should fail, got %v", seq, err)
+		}
+	}
+}
+
+// serveLeader serves the snapshot and the log of whichever leader current
+// returns, like the http server of a leader does.
+func serveLeader(current func() *Leader) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathSnapshot, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(current().Snapshot())
+	})
+	mux.HandleFunc(PathLog, func(w http.ResponseWriter, r *http.Request) {
+		after, _ := strconv.ParseUint(r.URL.Query().Get("after"), 10, 64)
+		sub, err := current().Resume(r.URL.Query().Get("run"), after)
+		if err != nil {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		defer sub.Close()
+		enc := json.NewEncoder(w)
+		for _, e := range sub.Replay {
+			enc.Encode(e)
+		}
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case e, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				enc.Encode(e)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestFollowerLeaderRestart(t *testing.T) {
+	var mu sync.Mutex
+	leader, l, r := newLeader(t, 100)
+	current := func() *Leader {
+		mu.Lock()
+		defer mu.Unlock()
+		return leader
+	}
+	srv := serveLeader(current)
+	defer srv.Close()
+
+	fl, err := list.New(list.BootList())
+	if err != nil {
+		t.Fatalf("Can't create list: %v", err)
+	}
+	fr, err := list.NewRegistry()
+	if err != nil {
+		t.Fatalf("Can't create registry: %v", err)
+	}
+	f, err := NewFollower(WithLeader(srv.URL, ""), WithTarget(fl, fr), WithRetry(time.Millisecond))
+	if err != nil {
+		t.Fatalf("Can't create follower: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		f.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// waitSynced waits for the follower to hold what l and r hold
+	waitSynced := func() {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !reflect.DeepEqual(state(fl, fr), state(l, r)) {
+			if time.Now().After(deadline) {
+				t.Fatalf("Follower has %+v, want %+v", state(fl, fr), state(l, r))
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	for i := 1; i <= 3; i++ {
+		l.Insert(context.Background(), 0, i, nil)
+	}
+	waitSynced()
+
+	// the restarted leader starts numbering over and gets past the
+	// follower's seq with other changes; resuming there would skip them
+	mu.Lock()
+	leader, l, r = newLeader(t, 100)
+	mu.Unlock()
+	for i := 7; i <= 10; i++ {
+		l.Insert(context.Background(), 0, i, nil)
+	}
+	srv.CloseClientConnections()
+	waitSynced()
+}
@@ -0,0 +1,174 @@
+// Package tracing sends OpenTelemetry spans to an exporter, propagates
+// W3C trace context and puts trace ids on log lines.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+type Exporter string
+
+const (
+	// ExporterStdout writes spans as JSON, one per line
+	ExporterStdout Exporter = "stdout"
+	// ExporterOTLP sends spans to a collector
+	ExporterOTLP Exporter = "otlp"
+)
+
+type Protocol string
+
+const (
+	ProtocolGRPC Protocol = "grpc"
+	ProtocolHTTP Protocol = "http"
+)
+
+// TracingService owns the tracer provider installed as the global one.
+type TracingService struct {
+	exporter    Exporter
+	writer      io.Writer
+	endpoint    string
+	protocol    Protocol
+	insecure    bool
+	sampleRatio float64
+	serviceName string
+	provider    *sdktrace.TracerProvider
+}
+
+type TracingConfiguration func(*TracingService) error
+
+// New installs a tracer provider exporting spans as configured, and the
+// W3C trace-context and baggage propagators. Call Shutdown to flush the
+// spans still buffered.
+func New(cfgs ...TracingConfiguration) (*TracingService, error) {
+	t := &TracingService{
+		exporter:    ExporterStdout,
+		writer:      os.Stdout,
+		protocol:    ProtocolGRPC,
+		sampleRatio: 1,
+		serviceName: "echo",
+	}
+	for _, cfg := range cfgs {
+		err := cfg(t)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	exporter, err := t.newExporter()
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(t.serviceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+	t.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// follow the caller's decision so a trace isn't cut in half
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(t.sampleRatio))),
+	)
+	otel.SetTracerProvider(t.provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return t, nil
+}
+
+func (t *TracingService) newExporter() (sdktrace.SpanExporter, error) {
+	ctx := context.Background()
+	switch t.exporter {
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(t.writer))
+	case ExporterOTLP:
+		if t.protocol == ProtocolHTTP {
+			opts := []otlptracehttp.Option{}
+			if t.endpoint != "" {
+				opts = append(opts, otlptracehttp.WithEndpoint(t.endpoint))
+			}
+			if t.insecure {
+				opts = append(opts, otlptracehttp.WithInsecure())
+			}
+			return otlptracehttp.New(ctx, opts...)
+		}
+		opts := []otlptracegrpc.Option{}
+		if t.endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(t.endpoint))
+		}
+		if t.insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	}
+	return nil, fmt.Errorf("tracing: unknown exporter %q", t.exporter)
+}
+
+func WithExporter(e Exporter) TracingConfiguration {
+	return func(t *TracingService) error {
+		t.exporter = e
+		return nil
+	}
+}
+
+// WithWriter is where the stdout exporter writes.
+func WithWriter(w io.Writer) TracingConfiguration {
+	return func(t *TracingService) error {
+		t.writer = w
+		return nil
+	}
+}
+
+// WithOTLP sends spans to the collector at endpoint, a host:port, over
+// protocol. An empty endpoint leaves it to the OTEL_EXPORTER_OTLP_*
+// variables, or localhost.
+func WithOTLP(endpoint string, protocol Protocol, insecure bool) TracingConfiguration {
+	return func(t *TracingService) error {
+		if protocol != ProtocolGRPC && protocol != ProtocolHTTP {
+			return fmt.Errorf("tracing: unknown protocol %q", protocol)
+		}
+		t.endpoint = endpoint
+		t.protocol = protocol
+		t.insecure = insecure
+		return nil
+	}
+}
+
+// WithSampleRatio samples that share of the traces started here. Traces
+// continued from a caller are sampled if the caller sampled them.
+func WithSampleRatio(ratio float64) TracingConfiguration {
+	return func(t *TracingService) error {
+		if ratio < 0 || ratio > 1 {
+			return fmt.Errorf("tracing: sample ratio %g isn't between 0 and 1", ratio)
+		}
+		t.sampleRatio = ratio
+		return nil
+	}
+}
+
+func WithServiceName(name string) TracingConfiguration {
+	return func(t *TracingService) error {
+		t.serviceName = name
+		return nil
+	}
+}
+
+// Shutdown exports the spans still buffered and stops the provider.
+func (t *TracingService) Shutdown(ctx context.Context) error {
+	return t.provider.Shutdown(ctx)
+}
@@ -0,0 +1,481 @@
+// Package webhook sends the changes to the lists to the URLs subscribed to
+// them, signed, retried and set aside once they keep failing.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+)
+
+var (
+	ErrNotFound     = errors.New("no such webhook")
+	ErrInvalidURL   = errors.New("webhook url must be an absolute http or https url")
+	ErrInvalidEvent = errors.New("unknown webhook event")
+	ErrLocalTarget  = errors.New("webhook url must not point at a loopback or link-local address")
+)
+
+// Headers of a delivery. The signature is the hex HMAC-SHA256, keyed with
+// the secret of the subscription, of the timestamp, a '.' and the body.
+const (
+	HeaderSignature = "X-Echo-Signature"
+	HeaderTimestamp = "X-Echo-Timestamp"
+	HeaderDelivery  = "X-Echo-Delivery"
+	HeaderEvent     = "X-Echo-Event"
+)
+
+// Events are what a subscription can filter on.
+var Events = []string{list.OpInsert, list.OpRemove, list.OpSet}
+
+// Subscription sends the changes whose op is in Events, or every change if
+// Events is empty, to URL.
+type Subscription struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret signs the deliveries; it is only shown when the subscription
+	// is created
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *Subscription) wants(op string) bool {
+	return len(s.Events) == 0 || slices.Contains(s.Events, op)
+}
+
+// Payload is the body of a delivery.
+type Payload struct {
+	ID           string `json:"id"`
+	Subscription string `json:"subscription"`
+	// List is the name of the changed list; empty for the global one
+	List  string     `json:"list,omitempty"`
+	Event list.Event `json:"event"`
+}
+
+// DeadLetter is a delivery that failed every attempt.
+type DeadLetter struct {
+	Payload   Payload   `json:"payload"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+type delivery struct {
+	id   string
+	list uint64
+	e    list.Event
+}
+
+// subscriber delivers to one subscription in order, so a failing receiver
+// only holds up its own deliveries.
+type subscriber struct {
+	Subscription
+	queue  chan delivery
+	cancel context.CancelFunc
+}
+
+// Dispatcher holds the subscriptions and delivers the changes it observes
+// to them.
+type Dispatcher struct {
+	sync.Mutex
+	subs        map[string]*subscriber
+	deadLetters []DeadLetter
+	maxDead     int
+	seq         uint64
+
+	client  *http.Client
+	timeout time.Duration
+	// allowLocal lets webhooks target loopback and link-local addresses,
+	// which otherwise reach the server itself and the metadata services of
+	// clouds
+	allowLocal  bool
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	queueSize   int
+	// names resolves the id of a named list; nil only knows the global one
+	names func(id uint64) (string, bool)
+
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+type DispatcherConfiguration func(*Dispatcher) error
+
+func New(cfgs ...DispatcherConfiguration) (*Dispatcher, error) {
+	ctx, stop := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		subs:        map[string]*subscriber{},
+		maxDead:     1000,
+		timeout:     5 * time.Second,
+		maxAttempts: 5,
+		backoff:     time.Second,
+		maxBackoff:  time.Minute,
+		queueSize:   1024,
+		ctx:         ctx,
+		stop:        stop,
+	}
+
+	for _, cfg := range cfgs {
+		err := cfg(d)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+	}
+	d.client = d.newClient()
+
+	return d, nil
+}
+
+// newClient sends deliveries. Unless local targets are allowed it refuses
+// to connect to them, whatever name or redirect led there.
+func (d *Dispatcher) newClient() *http.Client {
+	if d.allowLocal {
+		return &http.Client{Timeout: d.timeout}
+	}
+	dialer := &net.Dialer{
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isLocal(ip) {
+				return ErrLocalTarget
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: d.timeout, Transport: transport}
+}
+
+func isLocal(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+// isLocalHost reports whether the host of a url is known to be local
+// without resolving it.
+func isLocalHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && isLocal(ip)
+}
+
+// WithRetries makes up to attempts tries per delivery, waiting backoff
+// after the first failure and twice as long after each one after that, up
+// to maxBackoff.
+func WithRetries(attempts int, backoff, maxBackoff time.Duration) DispatcherConfiguration {
+	return func(d *Dispatcher) error {
+		if attempts < 1 || backoff <= 0 || maxBackoff < backoff {
+			return fmt.Errorf("webhook: invalid retries %d, %s, %s", attempts, backoff, maxBackoff)
+		}
+		d.maxAttempts = attempts
+		d.backoff = backoff
+		d.maxBackoff = maxBackoff
+		return nil
+	}
+}
+
+// WithTimeout gives up on an attempt after timeout.
+func WithTimeout(timeout time.Duration) DispatcherConfiguration {
+	return func(d *Dispatcher) error {
+		d.timeout = timeout
+		return nil
+	}
+}
+
+// WithLocalTargets lets webhooks be sent to loopback and link-local
+// addresses.
+func WithLocalTargets(allow bool) DispatcherConfiguration {
+	return func(d *Dispatcher) error {
+		d.allowLocal = allow
+		return nil
+	}
+}
+
+// WithQueueSize lets n deliveries per subscription wait to be sent. Changes
+// past that go straight to the dead letters.
+func WithQueueSize(n int) DispatcherConfiguration {
+	return func(d *Dispatcher) error {
+		if n < 1 {
+			return fmt.Errorf("webhook: queue size %d isn't positive", n)
+		}
+		d.queueSize = n
+		return nil
+	}
+}
+
+// WithDeadLetters keeps the last n failed deliveries.
+func WithDeadLetters(n int) DispatcherConfiguration {
+	return func(d *Dispatcher) error {
+		if n < 0 {
+			return fmt.Errorf("webhook: dead letters %d is negative", n)
+		}
+		d.maxDead = n
+		return nil
+	}
+}
+
+// WithNames names the lists in the payloads with names, like
+// list.Registry.Name.
+func WithNames(names func(id uint64) (string, bool)) DispatcherConfiguration {
+	return func(d *Dispatcher) error {
+		d.names = names
+		return nil
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	crand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Subscribe starts sending the changes with an op in events to rawURL. An
+// empty secret gets a random one, returned in the subscription.
+func (d *Dispatcher) Subscribe(rawURL string, events []string, secret string) (Subscription, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Subscription{}, ErrInvalidURL
+	}
+	if !d.allowLocal && isLocalHost(u.Hostname()) {
+		return Subscription{}, ErrLocalTarget
+	}
+	for _, e := range events {
+		if !slices.Contains(Events, e) {
+			return Subscription{}, fmt.Errorf("%w %q", ErrInvalidEvent, e)
+		}
+	}
+	if secret == "" {
+		secret = randomHex(32)
+	}
+
+	ctx, cancel := context.WithCancel(d.ctx)
+	s := &subscriber{
+		Subscription: Subscription{
+			ID:        randomHex(8),
+			URL:       rawURL,
+			Events:    append([]string{}, events...),
+			Secret:    secret,
+			CreatedAt: time.Now().UTC(),
+		},
+		queue:  make(chan delivery, d.queueSize),
+		cancel: cancel,
+	}
+	d.Lock()
+	d.subs[s.ID] = s
+	d.Unlock()
+	d.wg.Add(1)
+	go d.run(ctx, s)
+	return s.Subscription, nil
+}
+
+// Subscriptions returns every subscription, oldest first, without secrets.
+func (d *Dispatcher) Subscriptions() []Subscription {
+	d.Lock()
+	defer d.Unlock()
+	subs := make([]Subscription, 0, len(d.subs))
+	for _, s := range d.subs {
+		sub := s.Subscription
+		sub.Secret = ""
+		subs = append(subs, sub)
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].CreatedAt.Before(subs[j].CreatedAt)
+	})
+	return subs
+}
+
+// Unsubscribe stops the deliveries to the subscription with id and drops
+// its dead letters.
+func (d *Dispatcher) Unsubscribe(id string) error {
+	d.Lock()
+	defer d.Unlock()
+	s, ok := d.subs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	s.cancel()
+	delete(d.subs, id)
+	d.deadLetters = slices.DeleteFunc(d.deadLetters, func(l DeadLetter) bool {
+		return l.Payload.Subscription == id
+	})
+	return nil
+}
+
+// DeadLetters returns the failed deliveries of the subscription with id,
+// oldest first.
+func (d *Dispatcher) DeadLetters(id string) ([]DeadLetter, error) {
+	d.Lock()
+	defer d.Unlock()
+	if _, ok := d.subs[id]; !ok {
+		return nil, ErrNotFound
+	}
+	letters := []DeadLetter{}
+	for _, l := range d.deadLetters {
+		if l.Payload.Subscription == id {
+			letters = append(letters, l)
+		}
+	}
+	return letters, nil
+}
+
+// Observe queues a change to the list with id for the subscriptions that
+// want it. It is a list.ListObserver, so it runs under the lock of the
+// list and never waits on a receiver.
+func (d *Dispatcher) Observe(id uint64, e list.Event) {
+	d.Lock()
+	defer d.Unlock()
+	for _, s := range d.subs {
+		if !s.wants(e.Op) {
+			continue
+		}
+		d.seq++
+		dl := delivery{id: strconv.FormatUint(d.seq, 10), list: id, e: e}
+		select {
+		case s.queue <- dl:
+		default:
+			d.bury(s.payload(dl, ""), 0, "queue full")
+		}
+	}
+}
+
+func (s *subscriber) payload(dl delivery, name string) Payload {
+	return Payload{ID: dl.id, Subscription: s.ID, List: name, Event: dl.e}
+}
+
+// bury keeps p as a dead letter. It expects d to be locked.
+func (d *Dispatcher) bury(p Payload, attempts int, reason string) {
+	slog.Warn("webhook delivery failed", "subscription", p.Subscription, "delivery", p.ID, "attempts", attempts, "error", reason)
+	if d.maxDead == 0 {
+		return
+	}
+	if len(d.deadLetters) == d.maxDead {
+		d.deadLetters = d.deadLetters[1:]
+	}
+	d.deadLetters = append(d.deadLetters, DeadLetter{
+		Payload:   p,
+		Attempts:  attempts,
+		LastError: reason,
+		FailedAt:  time.Now().UTC(),
+	})
+}
+
+// run delivers the queue of s until ctx is done.
+func (d *Dispatcher) run(ctx context.Context, s *subscriber) {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case dl := <-s.queue:
+			name := ""
+			if dl.list != 0 {
+				var ok bool
+				if d.names != nil {
+					name, ok = d.names(dl.list)
+				}
+				if !ok {
+					slog.Debug("list of webhook delivery is gone", "subscription", s.ID, "delivery", dl.id)
+					continue
+				}
+			}
+			d.deliver(ctx, s, s.payload(dl, name))
+		}
+	}
+}
+
+// deliver sends p until the receiver takes it or the attempts run out.
+func (d *Dispatcher) deliver(ctx context.Context, s *subscriber, p Payload) {
+	body, _ := json.Marshal(p)
+	wait := d.backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = d.send(ctx, s, p, body)
+		if err == nil {
+			return
+		}
+		if attempt == d.maxAttempts {
+			break
+		}
+		// wait between half and all of the backoff so receivers coming
+		// back aren't hit by every retry at once
+		timer := time.NewTimer(wait/2 + rand.N(wait/2+1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		wait = min(2*wait, d.maxBackoff)
+	}
+	d.Lock()
+	defer d.Unlock()
+	// an unsubscribed receiver has no dead letters
+	if _, ok := d.subs[s.ID]; ok {
+		d.bury(p, d.maxAttempts, err.Error())
+	}
+}
+
+// Sign returns the signature of a delivery of body sent at timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *Dispatcher) send(ctx context.Context, s *subscriber, p Payload, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(s.Secret, timestamp, body))
+	req.Header.Set(HeaderDelivery, p.ID)
+	req.Header.Set(HeaderEvent, p.Event.Op)
+	res, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("receiver answered %s", res.Status)
+	}
+	return nil
+}
+
+// Close stops every delivery and waits for the senders to return.
+func (d *Dispatcher) Close() {
+	d.stop()
+	d.wg.Wait()
+}
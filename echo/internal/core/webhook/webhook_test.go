@@ -0,0 +1,193 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+)
+
+// receiver records the deliveries it is sent, failing the first fail of
+// them.
+type receiver struct {
+	sync.Mutex
+	t        *testing.T
+	secret   string
+	fail     int
+	attempts int
+	payloads []Payload
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.Lock()
+	defer r.Unlock()
+	r.attempts++
+	body, _ := io.ReadAll(req.Body)
+	want := Sign(r.secret, req.Header.Get(HeaderTimestamp), body)
+	if req.Header.Get(HeaderSignature) != want {
+		r.t.Errorf("Signature = %q, want %q", req.Header.Get(HeaderSignature), want)
+	}
+	if r.attempts <= r.fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var p Payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		r.t.Errorf("Invalid payload %s: %v", body, err)
+	}
+	if req.Header.Get(HeaderDelivery) != p.ID || req.Header.Get(HeaderEvent) != p.Event.Op {
+		r.t.Errorf("Headers %v don't match payload %+v", req.Header, p)
+	}
+	r.payloads = append(r.payloads, p)
+}
+
+func (r *receiver) received() []Payload {
+	r.Lock()
+	defer r.Unlock()
+	return append([]Payload(nil), r.payloads...)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func newDispatcher(t *testing.T, cfgs ...DispatcherConfiguration) *Dispatcher {
+	cfgs = append([]DispatcherConfiguration{
+		WithRetries(3, time.Millisecond, 4*time.Millisecond),
+		// the receivers run on this host
+		WithLocalTargets(true),
+		WithNames(func(id uint64) (string, bool) {
+			return "named", id == 1
+		}),
+	}, cfgs...)
+	d, err := New(cfgs...)
+	if err != nil {
+		t.Fatalf("Can't create dispatcher: %v", err)
+	}
+	t.Cleanup(d.Close)
+	return d
+}
+
+func TestDeliver(t *testing.T) {
+	d := newDispatcher(t)
+	r := &receiver{t: t, secret: "s3cret", fail: 2}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	sub, err := d.Subscribe(srv.URL, []string{list.OpInsert, list.OpSet}, "s3cret")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	old := 1
+	d.Observe(0, list.Event{Version: 1, Op: list.OpInsert, Value: 1})
+	d.Observe(0, list.Event{Version: 2, Op: list.OpRemove, Value: 1})
+	d.Observe(1, list.Event{Version: 1, Op: list.OpSet, Value: 2, Old: &old})
+	// a list deleted before the delivery was sent is skipped
+	d.Observe(2, list.Event{Version: 1, Op: list.OpInsert, Value: 3})
+
+	waitFor(t, func() bool { return len(r.received()) == 2 })
+	got := r.received()
+	if got[0].Subscription != sub.ID || got[0].List != "" || got[0].Event.Version != 1 {
+		t.Errorf("First delivery = %+v", got[0])
+	}
+	if got[1].List != "named" || got[1].Event.Op != list.OpSet || *got[1].Event.Old != 1 {
+		t.Errorf("Second delivery = %+v", got[1])
+	}
+	if letters, _ := d.DeadLetters(sub.ID); len(letters) != 0 {
+		t.Errorf("DeadLetters() = %+v, want none after retries succeeded", letters)
+	}
+
+	subs := d.Subscriptions()
+	if len(subs) != 1 || subs[0].Secret != "" {
+		t.Errorf("Subscriptions() = %+v, want one without its secret", subs)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	d := newDispatcher(t)
+	r := &receiver{t: t, fail: 100}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	sub, err := d.Subscribe(srv.URL, nil, "")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if sub.Secret == "" {
+		t.Fatalf("Subscribe() without a secret should make one")
+	}
+	r.secret = sub.Secret
+	d.Observe(0, list.Event{Version: 1, Op: list.OpRemove, Value: 4})
+
+	var letters []DeadLetter
+	waitFor(t, func() bool {
+		letters, _ = d.DeadLetters(sub.ID)
+		return len(letters) == 1
+	})
+	if letters[0].Attempts != 3 || letters[0].Payload.Event.Value != 4 || letters[0].LastError == "" {
+		t.Errorf("DeadLetters() = %+v", letters)
+	}
+	r.Lock()
+	if r.attempts != 3 {
+		t.Errorf("Receiver got %d attempts, want 3", r.attempts)
+	}
+	r.Unlock()
+
+	err = d.Unsubscribe(sub.ID)
+	if err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+	if _, err := d.DeadLetters(sub.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeadLetters() after Unsubscribe() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestSubscribeInvalid(t *testing.T) {
+	d := newDispatcher(t)
+	if _, err := d.Subscribe("ftp://example.com", nil, ""); !errors.Is(err, ErrInvalidURL) {
+		t.Errorf("Subscribe() error = %v, want %v", err, ErrInvalidURL)
+	}
+	if _, err := d.Subscribe("http://example.com", []string{"rename"}, ""); !errors.Is(err, ErrInvalidEvent) {
+		t.Errorf("Subscribe() error = %v, want %v", err, ErrInvalidEvent)
+	}
+	if err := d.Unsubscribe("nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Unsubscribe() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestLocalTargets(t *testing.T) {
+	d := newDispatcher(t, WithLocalTargets(false))
+	for _, u := range []string{
+		"http://127.0.0.1:8082/api/v1/numbers",
+		"http://[::1]/",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0/",
+		"http://LocalHost./",
+		"http://api.localhost/",
+	} {
+		if _, err := d.Subscribe(u, nil, ""); !errors.Is(err, ErrLocalTarget) {
+			t.Errorf("Subscribe(%q) error = %v, want %v", u, err, ErrLocalTarget)
+		}
+	}
+
+	// names are checked once they resolve, so none leads here either
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	_, err := d.client.Get(srv.URL)
+	if !errors.Is(err, ErrLocalTarget) {
+		t.Errorf("Delivering to %s failed with %v, want %v", srv.URL, err, ErrLocalTarget)
+	}
+}
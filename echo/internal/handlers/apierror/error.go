@@ -0,0 +1,193 @@
+// Package apierror is the body of every error response of the http API.
+package apierror
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/auth"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/cluster"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/idempotency"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/replication"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/webhook"
+	"github.com/labstack/echo"
+)
+
+// Code tells clients what went wrong without parsing the message.
+type Code string
+
+const (
+	CodeBadRequest            Code = "bad_request"
+	CodeValidationFailed      Code = "validation_failed"
+	CodeInvalidRequest        Code = "invalid_request"
+	CodeInvalidIndex          Code = "invalid_index"
+	CodeInvalidValue          Code = "invalid_value"
+	CodeInvalidListName       Code = "invalid_list_name"
+	CodeInvalidEventID        Code = "invalid_event_id"
+	CodeInvalidIdempotencyKey Code = "invalid_idempotency_key"
+	CodeInvalidRows           Code = "invalid_rows"
+	CodeUnauthenticated       Code = "unauthenticated"
+	CodeForbidden             Code = "forbidden"
+	CodeNotFound              Code = "not_found"
+	CodeListNotFound          Code = "list_not_found"
+	CodeIndexNotFound         Code = "index_not_found"
+	CodeValueNotFound         Code = "value_not_found"
+	CodeNoEventFeed           Code = "no_event_feed"
+	CodeNoHistory             Code = "no_history"
+	CodeVersionNotFound       Code = "version_not_found"
+	CodeMethodNotAllowed      Code = "method_not_allowed"
+	CodeConflict              Code = "conflict"
+	CodeListExists            Code = "list_exists"
+	CodeListFull              Code = "list_full"
+	CodeIdempotencyInProgress Code = "idempotency_in_progress"
+	CodeVersionMismatch       Code = "version_mismatch"
+	CodeUnsupportedMediaType  Code = "unsupported_media_type"
+	CodeNotAcceptable         Code = "not_acceptable"
+	CodeIdempotencyKeyReused  Code = "idempotency_key_reused"
+	CodeRateLimited           Code = "rate_limited"
+	CodeReadOnly              Code = "read_only"
+	CodeLogGone               Code = "log_gone"
+	CodeHistoryGone           Code = "history_gone"
+	CodeNotLeader             Code = "not_leader"
+	CodeNoMember              Code = "no_member"
+	CodeWebhookNotFound       Code = "webhook_not_found"
+	CodeInvalidWebhook        Code = "invalid_webhook"
+	CodeInternal              Code = "internal"
+	CodeUnavailable           Code = "unavailable"
+)
+
+// Error is the envelope of an error response. Internal is logged for server
+// errors but never sent.
+type Error struct {
+	Status    int    `json:"-"`
+	Code      Code   `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Internal  error  `json:"-"`
+}
+
+// FieldViolation is one failed validation rule, sent as details.
+type FieldViolation struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+func New(status int, code Code, message string) *Error {
+	return &Error{
+		Status:  status,
+		Code:    code,
+		Message: message,
+	}
+}
+
+func (e *Error) WithDetails(details any) *Error {
+	e.Details = details
+	return e
+}
+
+func (e *Error) WithInternal(err error) *Error {
+	e.Internal = err
+	return e
+}
+
+func (e *Error) Error() string {
+	return string(e.Code) + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Internal
+}
+
+// domain maps the errors of the core packages, so handlers can return them
+// as they are.
+var domain = []struct {
+	err     error
+	status  int
+	code    Code
+	message string
+}{
+	{list.ErrInvalidIndex, http.StatusBadRequest, CodeInvalidIndex, "Invalid index"},
+	{list.ErrIndexNotFound, http.StatusNotFound, CodeIndexNotFound, "Index not found"},
+	{list.ErrValueNotFound, http.StatusNotFound, CodeValueNotFound, "Value not found"},
+	{list.ErrListFull, http.StatusConflict, CodeListFull, "List is full"},
+	{list.ErrVersionMismatch, http.StatusPreconditionFailed, CodeVersionMismatch, "Version mismatch"},
+	{list.ErrInvalidImportMode, http.StatusBadRequest, CodeInvalidRequest, "Invalid import mode"},
+	{list.ErrListNotFound, http.StatusNotFound, CodeListNotFound, "List not found"},
+	{list.ErrListExists, http.StatusConflict, CodeListExists, "List already exists"},
+	{list.ErrInvalidName, http.StatusBadRequest, CodeInvalidListName, "Invalid list name"},
+	{list.ErrNoHistory, http.StatusNotFound, CodeNoHistory, "History is disabled"},
+	{list.ErrHistoryGone, http.StatusGone, CodeHistoryGone, "Version is older than the history keeps"},
+	{list.ErrVersionNotFound, http.StatusNotFound, CodeVersionNotFound, "List hasn't reached this version"},
+	{idempotency.ErrMismatch, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused, "Idempotency key was used for a different request"},
+	{idempotency.ErrInProgress, http.StatusConflict, CodeIdempotencyInProgress, "A request with this idempotency key is in progress"},
+	{replication.ErrLogGone, http.StatusGone, CodeLogGone, "Log entries are no longer kept; load a snapshot"},
+	{cluster.ErrNotLeader, http.StatusMisdirectedRequest, CodeNotLeader, "This node isn't the leader; see /admin/cluster for the one that is"},
+	{cluster.ErrNotStarted, http.StatusServiceUnavailable, CodeUnavailable, "This node hasn't joined the cluster yet"},
+	{cluster.ErrNoMember, http.StatusNotFound, CodeNoMember, "No such cluster member"},
+	{webhook.ErrNotFound, http.StatusNotFound, CodeWebhookNotFound, "Webhook not found"},
+	{webhook.ErrInvalidURL, http.StatusBadRequest, CodeInvalidWebhook, "Webhook url must be an absolute http or https url"},
+	{webhook.ErrInvalidEvent, http.StatusBadRequest, CodeInvalidWebhook, "Unknown webhook event"},
+	{webhook.ErrLocalTarget, http.StatusBadRequest, CodeInvalidWebhook, "Webhook url must not point at a loopback or link-local address"},
+	{auth.ErrNoCredentials, http.StatusUnauthorized, CodeUnauthenticated, "Missing credentials"},
+	{auth.ErrInvalidCredentials, http.StatusUnauthorized, CodeUnauthenticated, "Invalid credentials"},
+}
+
+// byStatus names the errors echo raises itself, which only carry a status.
+var byStatus = map[int]Code{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusUnauthorized:          CodeUnauthenticated,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusMethodNotAllowed:      CodeMethodNotAllowed,
+	http.StatusConflict:              CodeConflict,
+	http.StatusNotAcceptable:         CodeNotAcceptable,
+	http.StatusUnsupportedMediaType:  CodeUnsupportedMediaType,
+	http.StatusTooManyRequests:       CodeRateLimited,
+	http.StatusInternalServerError:   CodeInternal,
+	http.StatusServiceUnavailable:    CodeUnavailable,
+	http.StatusRequestEntityTooLarge: CodeBadRequest,
+}
+
+// From turns whatever a handler returned into an envelope. Unknown errors
+// become a 500 that keeps the error internal.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		copied := *e
+		return &copied
+	}
+
+	var batchErr *list.BatchError
+	if errors.As(err, &batchErr) {
+		e = From(batchErr.Err)
+		e.Details = map[string]int{"operation": batchErr.Operation}
+		return e
+	}
+
+	for _, d := range domain {
+		if errors.Is(err, d.err) {
+			return New(d.status, d.code, d.message).WithInternal(err)
+		}
+	}
+
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		code, ok := byStatus[he.Code]
+		if !ok {
+			code = CodeBadRequest
+			if he.Code >= http.StatusInternalServerError {
+				code = CodeInternal
+			}
+		}
+		message, ok := he.Message.(string)
+		if !ok {
+			message = http.StatusText(he.Code)
+		}
+		return New(he.Code, code, message).WithInternal(he.Internal)
+	}
+
+	return New(http.StatusInternalServerError, CodeInternal, http.StatusText(http.StatusInternalServerError)).WithInternal(err)
+}
@@ -0,0 +1,44 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/labstack/echo"
+)
+
+func TestFrom(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		code    Code
+		details any
+	}{
+		{"domain", list.ErrIndexNotFound, http.StatusNotFound, CodeIndexNotFound, nil},
+		{"wrapped domain", fmt.Errorf("get: %w", list.ErrListFull), http.StatusConflict, CodeListFull, nil},
+		{"batch", &list.BatchError{Operation: 2, Err: list.ErrInvalidIndex}, http.StatusBadRequest, CodeInvalidIndex, map[string]int{"operation": 2}},
+		{"envelope", New(http.StatusTeapot, CodeConflict, "Teapot"), http.StatusTeapot, CodeConflict, nil},
+		{"echo", echo.ErrMethodNotAllowed, http.StatusMethodNotAllowed, CodeMethodNotAllowed, nil},
+		{"unknown", errors.New("boom"), http.StatusInternalServerError, CodeInternal, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := From(tt.err)
+			if e.Status != tt.status || e.Code != tt.code {
+				t.Fatalf("Got %d %s, want %d %s", e.Status, e.Code, tt.status, tt.code)
+			}
+			if fmt.Sprint(e.Details) != fmt.Sprint(tt.details) {
+				t.Fatalf("Got details %v, want %v", e.Details, tt.details)
+			}
+		})
+	}
+
+	// unknown errors must not leak into the message
+	if e := From(errors.New("secret")); e.Message != "Internal Server Error" {
+		t.Fatalf("Internal error leaked: %q", e.Message)
+	}
+}
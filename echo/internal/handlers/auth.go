@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/auth"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	v1 "github.com/alipourhabibi/exercises-journal/echo/internal/handlers/v1"
+	"github.com/labstack/echo"
+)
+
+const (
+	headerAPIKey = "X-API-Key"
+	// principalKey holds the auth.Principal of a request in the echo context
+	principalKey = "principal"
+)
+
+// credentials takes the token from "Authorization: Bearer" or X-API-Key.
+func credentials(r *http.Request) string {
+	if h := r.Header.Get(echo.HeaderAuthorization); h != "" {
+		scheme, token, ok := strings.Cut(h, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	return r.Header.Get(headerAPIKey)
+}
+
+// Auth lets a request through once its API key or JWT resolves to a role
+// allowed to use the request method: readers get the GET routes, writers
+// everything.
+func Auth(a *auth.AuthService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if isProbe(c) || !a.Enabled() {
+				return next(c)
+			}
+
+			p, err := a.Authenticate(credentials(c.Request()))
+			if err != nil {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+				return err
+			}
+			if !p.Role.Allows(c.Request().Method) {
+				return apierror.New(http.StatusForbidden, apierror.CodeForbidden, "Role "+string(p.Role)+" may not do this")
+			}
+
+			c.Set(principalKey, p)
+			c.Set(v1.ActorKey, p.Name)
+			return next(c)
+		}
+	}
+}
+
+// adminRoutes are the route prefixes only admins may use: a cluster member
+// gets every change and a vote on the next ones, and webhooks make the
+// server send requests wherever they point and hold what was sent.
+var adminRoutes = []string{PathCluster + "/members", "/api/v1/webhooks"}
+
+// AdminOnly lets only admins use the adminRoutes once auth is on. It runs
+// after Auth, which found who sent the request.
+func AdminOnly() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			p, ok := c.Get(principalKey).(auth.Principal)
+			if !ok || p.Role == auth.RoleAdmin {
+				return next(c)
+			}
+			for _, prefix := range adminRoutes {
+				if strings.HasPrefix(c.Path(), prefix) {
+					return apierror.New(http.StatusForbidden, apierror.CodeForbidden, "Role "+string(p.Role)+" may not do this")
+				}
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	v1 "github.com/alipourhabibi/exercises-journal/echo/internal/handlers/v1"
+	"github.com/labstack/echo"
+)
+
+// ClientIP works out the address of the client and keeps it under
+// v1.ClientKey. It is the peer of the connection unless that peer is one
+// of proxies, whose X-Forwarded-For and X-Real-IP headers are then
+// believed; anyone else could put any address there.
+func ClientIP(proxies []*net.IPNet) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(v1.ClientKey, clientIP(c.Request(), proxies))
+			return next(c)
+		}
+	}
+}
+
+func clientIP(req *http.Request, proxies []*net.IPNet) string {
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		ip = req.RemoteAddr
+	}
+	if !trusted(ip, proxies) {
+		return ip
+	}
+	// each proxy appends the address it was reached from, so the client is
+	// the last one no trusted proxy added
+	if xff := req.Header.Get(echo.HeaderXForwardedFor); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip = strings.TrimSpace(hops[i])
+			if !trusted(ip, proxies) {
+				break
+			}
+		}
+		return ip
+	}
+	if real := req.Header.Get(echo.HeaderXRealIP); real != "" {
+		return real
+	}
+	return ip
+}
+
+func trusted(ip string, proxies []*net.IPNet) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, n := range proxies {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseProxies reads addresses and CIDR ranges of trusted proxies.
+func ParseProxies(proxies []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an ip address or CIDR range", p)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an ip address or CIDR range", p)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// client is the address ClientIP worked out for the request.
+func client(c echo.Context) string {
+	ip, _ := c.Get(v1.ClientKey).(string)
+	return ip
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("ParseProxies() error = %v", err)
+	}
+	tests := []struct {
+		remote string
+		xff    string
+		real   string
+		want   string
+	}{
+		// anyone else can't pick their address
+		{"203.0.113.9:1234", "1.2.3.4", "5.6.7.8", "203.0.113.9"},
+		{"10.1.2.3:1234", "", "", "10.1.2.3"},
+		{"10.1.2.3:1234", "", "198.51.100.7", "198.51.100.7"},
+		// the client is the last hop no trusted proxy added, not whatever
+		// it claimed in front
+		{"10.1.2.3:1234", "1.2.3.4, 198.51.100.7, 192.168.1.1", "", "198.51.100.7"},
+		{"192.168.1.1:1234", "10.0.0.1", "", "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remote
+		if tt.xff != "" {
+			req.Header.Set("X-Forwarded-For", tt.xff)
+		}
+		if tt.real != "" {
+			req.Header.Set("X-Real-IP", tt.real)
+		}
+		if got := clientIP(req, proxies); got != tt.want {
+			t.Errorf("clientIP(%s, %q, %q) = %q, want %q", tt.remote, tt.xff, tt.real, got, tt.want)
+		}
+	}
+
+	if _, err := ParseProxies([]string{"proxy.local"}); err == nil {
+		t.Errorf("ParseProxies() accepted a host name")
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/cluster"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"github.com/labstack/echo"
+)
+
+// HeaderReadConsistency asks for a linearizable read with the value
+// "linearizable"; without it a node answers from what it applied so far.
+const HeaderReadConsistency = "X-Read-Consistency"
+
+// Linearizable makes reads of the API that ask for it wait until node is
+// sure it holds every acknowledged change. Only the leader can be.
+func Linearizable(node *cluster.Node) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if isWrite(c.Request().Method) || !strings.HasPrefix(c.Path(), "/api/") ||
+				!strings.EqualFold(c.Request().Header.Get(HeaderReadConsistency), "linearizable") {
+				return next(c)
+			}
+			err := node.Linearize()
+			if err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// PathCluster is where the status and the membership of the cluster are
+// served.
+const PathCluster = "/admin/cluster"
+
+type memberRequest struct {
+	ID      string `json:"id" validate:"required"`
+	Address string `json:"address" validate:"required"`
+}
+
+// cluster serves the membership of the cluster the node is in. Changing it
+// takes an admin; see AdminOnly.
+func (s *server) cluster() {
+	s.e.GET(PathCluster, func(c echo.Context) error {
+		status, err := s.node.Status()
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, status)
+	})
+	s.e.POST(PathCluster+"/members", func(c echo.Context) error {
+		m := memberRequest{}
+		if err := c.Bind(&m); err != nil {
+			return err
+		}
+		if err := c.Validate(&m); err != nil {
+			return err
+		}
+		if _, _, err := net.SplitHostPort(m.Address); err != nil {
+			return apierror.New(http.StatusBadRequest, apierror.CodeInvalidRequest, "Address must be host:port").WithInternal(err)
+		}
+		err := s.node.Join(m.ID, m.Address)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusCreated, cluster.Member{ID: m.ID, Address: m.Address, Voter: true})
+	})
+	s.e.DELETE(PathCluster+"/members/:id", func(c echo.Context) error {
+		err := s.node.Leave(c.Param("id"))
+		if err != nil {
+			return err
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/auth"
+	"github.com/labstack/echo"
+)
+
+func TestAdminOnly(t *testing.T) {
+	reader := &auth.Principal{Name: "r", Role: auth.RoleReader}
+	writer := &auth.Principal{Name: "w", Role: auth.RoleWriter}
+	admin := &auth.Principal{Name: "a", Role: auth.RoleAdmin}
+	tests := []struct {
+		name   string
+		p      *auth.Principal
+		method string
+		target string
+		want   int
+	}{
+		{"auth off", nil, http.MethodDelete, PathCluster + "/members/node2", http.StatusNoContent},
+		{"reader", reader, http.MethodDelete, PathCluster + "/members/node2", http.StatusForbidden},
+		{"writer", writer, http.MethodDelete, PathCluster + "/members/node2", http.StatusForbidden},
+		{"admin", admin, http.MethodDelete, PathCluster + "/members/node2", http.StatusNoContent},
+		{"writer status", writer, http.MethodGet, PathCluster, http.StatusNoContent},
+		{"writer webhook", writer, http.MethodPost, "/api/v1/webhooks", http.StatusForbidden},
+		{"reader dead letters", reader, http.MethodGet, "/api/v1/webhooks/1/dead-letters", http.StatusForbidden},
+		{"admin webhook", admin, http.MethodPost, "/api/v1/webhooks", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		e := echo.New()
+		e.HTTPErrorHandler = ErrorHandler
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				if tt.p != nil {
+					c.Set(principalKey, *tt.p)
+				}
+				return next(c)
+			}
+		}, AdminOnly())
+		ok := func(c echo.Context) error {
+			return c.NoContent(http.StatusNoContent)
+		}
+		e.GET(PathCluster, ok)
+		e.DELETE(PathCluster+"/members/:id", ok)
+		e.POST("/api/v1/webhooks", ok)
+		e.GET("/api/v1/webhooks/:id/dead-letters", ok)
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s: %s %s = %d, want %d", tt.name, tt.method, tt.target, rec.Code, tt.want)
+		}
+	}
+}
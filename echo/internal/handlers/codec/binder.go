@@ -0,0 +1,43 @@
+package codec
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo"
+)
+
+// Binder binds bodies in any of Codecs. JSON and body-less requests are
+// left to echo's binder, so their errors stay what they were; the other
+// formats fail the same way, with a 400 for a body that doesn't decode and
+// a 415 for an unknown Content-Type.
+type Binder struct {
+	echo.DefaultBinder
+}
+
+func (b *Binder) Bind(i any, c echo.Context) error {
+	req := c.Request()
+	if req.ContentLength == 0 {
+		return b.DefaultBinder.Bind(i, c)
+	}
+	codec, ok := ForContentType(req.Header.Get(echo.HeaderContentType))
+	if !ok {
+		return UnsupportedMediaType(MediaTypes(i))
+	}
+	if codec == JSON {
+		return b.DefaultBinder.Bind(i, c)
+	}
+	if !codec.Supports(i) {
+		return UnsupportedMediaType(MediaTypes(i))
+	}
+
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	if err := codec.Unmarshal(data, i); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Malformed %s body: %v", codec.MediaType, err)).SetInternal(err)
+	}
+	return nil
+}
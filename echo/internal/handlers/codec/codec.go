@@ -0,0 +1,158 @@
+// Package codec reads and writes the bodies of the http API in every format
+// it speaks, so handlers bind and render a value the same way whichever
+// one the client picked.
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/labstack/echo"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MIMEApplicationCBOR is the media type of CBOR, which echo doesn't name.
+const MIMEApplicationCBOR = "application/cbor"
+
+// ErrNoMessage is returned for values protobuf has no message for.
+var ErrNoMessage = errors.New("codec: no protobuf message for this type")
+
+// Codec encodes and decodes the bodies of one media type. MessagePack and
+// CBOR go by the json tags of a type, so all formats share one shape.
+type Codec struct {
+	MediaType string
+	marshal   func(v any) ([]byte, error)
+	unmarshal func(data []byte, v any) error
+	// decode reads a body into the values encoding/json would have made
+	// of the same body in JSON
+	decode func(data []byte) (any, error)
+	// supports reports whether v can be encoded; nil means everything
+	supports func(v any) bool
+}
+
+var (
+	JSON = &Codec{
+		MediaType: echo.MIMEApplicationJSON,
+		marshal:   json.Marshal,
+		unmarshal: json.Unmarshal,
+		decode:    decodeVia(json.Unmarshal),
+	}
+	MsgPack = &Codec{
+		MediaType: echo.MIMEApplicationMsgpack,
+		marshal:   marshalMsgPack,
+		unmarshal: unmarshalMsgPack,
+		decode:    decodeVia(unmarshalMsgPack),
+	}
+	CBOR = &Codec{
+		MediaType: MIMEApplicationCBOR,
+		marshal:   cborEnc.Marshal,
+		unmarshal: cborDec.Unmarshal,
+		decode:    decodeVia(cborDec.Unmarshal),
+	}
+	Protobuf = &Codec{
+		MediaType: echo.MIMEApplicationProtobuf,
+		marshal:   marshalProto,
+		unmarshal: unmarshalProto,
+		decode:    decodeProto,
+		supports:  hasMessage,
+	}
+)
+
+// Codecs are all the formats, JSON first as the one used when a client
+// has no preference.
+var Codecs = []*Codec{JSON, MsgPack, CBOR, Protobuf}
+
+var (
+	// times are RFC 3339 strings, as in JSON, tagged as such
+	cborEnc, _ = cbor.EncOptions{Time: cbor.TimeRFC3339Nano, TimeTag: cbor.EncTagRequired}.EncMode()
+	// maps decoded into any get string keys like JSON objects
+	cborDec, _ = cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]any(nil))}.DecMode()
+)
+
+func marshalMsgPack(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func unmarshalMsgPack(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// decodeVia decodes data generically with unmarshal and then passes it
+// through JSON, leaving the float64 numbers and map[string]any objects
+// JSON schemas are checked against.
+func decodeVia(unmarshal func([]byte, any) error) func([]byte) (any, error) {
+	return func(data []byte) (any, error) {
+		var v any
+		if err := unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		return normalize(v)
+	}
+}
+
+func normalize(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	err = json.Unmarshal(b, &out)
+	return out, err
+}
+
+// ForContentType returns the codec of a Content-Type header.
+func ForContentType(contentType string) (*Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	for _, c := range Codecs {
+		if c.MediaType == mediaType {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// MediaTypes lists the media types v can be sent and received in.
+func MediaTypes(v any) []string {
+	var types []string
+	for _, c := range Codecs {
+		if c.Supports(v) {
+			types = append(types, c.MediaType)
+		}
+	}
+	return types
+}
+
+// Supports reports whether the codec can encode v.
+func (c *Codec) Supports(v any) bool {
+	return c.supports == nil || c.supports(v)
+}
+
+func (c *Codec) Marshal(v any) ([]byte, error) {
+	return c.marshal(v)
+}
+
+func (c *Codec) Unmarshal(data []byte, v any) error {
+	return c.unmarshal(data, v)
+}
+
+// Decode reads a body into the generic values encoding/json would have
+// decoded its JSON form into, for request validation.
+func (c *Codec) Decode(data []byte) (any, error) {
+	return c.decode(data)
+}
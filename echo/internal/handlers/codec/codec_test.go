@@ -0,0 +1,112 @@
+package codec
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	echov1 "github.com/alipourhabibi/exercises-journal/echo/api/echo/v1"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRoundTrip(t *testing.T) {
+	entity := list.ListEntity{Index: 3, Value: -7}
+	apiErr := apierror.New(http.StatusBadRequest, apierror.CodeValidationFailed, "Request is invalid").
+		WithDetails([]apierror.FieldViolation{{Field: "Value", Rule: "required"}})
+	apiErr.RequestID = "abc"
+
+	for _, c := range Codecs {
+		t.Run(c.MediaType, func(t *testing.T) {
+			data, err := c.Marshal(entity)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			var got list.ListEntity
+			if err := c.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if got != entity {
+				t.Errorf("Unmarshal() = %+v, want %+v", got, entity)
+			}
+			// validation sees the same values in every format
+			generic, err := c.Decode(data)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			want := map[string]any{"index": 3.0, "value": -7.0}
+			if !reflect.DeepEqual(generic, want) {
+				t.Errorf("Decode() = %#v, want %#v", generic, want)
+			}
+
+			if _, err := c.Marshal(apiErr); err != nil {
+				t.Errorf("Marshal() of an error: %v", err)
+			}
+		})
+	}
+}
+
+func TestProtobufOnlyEntities(t *testing.T) {
+	v := struct {
+		Applied int `json:"applied"`
+	}{1}
+	if Protobuf.Supports(v) {
+		t.Errorf("Supports() = true for a type without a message")
+	}
+	if _, err := Protobuf.Marshal(v); !errors.Is(err, ErrNoMessage) {
+		t.Errorf("Marshal() error = %v, want %v", err, ErrNoMessage)
+	}
+	if got := MediaTypes(v); len(got) != 3 {
+		t.Errorf("MediaTypes() = %v, want all but protobuf", got)
+	}
+}
+
+func TestDecodeMissingField(t *testing.T) {
+	// a value left out must stay missing, not become 0, so the schema
+	// rejects it in every format
+	for _, c := range []*Codec{MsgPack, CBOR, Protobuf} {
+		var data []byte
+		var err error
+		if c == Protobuf {
+			data, err = c.Marshal(&echov1.ListEntity{Index: proto.Uint64(2)})
+		} else {
+			data, err = c.Marshal(map[string]any{"index": 2})
+		}
+		if err != nil {
+			t.Fatalf("%s: Marshal() error = %v", c.MediaType, err)
+		}
+		got, err := c.Decode(data)
+		if err != nil {
+			t.Fatalf("%s: Decode() error = %v", c.MediaType, err)
+		}
+		if want := map[string]any{"index": 2.0}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: Decode() = %#v, want %#v", c.MediaType, got, want)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{JSON.MediaType, MsgPack.MediaType, CBOR.MediaType}
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", JSON.MediaType},
+		{"*/*", JSON.MediaType},
+		{"application/*", JSON.MediaType},
+		{"application/cbor", CBOR.MediaType},
+		{"application/json;q=0.5, application/msgpack", MsgPack.MediaType},
+		{"text/html, application/cbor;q=0.1", CBOR.MediaType},
+		{"*/*;q=0.1, application/json;q=0", MsgPack.MediaType},
+		{"Application/CBOR; charset=binary", CBOR.MediaType},
+		{"text/html", ""},
+		{"application/protobuf", ""},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.accept, offers); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package codec
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"github.com/labstack/echo"
+)
+
+// Negotiate returns the offer an Accept header prefers, the first offer if
+// the header is empty, and "" if it accepts none of them. Ties go to the
+// earlier offer.
+func Negotiate(accept string, offers []string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := quality(accept, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// quality is the q the most specific range of accept matching mediaType
+// gives it, 0 if none does.
+func quality(accept, mediaType string) float64 {
+	q, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		r, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		var s int
+		switch {
+		case r == mediaType:
+			s = 2
+		case strings.HasSuffix(r, "/*") && r != "*/*" && strings.HasPrefix(mediaType, r[:len(r)-1]):
+			s = 1
+		case r == "*/*" || r == "*":
+			s = 0
+		default:
+			continue
+		}
+		if s <= specificity {
+			continue
+		}
+		specificity, q = s, 1
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				q = 0
+			}
+		}
+	}
+	return q
+}
+
+// For returns the codec the request accepts v in, or false if it accepts
+// none that can encode v.
+func For(c echo.Context, v any) (*Codec, bool) {
+	// caches must keep a copy per format
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	mediaType := Negotiate(c.Request().Header.Get(echo.HeaderAccept), MediaTypes(v))
+	for _, codec := range Codecs {
+		if codec.MediaType == mediaType {
+			return codec, true
+		}
+	}
+	return nil, false
+}
+
+// NotAcceptable is the error for requests accepting none of offers.
+func NotAcceptable(offers []string) *apierror.Error {
+	return apierror.New(http.StatusNotAcceptable, apierror.CodeNotAcceptable,
+		fmt.Sprintf("Can't answer in an accepted media type; accept one of %s", strings.Join(offers, ", ")))
+}
+
+// UnsupportedMediaType is the error for bodies sent as none of accepted.
+func UnsupportedMediaType(accepted []string) *apierror.Error {
+	return apierror.New(http.StatusUnsupportedMediaType, apierror.CodeUnsupportedMediaType,
+		fmt.Sprintf("Unsupported Content-Type; send one of %s", strings.Join(accepted, ", ")))
+}
+
+// Render sends v with status in the format the request accepts, or returns
+// a 406 error if it accepts none v can be sent in.
+func Render(c echo.Context, status int, v any) error {
+	codec, ok := For(c, v)
+	if !ok {
+		return NotAcceptable(MediaTypes(v))
+	}
+	return codec.Render(c, status, v)
+}
+
+// Render sends v with status in this format. JSON goes through echo, which
+// keeps ?pretty working.
+func (codec *Codec) Render(c echo.Context, status int, v any) error {
+	if codec == JSON {
+		return c.JSON(status, v)
+	}
+	b, err := codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.Blob(status, codec.MediaType, b)
+}
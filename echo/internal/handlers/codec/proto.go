@@ -0,0 +1,93 @@
+package codec
+
+import (
+	echov1 "github.com/alipourhabibi/exercises-journal/echo/api/echo/v1"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// toMessage returns the payload.proto message of v. Only entities and
+// errors have one.
+func toMessage(v any) (proto.Message, error) {
+	switch v := v.(type) {
+	case list.ListEntity:
+		return &echov1.ListEntity{
+			Index: proto.Uint64(uint64(v.Index)),
+			Value: proto.Int64(int64(v.Value)),
+		}, nil
+	case *list.ListEntity:
+		return toMessage(*v)
+	case *apierror.Error:
+		m := &echov1.Error{
+			Code:      string(v.Code),
+			Message:   v.Message,
+			RequestId: v.RequestID,
+		}
+		if v.Details != nil {
+			// details are whatever the error carries; send what JSON would
+			details, err := normalize(v.Details)
+			if err != nil {
+				return nil, err
+			}
+			m.Details, err = structpb.NewValue(details)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case proto.Message:
+		return v, nil
+	}
+	return nil, ErrNoMessage
+}
+
+func hasMessage(v any) bool {
+	switch v.(type) {
+	case list.ListEntity, *list.ListEntity, *apierror.Error, proto.Message:
+		return true
+	}
+	return false
+}
+
+func marshalProto(v any) ([]byte, error) {
+	m, err := toMessage(v)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(m)
+}
+
+func unmarshalProto(data []byte, v any) error {
+	switch v := v.(type) {
+	case *list.ListEntity:
+		m := &echov1.ListEntity{}
+		if err := proto.Unmarshal(data, m); err != nil {
+			return err
+		}
+		v.Index = uint(m.GetIndex())
+		v.Value = int(m.GetValue())
+		return nil
+	case proto.Message:
+		return proto.Unmarshal(data, v)
+	}
+	return ErrNoMessage
+}
+
+// decodeProto reads an entity, the only protobuf request body, leaving out
+// the fields that weren't sent so they count as missing like in JSON.
+func decodeProto(data []byte) (any, error) {
+	m := &echov1.ListEntity{}
+	if err := proto.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	out := map[string]any{}
+	if m.Index != nil {
+		out["index"] = float64(m.GetIndex())
+	}
+	if m.Value != nil {
+		out["value"] = float64(m.GetValue())
+	}
+	return out, nil
+}
@@ -2,57 +2,370 @@ package handlers
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
-	"sync"
+	"net/url"
+	"reflect"
+	"strings"
 
-	"github.com/alipourhabibi/exercises-journal/echo/config"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/audit"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/auth"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/cluster"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/idempotency"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/metrics"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/ratelimit"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/replication"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/webhook"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/codec"
 	v1 "github.com/alipourhabibi/exercises-journal/echo/internal/handlers/v1"
-	"github.com/alipourhabibi/exercises-journal/linkedlist"
 	"github.com/go-playground/validator"
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/middleware"
 )
 
 type server struct {
-	e *echo.Echo
+	e           *echo.Echo
+	list        *list.ListService
+	lists       *list.Registry
+	idempotency *idempotency.Store
+	auth        *auth.AuthService
+	limiter     *ratelimit.LimiterService
+	metrics     *metrics.MetricsService
+	ready       *Readiness
+	leader      *replication.Leader
+	// readOnly guards a follower; nil on a leader or a standalone server
+	readOnly echo.MiddlewareFunc
+	node     *cluster.Node
+	audit    *audit.Log
+	webhooks *webhook.Dispatcher
+	// tls serves over TLS when set
+	tls *tls.Config
+	// proxies are trusted to say who the client is
+	proxies []*net.IPNet
 }
 
-func New() *server {
+type ServerConfiguration func(*server) error
+
+// New builds a server around the given lists. The lists outlive the server
+// so a replacement server started on reload keeps the same data.
+func New(cfgs ...ServerConfiguration) (*server, error) {
 	e := echo.New()
-	e.Use(middleware.Logger())
-	e.Validator = &CustomValidator{validator: validator.New()}
+	e.HideBanner = true
+	e.HTTPErrorHandler = ErrorHandler
+	// the id comes first so the access log and error responses can show it
+	e.Use(middleware.RequestID())
+	e.Validator = NewValidator()
+	e.Binder = &codec.Binder{}
 
-	return &server{
+	s := &server{
 		e: e,
 	}
+	for _, cfg := range cfgs {
+		err := cfg(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if s.list == nil || s.lists == nil {
+		return nil, errors.New("handlers: a list and a registry are required")
+	}
+	// everything below knows the client by the address this settles on
+	e.Use(ClientIP(s.proxies))
+	// trace, log and measure first so requests rejected below show up too
+	e.Use(Tracing())
+	e.Use(AccessLog())
+	if s.metrics != nil {
+		e.Use(Metrics(s.metrics))
+	}
+	// authenticate first so rejected requests don't claim idempotency keys
+	// and so clients can be limited by who they are
+	if s.auth != nil {
+		e.Use(Auth(s.auth))
+		e.Use(AdminOnly())
+	}
+	if s.limiter != nil {
+		e.Use(RateLimit(s.limiter))
+	}
+	// followers send writes on before anything else looks at them
+	if s.readOnly != nil {
+		e.Use(s.readOnly)
+	}
+	if s.node != nil {
+		e.Use(Linearizable(s.node))
+	}
+	// reject malformed requests before they claim an idempotency key
+	e.Use(ContentNegotiation(v1.Spec()))
+	e.Use(RequestValidation(v1.Spec()))
+	if s.idempotency != nil {
+		e.Use(Idempotency(s.idempotency))
+	}
+
+	s.probes()
+	if s.leader != nil {
+		s.replication()
+	}
+	if s.node != nil {
+		s.cluster()
+	}
+	v1.New(e, s.list, s.lists, s.audit, s.webhooks)
+
+	return s, nil
+}
+
+func WithList(l *list.ListService) ServerConfiguration {
+	return func(s *server) error {
+		s.list = l
+		return nil
+	}
+}
+
+func WithRegistry(r *list.Registry) ServerConfiguration {
+	return func(s *server) error {
+		s.lists = r
+		return nil
+	}
+}
+
+// WithAuth requires requests to authenticate against a, which is shared
+// across reloads so key changes apply to the running server.
+func WithAuth(a *auth.AuthService) ServerConfiguration {
+	return func(s *server) error {
+		s.auth = a
+		return nil
+	}
+}
+
+// WithRateLimit limits requests per client with l, which is shared across
+// reloads so new limits apply to the running server.
+func WithRateLimit(l *ratelimit.LimiterService) ServerConfiguration {
+	return func(s *server) error {
+		s.limiter = l
+		return nil
+	}
+}
+
+// WithIdempotency makes mutating routes honour the Idempotency-Key header
+// using store, which is shared across reloads like the lists.
+func WithIdempotency(store *idempotency.Store) ServerConfiguration {
+	return func(s *server) error {
+		s.idempotency = store
+		return nil
+	}
+}
+
+// WithMetrics records requests into m and serves it on /metrics.
+func WithMetrics(m *metrics.MetricsService) ServerConfiguration {
+	return func(s *server) error {
+		s.metrics = m
+		return nil
+	}
+}
+
+// WithReadiness makes /readyz report r. Without it the server is always
+// ready.
+func WithReadiness(r *Readiness) ServerConfiguration {
+	return func(s *server) error {
+		s.ready = r
+		return nil
+	}
+}
+
+// WithLeader serves the snapshot and change log of l to followers.
+func WithLeader(l *replication.Leader) ServerConfiguration {
+	return func(s *server) error {
+		s.leader = l
+		return nil
+	}
+}
+
+// WithFollower makes the lists read-only: writes are forwarded to the
+// leader at rawURL when forward is set and refused otherwise.
+func WithFollower(rawURL string, forward bool) ServerConfiguration {
+	return func(s *server) error {
+		leader, err := url.Parse(rawURL)
+		if err != nil {
+			return err
+		}
+		s.readOnly = ReadOnly(leader, forward)
+		return nil
+	}
+}
+
+// WithCluster serves the membership of the cluster node is in and lets
+// reads ask to be linearizable.
+func WithCluster(node *cluster.Node) ServerConfiguration {
+	return func(s *server) error {
+		s.node = node
+		return nil
+	}
+}
+
+// WithAudit logs every change made through the v1 routes to a and serves
+// it on /api/v1/audit.
+func WithAudit(a *audit.Log) ServerConfiguration {
+	return func(s *server) error {
+		s.audit = a
+		return nil
+	}
+}
+
+// WithWebhooks lets clients subscribe to the changes d observes through
+// /api/v1/webhooks.
+func WithWebhooks(d *webhook.Dispatcher) ServerConfiguration {
+	return func(s *server) error {
+		s.webhooks = d
+		return nil
+	}
 }
 
-type list struct {
-	sync.RWMutex
-	l *linkedlist.LinkedList
+// WithTLS serves over TLS with cfg, which may look up its certificate on
+// every handshake.
+func WithTLS(cfg *tls.Config) ServerConfiguration {
+	return func(s *server) error {
+		s.tls = cfg
+		return nil
+	}
+}
+
+// WithTrustedProxies believes the client addresses that the proxies at
+// proxies, addresses or CIDR ranges, pass on in X-Forwarded-For and
+// X-Real-IP.
+func WithTrustedProxies(proxies []string) ServerConfiguration {
+	return func(s *server) error {
+		nets, err := ParseProxies(proxies)
+		if err != nil {
+			return err
+		}
+		s.proxies = nets
+		return nil
+	}
+}
+
+// isWrite reports whether a request with method may change a list.
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
 }
 
 type CustomValidator struct {
 	validator *validator.Validate
 }
 
+// NewValidator reports fields by their json names, as clients sent them.
+func NewValidator() *CustomValidator {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return &CustomValidator{validator: v}
+}
+
 func (cv *CustomValidator) Validate(i interface{}) error {
-	if err := cv.validator.Struct(i); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	err := cv.validator.Struct(i)
+	var fields validator.ValidationErrors
+	if !errors.As(err, &fields) {
+		return err
 	}
-	return nil
+	violations := make([]apierror.FieldViolation, 0, len(fields))
+	for _, f := range fields {
+		// the namespace starts with the type name, which means nothing to
+		// clients
+		_, field, _ := strings.Cut(f.Namespace(), ".")
+		violations = append(violations, apierror.FieldViolation{
+			Field: field,
+			Rule:  f.Tag(),
+			Param: f.Param(),
+		})
+	}
+	return apierror.New(http.StatusBadRequest, apierror.CodeValidationFailed, "Request is invalid").
+		WithDetails(violations).
+		WithInternal(err)
 }
 
-func (s *server) Start(ctx context.Context) error {
-	_, err := v1.New(s.e)
+// ErrorHandler answers every failed request with an apierror.Error carrying
+// the request id, in the format the request accepts.
+func ErrorHandler(err error, c echo.Context) {
+	e := apierror.From(err)
+	e.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+	if e.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(c.Request().Context(), "request failed", "error", err, "request_id", e.RequestID)
+	}
+
+	if c.Response().Committed {
+		return
+	}
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(e.Status)
+	} else {
+		// an error is still sent to clients that accept none of its formats
+		format, ok := codec.For(c, e)
+		if !ok {
+			format = codec.JSON
+		}
+		err = format.Render(c, e.Status, e)
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request().Context(), "could not send error response", "error", err, "request_id", e.RequestID)
+	}
+}
+
+// Listen binds the server to port without serving yet, so the caller knows
+// the port is usable before it retires a previous server.
+func (s *server) Listen(port uint) error {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		return err
 	}
+	if s.tls != nil {
+		s.e.TLSListener = tls.NewListener(ln, s.tls)
+		return nil
+	}
+	s.e.Listener = ln
+	return nil
+}
 
-	return s.e.Start(fmt.Sprintf(":%d", config.Confs.Server.Port))
+// Start serves on the port bound by Listen until the server is shut down.
+func (s *server) Start(ctx context.Context) error {
+	if s.e.Listener == nil && s.e.TLSListener == nil {
+		return errors.New("handlers: Listen must be called before Start")
+	}
+	// echo serves on the listener and only falls back to the address when
+	// there is none
+	addr := ""
+	var err error
+	if s.tls != nil {
+		s.e.TLSServer.Addr = addr
+		s.e.TLSServer.TLSConfig = s.tls
+		err = s.e.StartServer(s.e.TLSServer)
+	} else {
+		err = s.e.Start(addr)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
 
 func (s *server) Shutdown(ctx context.Context) error {
-	return s.e.Shutdown(ctx)
+	err := s.e.Shutdown(ctx)
+	// a server that never started still holds the listener from Listen
+	for _, ln := range []net.Listener{s.e.Listener, s.e.TLSListener} {
+		if ln != nil {
+			ln.Close()
+		}
+	}
+	return err
 }
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/metrics"
+	"github.com/labstack/echo"
+)
+
+const (
+	pathMetrics = "/metrics"
+	pathHealthz = "/healthz"
+	pathReadyz  = "/readyz"
+)
+
+// Readiness tells /readyz whether the server should get traffic. It is
+// shared across reloads so the caller can flip it around a server swap.
+type Readiness struct {
+	ready atomic.Bool
+}
+
+func (r *Readiness) Set(ready bool) {
+	r.ready.Store(ready)
+}
+
+func (r *Readiness) Ready() bool {
+	return r.ready.Load()
+}
+
+// isProbe reports whether c is for one of the operational routes, which
+// skip auth and rate limits so monitoring keeps working.
+func isProbe(c echo.Context) bool {
+	switch c.Path() {
+	case pathMetrics, pathHealthz, pathReadyz:
+		return true
+	}
+	return false
+}
+
+// Metrics records the count and latency of every request by route pattern
+// and status.
+func Metrics(m *metrics.MetricsService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			m.ObserveRequest(c.Request().Method, route, statusOf(c, err), time.Since(start))
+			return err
+		}
+	}
+}
+
+func (s *server) probes() {
+	if s.metrics != nil {
+		s.e.GET(pathMetrics, echo.WrapHandler(s.metrics.Handler()))
+	}
+	s.e.GET(pathHealthz, func(c echo.Context) error {
+		return c.JSON(http.StatusOK, echo.Map{"status": "ok"})
+	})
+	s.e.GET(pathReadyz, func(c echo.Context) error {
+		if s.ready != nil && !s.ready.Ready() {
+			return c.JSON(http.StatusServiceUnavailable, echo.Map{"status": "not ready"})
+		}
+		return c.JSON(http.StatusOK, echo.Map{"status": "ready"})
+	})
+}
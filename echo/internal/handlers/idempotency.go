@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/auth"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/idempotency"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"github.com/labstack/echo"
+)
+
+const (
+	headerIdempotencyKey = "Idempotency-Key"
+	headerReplayed       = "Idempotent-Replayed"
+	maxIdempotencyKey    = 255
+)
+
+// recorder keeps a copy of everything written to the response.
+type recorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// Idempotency replays the stored response when a mutating request is sent
+// again with the same Idempotency-Key, and rejects the key if it comes with
+// a different request. Keys are kept apart per principal, or per client
+// address without auth.
+func Idempotency(store *idempotency.Store) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			key := req.Header.Get(headerIdempotencyKey)
+			if key == "" || !isWrite(req.Method) || !store.Enabled() {
+				return next(c)
+			}
+			if len(key) > maxIdempotencyKey {
+				return apierror.New(http.StatusBadRequest, apierror.CodeInvalidIdempotencyKey, "Idempotency key is too long")
+			}
+
+			// a key only means something to the client that chose it, so
+			// another client reusing it gets its own
+			scope := "ip:" + client(c)
+			if p, ok := c.Get(principalKey).(auth.Principal); ok {
+				scope = "key:" + p.Name
+			}
+			key = scope + " " + key
+
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return err
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			sum := sha256.New()
+			io.WriteString(sum, req.Method+" "+req.URL.RequestURI()+"\n")
+			sum.Write(body)
+
+			stored, err := store.Begin(key, hex.EncodeToString(sum.Sum(nil)))
+			if err != nil {
+				return err
+			}
+			if stored != nil {
+				res := c.Response()
+				for k, v := range stored.Header {
+					// the retry keeps its own request id
+					if k == http.CanonicalHeaderKey(echo.HeaderXRequestID) {
+						continue
+					}
+					res.Header()[k] = v
+				}
+				res.Header().Set(headerReplayed, "true")
+				res.WriteHeader(stored.Status)
+				_, err := res.Write(stored.Body)
+				return err
+			}
+
+			rec := &recorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = rec
+			// write errors out here so the stored response is the one sent
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+
+			res := c.Response()
+			if res.Status >= http.StatusInternalServerError {
+				store.Abort(key)
+				return nil
+			}
+			store.Finish(key, idempotency.Response{
+				Status: res.Status,
+				Header: res.Header().Clone(),
+				Body:   rec.body.Bytes(),
+			})
+			return nil
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/idempotency"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+)
+
+// idempotentServer counts the requests that got past Idempotency and
+// answers each with the count.
+func idempotentServer(t *testing.T) (*echo.Echo, *int) {
+	t.Helper()
+	store, err := idempotency.New(idempotency.WithTTL(time.Minute))
+	if err != nil {
+		t.Fatalf("idempotency.New() error = %v", err)
+	}
+	calls := new(int)
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	e.Use(ClientIP(nil))
+	e.Use(Idempotency(store))
+	e.POST("/numbers", func(c echo.Context) error {
+		*calls++
+		return c.String(http.StatusCreated, strconv.Itoa(*calls))
+	})
+	return e, calls
+}
+
+func post(e *echo.Echo, remote, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/numbers", strings.NewReader(body))
+	req.RemoteAddr = remote
+	req.Header.Set(headerIdempotencyKey, key)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyScopedByClient(t *testing.T) {
+	e, calls := idempotentServer(t)
+
+	post(e, "203.0.113.1:1000", "k", "1")
+	// the same key from someone else is a new request, not a replay of
+	// the first client's response
+	rec := post(e, "203.0.113.2:1000", "k", "1")
+	if rec.Header().Get(headerReplayed) != "" || rec.Body.String() != "2" {
+		t.Errorf("Other client got %q replayed=%q, want its own response", rec.Body, rec.Header().Get(headerReplayed))
+	}
+	// nor does a different body from them clash with it
+	rec = post(e, "203.0.113.3:1000", "k", "other")
+	if rec.Code != http.StatusCreated {
+		t.Errorf("Other client with another body = %d, want 201", rec.Code)
+	}
+	if *calls != 3 {
+		t.Errorf("Handler ran %d times, want 3", *calls)
+	}
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	e, calls := idempotentServer(t)
+
+	first := post(e, "203.0.113.1:1000", "k", "1")
+	again := post(e, "203.0.113.1:1000", "k", "1")
+	if *calls != 1 {
+		t.Fatalf("Handler ran %d times, want once", *calls)
+	}
+	if again.Code != first.Code || again.Body.String() != first.Body.String() || again.Header().Get(headerReplayed) != "true" {
+		t.Errorf("Retry = %d %q replayed=%q, want the first response replayed", again.Code, again.Body, again.Header().Get(headerReplayed))
+	}
+	if first.Header().Get(headerReplayed) != "" {
+		t.Errorf("First response is marked as replayed")
+	}
+
+	rec := post(e, "203.0.113.1:1000", "k", "2")
+	if rec.Code != http.StatusUnprocessableEntity || *calls != 1 {
+		t.Errorf("Key reused with another body = %d after %d calls, want 422 and no call", rec.Code, *calls)
+	}
+	// without a key nothing is kept
+	post(e, "203.0.113.1:1000", "", "1")
+	post(e, "203.0.113.1:1000", "", "1")
+	if *calls != 3 {
+		t.Errorf("Handler ran %d times, want 3", *calls)
+	}
+}
+
+func TestIdempotencyRequestID(t *testing.T) {
+	e, _ := idempotentServer(t)
+	e.Pre(middleware.RequestID())
+
+	send := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/numbers", strings.NewReader(body))
+		req.Header.Set(headerIdempotencyKey, "k")
+		req.Header.Set(echo.HeaderXRequestID, id)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	// every response, replayed or refused, carries the id of the request
+	// it answers
+	for _, tt := range []struct {
+		id, body string
+	}{{"first", "1"}, {"retry", "1"}, {"mismatch", "2"}} {
+		rec := send(tt.id, tt.body)
+		if got := rec.Header().Get(echo.HeaderXRequestID); got != tt.id {
+			t.Errorf("Response to %s has request id %q", tt.id, got)
+		}
+		if rec.Code >= http.StatusBadRequest && !strings.Contains(rec.Body.String(), `"request_id":"`+tt.id+`"`) {
+			t.Errorf("Error for %s = %s, want its request id", tt.id, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/codec"
+	v1 "github.com/alipourhabibi/exercises-journal/echo/internal/handlers/v1"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/labstack/echo"
+)
+
+func init() {
+	// the spec describes bodies as JSON; the other formats are decoded to
+	// the same values so the schemas check them all alike
+	for _, c := range codec.Codecs {
+		if c == codec.JSON {
+			continue
+		}
+		openapi3filter.RegisterBodyDecoder(c.MediaType, func(body io.Reader, _ http.Header, _ *openapi3.SchemaRef, _ openapi3filter.EncodingFn) (any, error) {
+			data, err := io.ReadAll(body)
+			if err != nil {
+				return nil, err
+			}
+			return c.Decode(data)
+		})
+	}
+}
+
+// specOperation returns the operation doc documents for the route of c,
+// or nil if it has none.
+func specOperation(doc *openapi3.T, c echo.Context) (string, *openapi3.PathItem, *openapi3.Operation) {
+	path := v1.SpecPath(c.Path())
+	item := doc.Paths.Value(path)
+	if item == nil {
+		return path, nil, nil
+	}
+	return path, item, item.GetOperation(c.Request().Method)
+}
+
+// ContentNegotiation answers 415 to bodies sent in a media type doc doesn't
+// document for their route, and 406 to requests accepting none of the
+// media types of its successful responses, before any work is done.
+// Streamed bodies pick their own format and are left to their handler.
+func ContentNegotiation(doc *openapi3.T) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			_, _, op := specOperation(doc, c)
+			if op == nil {
+				return next(c)
+			}
+			req := c.Request()
+
+			body := op.RequestBody
+			if body != nil && req.ContentLength != 0 && op.Extensions[v1.StreamedBody] != true {
+				mediaType, _, _ := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType))
+				if body.Value.Content.Get(mediaType) == nil {
+					return codec.UnsupportedMediaType(mediaTypes(body.Value.Content))
+				}
+			}
+
+			responses := openapi3.Content{}
+			for status, res := range op.Responses.Map() {
+				if strings.HasPrefix(status, "2") && res.Value != nil {
+					for t, mt := range res.Value.Content {
+						responses[t] = mt
+					}
+				}
+			}
+			offers := mediaTypes(responses)
+			if len(offers) > 0 && codec.Negotiate(req.Header.Get(echo.HeaderAccept), offers) == "" {
+				return codec.NotAcceptable(offers)
+			}
+			return next(c)
+		}
+	}
+}
+
+// mediaTypes lists content in the order of codec.Codecs, then the rest
+// sorted.
+func mediaTypes(content openapi3.Content) []string {
+	var types []string
+	for _, c := range codec.Codecs {
+		if content[c.MediaType] != nil {
+			types = append(types, c.MediaType)
+		}
+	}
+	var rest []string
+	for t := range content {
+		if !slices.Contains(types, t) {
+			rest = append(rest, t)
+		}
+	}
+	slices.Sort(rest)
+	return append(types, rest...)
+}
+
+// RequestValidation answers 400 to requests that don't match the operation
+// doc documents for their route. Routes doc doesn't know are let through.
+func RequestValidation(doc *openapi3.T) echo.MiddlewareFunc {
+	options := &openapi3filter.Options{
+		// the Auth middleware checks credentials
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+	}
+	// the handler validates a streamed body as it reads it
+	streamed := *options
+	streamed.ExcludeRequestBody = true
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			path, item, op := specOperation(doc, c)
+			if op == nil {
+				return next(c)
+			}
+
+			opts := options
+			if op.Extensions[v1.StreamedBody] == true {
+				opts = &streamed
+			}
+
+			params := map[string]string{}
+			values := c.ParamValues()
+			for i, name := range c.ParamNames() {
+				params[name] = values[i]
+			}
+			err := openapi3filter.ValidateRequest(req.Context(), &openapi3filter.RequestValidationInput{
+				Request:    req,
+				PathParams: params,
+				Route: &routers.Route{
+					Spec:      doc,
+					Path:      path,
+					PathItem:  item,
+					Method:    req.Method,
+					Operation: op,
+				},
+				Options: opts,
+			})
+			if err != nil {
+				return apierror.New(http.StatusBadRequest, apierror.CodeInvalidRequest, validationMessage(err)).WithInternal(err)
+			}
+			return next(c)
+		}
+	}
+}
+
+// validationMessage says what was wrong with a request without dumping the
+// schema like the error itself does.
+func validationMessage(err error) string {
+	var re *openapi3filter.RequestError
+	if !errors.As(err, &re) {
+		return "Invalid request"
+	}
+	where := "request body"
+	if re.Parameter != nil {
+		where = fmt.Sprintf("%s parameter %q", re.Parameter.In, re.Parameter.Name)
+	}
+
+	detail := re.Reason
+	var se *openapi3.SchemaError
+	if errors.As(err, &se) {
+		detail = se.Reason
+		if p := se.JSONPointer(); len(p) > 0 {
+			detail = "/" + strings.Join(p, "/") + ": " + detail
+		}
+	} else if re.Err != nil {
+		detail = re.Err.Error()
+	}
+	return fmt.Sprintf("Invalid %s: %s", where, detail)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/auth"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/ratelimit"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"github.com/labstack/echo"
+)
+
+// RateLimit answers 429 once a client used up its read or write budget.
+// Clients are told apart as the limiter's KeyBy says.
+func RateLimit(l *ratelimit.LimiterService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if isProbe(c) || !l.Enabled() {
+				return next(c)
+			}
+
+			client := "ip:" + client(c)
+			if p, ok := c.Get(principalKey).(auth.Principal); ok && l.KeyBy() == ratelimit.KeyByAPIKey {
+				client = "key:" + p.Name
+			}
+
+			ok, wait := l.Allow(client, isWrite(c.Request().Method))
+			if !ok {
+				seconds := int(math.Ceil(wait.Seconds()))
+				c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+				return apierror.New(http.StatusTooManyRequests, apierror.CodeRateLimited, "Too many requests").
+					WithDetails(map[string]int{"retry_after": seconds})
+			}
+			return next(c)
+		}
+	}
+}
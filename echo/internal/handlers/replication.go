@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/replication"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"github.com/labstack/echo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// replicationKeepAlive is how often an idle log stream gets an empty line
+// so proxies keep it open.
+const replicationKeepAlive = 15 * time.Second
+
+// ReadOnly guards the lists of a follower: writes to the API are sent on to
+// leader when forward is set and refused otherwise.
+func ReadOnly(leader *url.URL, forward bool) echo.MiddlewareFunc {
+	proxy := httputil.NewSingleHostReverseProxy(leader)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !isWrite(c.Request().Method) || !strings.HasPrefix(c.Path(), "/api/") {
+				return next(c)
+			}
+			if forward {
+				// the leader's spans join the trace of this request
+				otel.GetTextMapPropagator().Inject(c.Request().Context(), propagation.HeaderCarrier(c.Request().Header))
+				proxy.ServeHTTP(c.Response(), c.Request())
+				return nil
+			}
+			return apierror.New(http.StatusMisdirectedRequest, apierror.CodeReadOnly, "This is a follower; send writes to the leader").
+				WithDetails(map[string]string{"leader": leader.String()})
+		}
+	}
+}
+
+// replication serves the snapshot and the log of the leader to followers.
+func (s *server) replication() {
+	closing := make(chan struct{})
+	// like the v1 streams, end on the shutdown of either server
+	var once sync.Once
+	stop := func() {
+		once.Do(func() { close(closing) })
+	}
+	s.e.Server.RegisterOnShutdown(stop)
+	s.e.TLSServer.RegisterOnShutdown(stop)
+
+	s.e.GET(replication.PathSnapshot, func(c echo.Context) error {
+		return c.JSON(http.StatusOK, s.leader.Snapshot())
+	})
+	s.e.GET(replication.PathLog, func(c echo.Context) error {
+		after, err := strconv.ParseUint(c.QueryParam("after"), 10, 64)
+		if err != nil {
+			return apierror.New(http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid after").WithInternal(err)
+		}
+		sub, err := s.leader.Resume(c.QueryParam("run"), after)
+		if err != nil {
+			return err
+		}
+		defer sub.Close()
+
+		res := c.Response()
+		res.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+		res.WriteHeader(http.StatusOK)
+		enc := json.NewEncoder(res)
+		for _, e := range sub.Replay {
+			if err := enc.Encode(e); err != nil {
+				return nil
+			}
+		}
+		res.Flush()
+
+		ping := time.NewTicker(replicationKeepAlive)
+		defer ping.Stop()
+		for {
+			select {
+			case e, ok := <-sub.C:
+				if !ok {
+					// too far behind; the follower resumes from the kept
+					// entries
+					return nil
+				}
+				if err := enc.Encode(e); err != nil {
+					return nil
+				}
+			case <-ping.C:
+				if _, err := res.Write([]byte("\n")); err != nil {
+					return nil
+				}
+			case <-c.Request().Context().Done():
+				return nil
+			case <-closing:
+				return nil
+			}
+			res.Flush()
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/tracing"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"github.com/labstack/echo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestAccessLog(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prevProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	var out bytes.Buffer
+	prevLogger := slog.Default()
+	slog.SetDefault(slog.New(tracing.NewLogHandler(slog.NewTextHandler(&out, nil))))
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		slog.SetDefault(prevLogger)
+	})
+
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	e.Use(ClientIP(nil), Tracing(), AccessLog())
+	e.GET("/missing", func(c echo.Context) error {
+		return apierror.New(http.StatusNotFound, apierror.CodeNotFound, "Nothing here")
+	})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("GET = %d, want 404", rec.Code)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Recorded %d spans, want 1", len(spans))
+	}
+	status := attribute.Int("http.response.status_code", http.StatusNotFound)
+	found := false
+	for _, a := range spans[0].Attributes() {
+		found = found || a == status
+	}
+	if !found {
+		t.Errorf("Span attributes %v lack %v", spans[0].Attributes(), status)
+	}
+
+	line := out.String()
+	for _, want := range []string{
+		"msg=request",
+		"status=404",
+		"error=",
+		"trace_id=" + spans[0].SpanContext().TraceID().String(),
+		"span_id=" + spans[0].SpanContext().SpanID().String(),
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("Access log %q lacks %q", line, want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package v1
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/audit"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/codec"
+	"github.com/labstack/echo"
+)
+
+const (
+	// ActorKey holds, in the echo context, the name of whoever
+	// authenticated the request. Without it changes are put down to the
+	// client ip.
+	ActorKey = "actor"
+	// ClientKey holds the ip address of the client, past any trusted
+	// proxies.
+	ClientKey = "client"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type auditResponse struct {
+	Records []audit.Record `json:"records"`
+	// NextBefore is passed back as before to get the following page
+	NextBefore *uint64 `json:"next_before,omitempty"`
+}
+
+// change runs fn, a change to a list, and logs what it did to the audit
+// log. fn must make the change with the context of the request, which
+// gathers its events.
+func (s *server) change(c echo.Context, fn func(match list.Match) (uint64, error)) (uint64, error) {
+	if s.audit == nil {
+		return fn(ifMatch(c))
+	}
+	ctx, events := list.Collect(c.Request().Context())
+	c.SetRequest(c.Request().WithContext(ctx))
+	version, err := fn(ifMatch(c))
+	if err != nil {
+		return version, err
+	}
+	actor, _ := c.Get(ActorKey).(string)
+	if actor == "" {
+		actor, _ = c.Get(ClientKey).(string)
+	}
+	req := audit.Request{
+		Actor:     actor,
+		RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+		List:      c.Param("name"),
+	}
+	// the change is made, so failing to log it mustn't fail the request
+	if err := s.audit.Commit(events.Events(), req); err != nil {
+		slog.ErrorContext(c.Request().Context(), "could not write audit records", "error", err, "request_id", req.RequestID)
+	}
+	return version, nil
+}
+
+// timeParam reads an optional RFC 3339 query parameter.
+func timeParam(c echo.Context, name string) (time.Time, error) {
+	s := c.QueryParam(name)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return t, invalidParam(name, err)
+	}
+	return t, nil
+}
+
+// Audit returns the audit records matching the query parameters, newest
+// first, a page at a time.
+func (s *server) Audit(c echo.Context) error {
+	if s.audit == nil {
+		return apierror.New(http.StatusNotFound, apierror.CodeNotFound, "Audit log is off")
+	}
+	f := audit.Filter{
+		Actor:     c.QueryParam("actor"),
+		RequestID: c.QueryParam("request_id"),
+		List:      c.QueryParam("list"),
+		Op:        c.QueryParam("op"),
+		Limit:     defaultAuditLimit,
+	}
+	var err error
+	if f.Since, err = timeParam(c, "since"); err != nil {
+		return err
+	}
+	if f.Until, err = timeParam(c, "until"); err != nil {
+		return err
+	}
+	if raw := c.QueryParam("before"); raw != "" {
+		f.Before, err = strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return invalidParam("before", err)
+		}
+	}
+	limit, err := uintParam(c, "limit")
+	if err != nil {
+		return err
+	}
+	if limit != nil {
+		if *limit == 0 || *limit > maxAuditLimit {
+			return apierror.New(http.StatusBadRequest, apierror.CodeInvalidRequest,
+				"Limit must be between 1 and "+strconv.Itoa(maxAuditLimit))
+		}
+		f.Limit = int(*limit)
+	}
+
+	records, more, err := s.audit.Query(f)
+	if err != nil {
+		return err
+	}
+	res := auditResponse{Records: records}
+	if more {
+		next := records[len(records)-1].Seq
+		res.NextBefore = &next
+	}
+	return codec.Render(c, http.StatusOK, res)
+}
@@ -0,0 +1,47 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/codec"
+	"github.com/labstack/echo"
+)
+
+type batchRequest struct {
+	Operations []list.Operation `json:"operations" validate:"required,min=1,dive"`
+}
+
+type batchResponse struct {
+	Applied int `json:"applied"`
+}
+
+func (s *server) Batch(c echo.Context) error {
+	// see numbers() for why the suffix arrives as a parameter
+	if c.Param("batch") != ":batch" {
+		return echo.ErrNotFound
+	}
+
+	data := batchRequest{}
+	if err := c.Bind(&data); err != nil {
+		return err
+	}
+	if err := c.Validate(&data); err != nil {
+		return err
+	}
+	l, err := s.listFor(c)
+	if err != nil {
+		return err
+	}
+
+	version, err := s.change(c, func(match list.Match) (uint64, error) {
+		return l.Batch(c.Request().Context(), data.Operations, match)
+	})
+	setETag(c, version)
+	if err != nil {
+		// a list.BatchError names the failed operation in the details
+		return err
+	}
+
+	return codec.Render(c, http.StatusOK, batchResponse{Applied: len(data.Operations)})
+}
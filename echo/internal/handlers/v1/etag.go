@@ -0,0 +1,51 @@
+package v1
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/labstack/echo"
+)
+
+// etag renders a list version as a strong entity tag.
+func etag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+func setETag(c echo.Context, version uint64) {
+	c.Response().Header().Set("ETag", etag(version))
+}
+
+// tagsMatch reports whether header, an If-Match or If-None-Match value,
+// lists the tag of version. Weak tags never match since both headers are
+// used here for exact versions.
+func tagsMatch(header string, version uint64) bool {
+	want := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == want {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatch turns the If-Match header into a list precondition; without the
+// header every version is accepted.
+func ifMatch(c echo.Context) list.Match {
+	header := c.Request().Header.Get("If-Match")
+	if header == "" {
+		return nil
+	}
+	return func(version uint64) bool {
+		return tagsMatch(header, version)
+	}
+}
+
+// notModified reports whether the If-None-Match header already names
+// version, in which case the caller should answer 304.
+func notModified(c echo.Context, version uint64) bool {
+	header := c.Request().Header.Get("If-None-Match")
+	return header != "" && tagsMatch(header, version)
+}
@@ -0,0 +1,167 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"github.com/labstack/echo"
+	"golang.org/x/net/websocket"
+)
+
+// keepAlive is how often an idle stream gets a ping so proxies keep it open.
+const keepAlive = 15 * time.Second
+
+// opReset tells a resuming client that events were missed and it should
+// read the list again; the event version is where the stream continues from.
+const opReset = "reset"
+
+// subscribe follows the feed of l, resuming after lastID if it is set. When
+// the events after lastID are gone the subscription starts from now and
+// reset is true.
+func subscribe(l *list.ListService, lastID string) (sub *list.Subscription, reset bool, err error) {
+	feed := l.Feed()
+	if feed == nil {
+		return nil, false, apierror.New(http.StatusNotFound, apierror.CodeNoEventFeed, "List has no event feed")
+	}
+	if lastID == "" {
+		return feed.Subscribe(), false, nil
+	}
+	version, err := strconv.ParseUint(lastID, 10, 64)
+	if err != nil {
+		return nil, false, apierror.New(http.StatusBadRequest, apierror.CodeInvalidEventID, "Invalid event id").WithInternal(err)
+	}
+	sub, err = feed.Resume(version)
+	if errors.Is(err, list.ErrEventsGone) {
+		return feed.Subscribe(), true, nil
+	}
+	return sub, false, err
+}
+
+// Events streams the changes of a list as Server-Sent Events. The event id
+// is the list version, so a reconnecting client resumes with Last-Event-ID.
+func (s *server) Events(c echo.Context) error {
+	l, err := s.listFor(c)
+	if err != nil {
+		return err
+	}
+	sub, reset, err := subscribe(l, c.Request().Header.Get("Last-Event-ID"))
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.WriteHeader(http.StatusOK)
+
+	send := func(e list.Event) error {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", e.Version, e.Op, data)
+		return err
+	}
+
+	if reset {
+		if err := send(list.Event{Op: opReset, Version: sub.From}); err != nil {
+			return nil
+		}
+	}
+	for _, e := range sub.Replay {
+		if err := send(e); err != nil {
+			return nil
+		}
+	}
+	res.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				// fell behind; the client reconnects and resumes
+				return nil
+			}
+			if err := send(e); err != nil {
+				return nil
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(res, ": ping\n\n"); err != nil {
+				return nil
+			}
+		case <-c.Request().Context().Done():
+			return nil
+		case <-s.closing:
+			return nil
+		}
+		res.Flush()
+	}
+}
+
+// EventsWebSocket streams the same events as Events as JSON messages. A
+// client resumes with the last_event_id query parameter, since browsers
+// can't set headers on a WebSocket handshake.
+func (s *server) EventsWebSocket(c echo.Context) error {
+	l, err := s.listFor(c)
+	if err != nil {
+		return err
+	}
+	lastID := c.QueryParam("last_event_id")
+	if lastID == "" {
+		lastID = c.Request().Header.Get("Last-Event-ID")
+	}
+	sub, reset, err := subscribe(l, lastID)
+	if err != nil {
+		return err
+	}
+
+	handler := func(ws *websocket.Conn) {
+		defer ws.Close()
+		defer sub.Close()
+
+		// the client doesn't send anything; reading only notices it leaving
+		gone := make(chan struct{})
+		go func() {
+			var msg []byte
+			for websocket.Message.Receive(ws, &msg) == nil {
+			}
+			close(gone)
+		}()
+
+		if reset {
+			if websocket.JSON.Send(ws, list.Event{Op: opReset, Version: sub.From}) != nil {
+				return
+			}
+		}
+		for _, e := range sub.Replay {
+			if websocket.JSON.Send(ws, e) != nil {
+				return
+			}
+		}
+		for {
+			select {
+			case e, ok := <-sub.C:
+				if !ok || websocket.JSON.Send(ws, e) != nil {
+					return
+				}
+			case <-gone:
+				return
+			case <-s.closing:
+				return
+			}
+		}
+	}
+	// websocket.Server skips the Origin check websocket.Handler would do
+	websocket.Server{Handler: handler}.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
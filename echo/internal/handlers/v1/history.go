@@ -0,0 +1,107 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/codec"
+	"github.com/labstack/echo"
+)
+
+const (
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+)
+
+type historyResponse struct {
+	Records []list.Record `json:"records"`
+	// NextAfter is passed back as after to get the following page
+	NextAfter *uint64 `json:"next_after,omitempty"`
+	// Oldest is the earliest version that can still be read
+	Oldest  uint64 `json:"oldest"`
+	Version uint64 `json:"version"`
+}
+
+// readListFor returns the list a read route works on, as it was at the
+// version or time the version or at query parameter names, if any.
+func (s *server) readListFor(c echo.Context) (*list.ListService, error) {
+	l, err := s.listFor(c)
+	if err != nil {
+		return nil, err
+	}
+	rawVersion, rawAt := c.QueryParam("version"), c.QueryParam("at")
+	switch {
+	case rawVersion != "" && rawAt != "":
+		return nil, apierror.New(http.StatusBadRequest, apierror.CodeInvalidRequest, "Only one of version and at may be given")
+	case rawVersion != "":
+		version, err := strconv.ParseUint(rawVersion, 10, 64)
+		if err != nil {
+			return nil, invalidParam("version", err)
+		}
+		return l.AsOf(version)
+	case rawAt != "":
+		at, err := time.Parse(time.RFC3339Nano, rawAt)
+		if err != nil {
+			return nil, invalidParam("at", err)
+		}
+		version, err := l.VersionAt(at)
+		if err != nil {
+			return nil, err
+		}
+		return l.AsOf(version)
+	}
+	return l, nil
+}
+
+// History returns the changes made to the list after the after query
+// parameter, a page at a time, oldest first.
+func (s *server) History(c echo.Context) error {
+	after := uint64(0)
+	if raw := c.QueryParam("after"); raw != "" {
+		var err error
+		after, err = strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return invalidParam("after", err)
+		}
+	}
+	limit, err := uintParam(c, "limit")
+	if err != nil {
+		return err
+	}
+	n := defaultHistoryLimit
+	if limit != nil {
+		if *limit == 0 || *limit > maxHistoryLimit {
+			return apierror.New(http.StatusBadRequest, apierror.CodeInvalidRequest,
+				"Limit must be between 1 and "+strconv.Itoa(maxHistoryLimit))
+		}
+		n = int(*limit)
+	}
+	l, err := s.listFor(c)
+	if err != nil {
+		return err
+	}
+	h := l.History()
+	if h == nil {
+		return list.ErrNoHistory
+	}
+
+	records, more, err := h.Records(after, n)
+	if err != nil {
+		return err
+	}
+	// read after the records, so none is newer than it
+	version := l.Version()
+	res := historyResponse{
+		Records: records,
+		Oldest:  h.Oldest(),
+		Version: version,
+	}
+	if more && len(records) > 0 {
+		next := records[len(records)-1].Version
+		res.NextAfter = &next
+	}
+	return codec.Render(c, http.StatusOK, res)
+}
@@ -1,35 +1,109 @@
 package v1
 
 import (
-	"fmt"
 	"net/http"
 	"strconv"
+	"sync"
 
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/audit"
 	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/webhook"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/codec"
 	"github.com/labstack/echo"
 )
 
 type server struct {
-	list *list.ListService
+	list  *list.ListService
+	lists *list.Registry
+	// audit logs who changed what; nil turns it off
+	audit *audit.Log
+	// webhooks sends the changes to subscribers; nil turns them off
+	webhooks *webhook.Dispatcher
+	// closing is closed when the http server shuts down so streams end
+	// instead of holding the shutdown up
+	closing chan struct{}
 }
 
-func New(e *echo.Echo) (*server, error) {
-	s := &server{}
-	l, err := list.New(
-		list.BootList(),
-	)
-	if err != nil {
-		return nil, err
+// New registers the v1 routes. /numbers works on l, and the same number
+// routes under /lists/:name work on the named lists held by r. Changes made
+// through them are logged to a, and hooks manages the webhooks; either may
+// be nil.
+func New(e *echo.Echo, l *list.ListService, r *list.Registry, a *audit.Log, hooks *webhook.Dispatcher) *server {
+	s := &server{
+		list:     l,
+		lists:    r,
+		audit:    a,
+		webhooks: hooks,
+		closing:  make(chan struct{}),
+	}
+	// echo shuts the TLS server down first and waits for its connections,
+	// so streams on either must end as soon as shutdown starts
+	var once sync.Once
+	stop := func() {
+		once.Do(func() { close(s.closing) })
 	}
-	s.list = l
+	e.Server.RegisterOnShutdown(stop)
+	e.TLSServer.RegisterOnShutdown(stop)
 	v1 := e.Group("/api/v1")
 
-	v1.PUT("/numbers", s.Insert)
-	v1.DELETE("/numbers/:index", s.Remove)
-	v1.GET("/numbers/value/:value", s.Find)
-	v1.GET("/numbers/index/:index", s.Get)
+	s.numbers(v1)
+
+	// echo's router drops the handlers of /lists/:name if routes below it
+	// are added afterwards, so the nested ones go first
+	s.numbers(v1.Group("/lists/:name"))
+	v1.GET("/lists", s.Lists)
+	v1.POST("/lists", s.CreateList)
+	v1.PATCH("/lists/:name", s.RenameList)
+	v1.DELETE("/lists/:name", s.DeleteList)
+	v1.GET("/audit", s.Audit)
+	v1.GET("/webhooks/:id/dead-letters", s.DeadLetters)
+	v1.GET("/webhooks", s.Webhooks)
+	v1.POST("/webhooks", s.CreateWebhook)
+	v1.DELETE("/webhooks/:id", s.DeleteWebhook)
+	v1.GET("/openapi.json", s.OpenAPI)
+
+	return s
+}
+
+func (s *server) numbers(g *echo.Group) {
+	g.PUT("/numbers", s.Insert)
+	// echo can't escape ':' in a route, so this registers /numbers followed
+	// by a parameter named batch; Batch checks it holds ":batch"
+	g.POST("/numbers:batch", s.Batch)
+	g.DELETE("/numbers/:index", s.Remove)
+	g.GET("/numbers/value/:value", s.Find)
+	g.GET("/numbers/index/:index", s.Get)
+	g.GET("/numbers/search", s.Search)
+	g.GET("/numbers/export", s.Export)
+	g.GET("/numbers/history", s.History)
+	g.POST("/numbers/import", s.Import)
+	g.GET("/numbers/events", s.Events)
+	g.GET("/numbers/events/ws", s.EventsWebSocket)
+}
+
+// listFor returns the list a number route works on: the named list when the
+// route has a :name parameter and the global one otherwise.
+func (s *server) listFor(c echo.Context) (*list.ListService, error) {
+	name := c.Param("name")
+	if name == "" {
+		return s.list, nil
+	}
+	l, ok := s.lists.Get(name)
+	if !ok {
+		return nil, list.ErrListNotFound
+	}
+	return l, nil
+}
 
-	return s, nil
+// parseIndex reads the :index parameter; a malformed one is as invalid as
+// an out of range one.
+func parseIndex(c echo.Context) (uint, error) {
+	index, err := strconv.ParseUint(c.Param("index"), 10, 32)
+	if err != nil {
+		return 0, list.ErrInvalidIndex
+	}
+	return uint(index), nil
 }
 
 func (s *server) Insert(c echo.Context) error {
@@ -40,25 +114,37 @@ func (s *server) Insert(c echo.Context) error {
 	if err := c.Validate(&data); err != nil {
 		return err
 	}
+	l, err := s.listFor(c)
+	if err != nil {
+		return err
+	}
 
-	ok := s.list.Insert(data.Index, data.Value)
-	if !ok {
-		return echo.NewHTTPError(echo.ErrBadRequest.Code, "Invalid index")
+	version, err := s.change(c, func(match list.Match) (uint64, error) {
+		return l.Insert(c.Request().Context(), data.Index, data.Value, match)
+	})
+	setETag(c, version)
+	if err != nil {
+		return err
 	}
-	c.JSON(http.StatusCreated, data)
-	return nil
+	return codec.Render(c, http.StatusCreated, data)
 }
 
 func (s *server) Remove(c echo.Context) error {
-	indexStr := c.Param("index")
-	index, err := strconv.ParseUint(indexStr, 10, 32)
+	index, err := parseIndex(c)
 	if err != nil {
-		return echo.NewHTTPError(echo.ErrBadRequest.Code, "Invalid index")
+		return err
+	}
+	l, err := s.listFor(c)
+	if err != nil {
+		return err
 	}
 
-	ok := s.list.Remove(uint(index))
-	if !ok {
-		return echo.NewHTTPError(echo.ErrNotFound.Code, "Index not found")
+	version, err := s.change(c, func(match list.Match) (uint64, error) {
+		return l.Remove(c.Request().Context(), index, match)
+	})
+	setETag(c, version)
+	if err != nil {
+		return err
 	}
 
 	c.NoContent(http.StatusOK)
@@ -67,43 +153,53 @@ func (s *server) Remove(c echo.Context) error {
 }
 
 func (s *server) Find(c echo.Context) error {
-	valueStr := c.Param("value")
-	value, err := strconv.Atoi(valueStr)
+	value, err := strconv.Atoi(c.Param("value"))
 	if err != nil {
-		fmt.Println(err)
-		return echo.NewHTTPError(echo.ErrBadRequest.Code, "Invalid value")
+		return apierror.New(http.StatusBadRequest, apierror.CodeInvalidValue, "Invalid value").WithInternal(err)
+	}
+	l, err := s.readListFor(c)
+	if err != nil {
+		return err
 	}
 
-	index, ok := s.list.Find(value)
-	if !ok {
-		return echo.NewHTTPError(echo.ErrNotFound.Code, "Value not found")
+	index, version, err := l.Find(c.Request().Context(), value)
+	setETag(c, version)
+	if err != nil {
+		return err
+	}
+	if notModified(c, version) {
+		return c.NoContent(http.StatusNotModified)
 	}
 
 	data := list.ListEntity{
 		Index: index,
 		Value: value,
 	}
-	c.JSON(http.StatusOK, data)
-	return nil
+	return codec.Render(c, http.StatusOK, data)
 }
 
 func (s *server) Get(c echo.Context) error {
-
-	indexStr := c.Param("index")
-	index, err := strconv.ParseUint(indexStr, 10, 32)
+	index, err := parseIndex(c)
+	if err != nil {
+		return err
+	}
+	l, err := s.readListFor(c)
 	if err != nil {
-		return echo.NewHTTPError(echo.ErrBadRequest.Code, "Invalid index")
+		return err
 	}
 
-	value, ok := s.list.Get(uint(index))
-	if !ok {
-		return echo.NewHTTPError(echo.ErrNotFound.Code, "Index not found")
+	value, version, err := l.Get(c.Request().Context(), index)
+	setETag(c, version)
+	if err != nil {
+		return err
+	}
+	if notModified(c, version) {
+		return c.NoContent(http.StatusNotModified)
 	}
 	data := list.ListEntity{
-		Index: uint(index),
+		Index: index,
 		Value: value,
 	}
 
-	c.JSON(http.StatusOK, data)
-	return nil
+	return codec.Render(c, http.StatusOK, data)
 }
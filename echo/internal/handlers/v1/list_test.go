@@ -0,0 +1,117 @@
+package v1
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"github.com/go-playground/validator"
+	"github.com/labstack/echo"
+)
+
+type testValidator struct {
+	v *validator.Validate
+}
+
+func (tv testValidator) Validate(i any) error {
+	return tv.v.Struct(i)
+}
+
+// testServer serves the v1 routes on an empty global list.
+func testServer(t *testing.T) (*echo.Echo, *list.ListService) {
+	t.Helper()
+	l, err := list.New(list.BootList())
+	if err != nil {
+		t.Fatalf("Can't create list: %v", err)
+	}
+	r, err := list.NewRegistry()
+	if err != nil {
+		t.Fatalf("Can't create registry: %v", err)
+	}
+	e := echo.New()
+	e.Validator = testValidator{validator.New()}
+	e.HTTPErrorHandler = func(err error, c echo.Context) {
+		e := apierror.From(err)
+		c.JSON(e.Status, e)
+	}
+	New(e, l, r, nil, nil)
+	return e, l
+}
+
+// send serves a request with a JSON body, if any, and the given headers,
+// as name and value pairs.
+func send(e *echo.Echo, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, r)
+	if body != "" {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// get sends a GET for target with the If-None-Match header inm.
+func get(e *echo.Echo, target, inm string) *httptest.ResponseRecorder {
+	if inm == "" {
+		return send(e, http.MethodGet, target, "")
+	}
+	return send(e, http.MethodGet, target, "", "If-None-Match", inm)
+}
+
+func TestNotModifiedAfterErrors(t *testing.T) {
+	e, _ := testServer(t)
+	// nothing is at the version the client holds, which must not pass for
+	// an unchanged answer
+	for _, target := range []string{"/api/v1/numbers/index/3", "/api/v1/numbers/value/7"} {
+		rec := get(e, target, etag(0))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s with a current tag = %d, want 404", target, rec.Code)
+		}
+	}
+}
+
+func TestETags(t *testing.T) {
+	e, _ := testServer(t)
+
+	rec := send(e, http.MethodPut, "/api/v1/numbers", `{"index": 0, "value": 5}`)
+	if rec.Code != http.StatusCreated || rec.Header().Get("ETag") != etag(1) {
+		t.Fatalf("PUT = %d with ETag %q, want 201 with %s", rec.Code, rec.Header().Get("ETag"), etag(1))
+	}
+
+	// a client holding the current version is told nothing changed
+	for _, target := range []string{"/api/v1/numbers/index/0", "/api/v1/numbers/value/5"} {
+		rec := get(e, target, etag(1))
+		if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 || rec.Header().Get("ETag") != etag(1) {
+			t.Errorf("GET %s with the current tag = %d %q", target, rec.Code, rec.Body)
+		}
+		rec = get(e, target, `W/"1", `+etag(0))
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s with stale or weak tags = %d, want 200", target, rec.Code)
+		}
+	}
+
+	// writes based on a stale read are refused and change nothing
+	rec = send(e, http.MethodDelete, "/api/v1/numbers/0", "", "If-Match", etag(0))
+	if rec.Code != http.StatusPreconditionFailed || rec.Header().Get("ETag") != etag(1) {
+		t.Errorf("DELETE with a stale tag = %d with ETag %q, want 412 with %s", rec.Code, rec.Header().Get("ETag"), etag(1))
+	}
+	rec = send(e, http.MethodPut, "/api/v1/numbers", `{"index": 0, "value": 6}`, "If-Match", etag(0))
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("PUT with a stale tag = %d, want 412", rec.Code)
+	}
+	rec = send(e, http.MethodDelete, "/api/v1/numbers/0", "", "If-Match", etag(1))
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != etag(2) {
+		t.Errorf("DELETE with the current tag = %d with ETag %q, want 200 with %s", rec.Code, rec.Header().Get("ETag"), etag(2))
+	}
+}
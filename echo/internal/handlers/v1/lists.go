@@ -0,0 +1,77 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/codec"
+	"github.com/labstack/echo"
+)
+
+func (s *server) Lists(c echo.Context) error {
+	return codec.Render(c, http.StatusOK, s.lists.Info())
+}
+
+func (s *server) CreateList(c echo.Context) error {
+	data := list.ListInfo{}
+	if err := c.Bind(&data); err != nil {
+		return err
+	}
+	if err := c.Validate(&data); err != nil {
+		return err
+	}
+
+	l, err := s.lists.Create(data.Name, data.MaxSize)
+	if err != nil {
+		return err
+	}
+	data.Size = 0
+	data.MaxSize = l.MaxSize()
+	return codec.Render(c, http.StatusCreated, data)
+}
+
+// renameRequest is the body of a rename, which changes only the name. The
+// other fields of a ListInfo are bound to refuse them rather than drop them.
+type renameRequest struct {
+	Name    string `json:"name" validate:"required"`
+	Size    *uint  `json:"size,omitempty"`
+	MaxSize *uint  `json:"max_size,omitempty"`
+}
+
+func (s *server) RenameList(c echo.Context) error {
+	req := renameRequest{}
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+	if req.Size != nil || req.MaxSize != nil {
+		return apierror.New(http.StatusBadRequest, apierror.CodeInvalidRequest, "Only the name of a list can be changed")
+	}
+
+	err := s.lists.Rename(c.Param("name"), req.Name)
+	if err != nil {
+		return err
+	}
+	l, ok := s.lists.Get(req.Name)
+	if !ok {
+		// deleted right after the rename
+		return list.ErrListNotFound
+	}
+	return codec.Render(c, http.StatusOK, list.ListInfo{
+		Name:    req.Name,
+		Size:    l.Len(),
+		MaxSize: l.MaxSize(),
+	})
+}
+
+func (s *server) DeleteList(c echo.Context) error {
+	err := s.lists.Delete(c.Param("name"))
+	if err != nil {
+		return err
+	}
+	c.NoContent(http.StatusOK)
+	return nil
+}
@@ -0,0 +1,68 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+)
+
+func TestNamedLists(t *testing.T) {
+	e, global := testServer(t)
+
+	rec := send(e, http.MethodPost, "/api/v1/lists", `{"name": "a", "max_size": 1}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST lists = %d %s", rec.Code, rec.Body)
+	}
+	if rec := send(e, http.MethodPost, "/api/v1/lists", `{"name": "a"}`); rec.Code != http.StatusConflict {
+		t.Errorf("POST existing list = %d, want 409", rec.Code)
+	}
+
+	// number routes under a name work on that list alone, up to its limit
+	if rec := send(e, http.MethodPut, "/api/v1/lists/a/numbers", `{"index": 0, "value": 5}`); rec.Code != http.StatusCreated {
+		t.Fatalf("PUT into a = %d %s", rec.Code, rec.Body)
+	}
+	if rec := send(e, http.MethodPut, "/api/v1/lists/a/numbers", `{"index": 0, "value": 6}`); rec.Code != http.StatusConflict {
+		t.Errorf("PUT into full list = %d, want 409", rec.Code)
+	}
+	if global.Len() != 0 {
+		t.Errorf("Global list holds %d values, want none", global.Len())
+	}
+	if rec := send(e, http.MethodPut, "/api/v1/lists/b/numbers", `{"index": 0, "value": 5}`); rec.Code != http.StatusNotFound {
+		t.Errorf("PUT into a missing list = %d, want 404", rec.Code)
+	}
+
+	// a rename changes only the name, so other fields are refused
+	if rec := send(e, http.MethodPatch, "/api/v1/lists/a", `{"name": "b", "max_size": 10}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("PATCH a with max_size = %d, want 400", rec.Code)
+	}
+	if rec := get(e, "/api/v1/lists/a/numbers/index/0", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET from a after a refused rename = %d, want 200", rec.Code)
+	}
+
+	rec = send(e, http.MethodPatch, "/api/v1/lists/a", `{"name": "b"}`)
+	var info list.ListInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("PATCH a = %d %s", rec.Code, rec.Body)
+	}
+	if info != (list.ListInfo{Name: "b", Size: 1, MaxSize: 1}) {
+		t.Errorf("Renamed list = %+v", info)
+	}
+	if rec := get(e, "/api/v1/lists/b/numbers/index/0", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET from the renamed list = %d, want 200", rec.Code)
+	}
+
+	rec = get(e, "/api/v1/lists", "")
+	var infos []list.ListInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &infos); err != nil || len(infos) != 1 || infos[0].Name != "b" {
+		t.Errorf("GET lists = %s", rec.Body)
+	}
+
+	if rec := send(e, http.MethodDelete, "/api/v1/lists/b", ""); rec.Code != http.StatusOK {
+		t.Errorf("DELETE b = %d, want 200", rec.Code)
+	}
+	if rec := get(e, "/api/v1/lists/b/numbers/index/0", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET from a deleted list = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,543 @@
+package v1
+
+import (
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/codec"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/labstack/echo"
+)
+
+// Spec returns the OpenAPI document for the v1 routes. It is built here,
+// next to the routes, and openapi_test.go fails when the two drift apart.
+var Spec = sync.OnceValue(buildSpec)
+
+// StreamedBody marks operations that read their body as it arrives, which
+// request validation must leave alone.
+const StreamedBody = "x-streamed-body"
+
+var routeParam = regexp.MustCompile(`/:(\w+)`)
+
+// SpecPath turns an echo route like /numbers/:index into the OpenAPI path
+// /numbers/{index}. The ':' of /numbers:batch isn't a parameter to OpenAPI,
+// so only ':' right after a '/' is converted.
+func SpecPath(route string) string {
+	return routeParam.ReplaceAllString(route, "/{$1}")
+}
+
+func (s *server) OpenAPI(c echo.Context) error {
+	return c.JSON(http.StatusOK, Spec())
+}
+
+func schemaRef(name string, schema *openapi3.Schema) *openapi3.SchemaRef {
+	return openapi3.NewSchemaRef("#/components/schemas/"+name, schema)
+}
+
+func arrayOf(items *openapi3.SchemaRef) *openapi3.Schema {
+	a := openapi3.NewArraySchema()
+	a.Items = items
+	return a
+}
+
+var (
+	entitySchema = schemaRef("Entity", openapi3.NewObjectSchema().
+			WithProperty("index", openapi3.NewIntegerSchema().WithMin(0)).
+			WithProperty("value", openapi3.NewIntegerSchema()).
+			WithRequired([]string{"value"}))
+	listInfoSchema = schemaRef("ListInfo", openapi3.NewObjectSchema().
+			WithProperty("name", openapi3.NewStringSchema().WithPattern(`^[A-Za-z0-9_-]{1,64}$`)).
+			WithProperty("size", openapi3.NewIntegerSchema().WithMin(0)).
+			WithProperty("max_size", openapi3.NewIntegerSchema().WithMin(0)).
+			WithRequired([]string{"name"}))
+	renameSchema = schemaRef("Rename", openapi3.NewObjectSchema().
+			WithProperty("name", openapi3.NewStringSchema().WithPattern(`^[A-Za-z0-9_-]{1,64}$`)).
+			WithRequired([]string{"name"}).
+			WithoutAdditionalProperties())
+	operationSchema = schemaRef("Operation", openapi3.NewObjectSchema().
+			WithProperty("op", openapi3.NewStringSchema().WithEnum(list.OpInsert, list.OpRemove, list.OpSet)).
+			WithProperty("index", openapi3.NewIntegerSchema().WithMin(0)).
+			WithProperty("value", openapi3.NewIntegerSchema()).
+			WithRequired([]string{"op"}))
+	batchRequestSchema = schemaRef("BatchRequest", openapi3.NewObjectSchema().
+				WithProperty("operations", arrayOf(operationSchema).WithMinItems(1)).
+				WithRequired([]string{"operations"}))
+	batchResponseSchema = schemaRef("BatchResponse", openapi3.NewObjectSchema().
+				WithProperty("applied", openapi3.NewIntegerSchema()))
+	eventSchema = schemaRef("Event", openapi3.NewObjectSchema().
+			WithProperty("version", openapi3.NewIntegerSchema().WithMin(0)).
+			WithProperty("op", openapi3.NewStringSchema().WithEnum(list.OpInsert, list.OpRemove, list.OpSet, opReset)).
+			WithProperty("index", openapi3.NewIntegerSchema().WithMin(0)).
+			WithProperty("value", openapi3.NewIntegerSchema()))
+	searchSchema = schemaRef("SearchResult", openapi3.NewObjectSchema().
+			WithProperty("matches", arrayOf(entitySchema)).
+			WithProperty("next_cursor", openapi3.NewIntegerSchema().WithMin(0)).
+			WithProperty("count", openapi3.NewIntegerSchema().WithMin(0)).
+			WithProperty("version", openapi3.NewIntegerSchema().WithMin(0)))
+	importSchema = schemaRef("ImportResult", openapi3.NewObjectSchema().
+			WithProperty("imported", openapi3.NewIntegerSchema().WithMin(0)).
+			WithProperty("version", openapi3.NewIntegerSchema().WithMin(0)))
+	recordSchema = schemaRef("Record", openapi3.NewObjectSchema().
+			WithProperty("version", openapi3.NewIntegerSchema().WithMin(0)).
+			WithProperty("op", openapi3.NewStringSchema().WithEnum(list.OpInsert, list.OpRemove, list.OpSet)).
+			WithProperty("index", openapi3.NewIntegerSchema().WithMin(0)).
+			WithProperty("value", openapi3.NewIntegerSchema()).
+			WithProperty("time", openapi3.NewDateTimeSchema()))
+	historySchema = schemaRef("History", openapi3.NewObjectSchema().
+			WithProperty("records", arrayOf(recordSchema)).
+			WithProperty("next_after", openapi3.NewIntegerSchema().WithMin(0)).
+			WithProperty("oldest", openapi3.NewIntegerSchema().WithMin(0)).
+			WithProperty("version", openapi3.NewIntegerSchema().WithMin(0)))
+	auditRecordSchema = schemaRef("AuditRecord", openapi3.NewObjectSchema().
+				WithProperty("seq", openapi3.NewIntegerSchema().WithMin(1)).
+				WithProperty("time", openapi3.NewDateTimeSchema()).
+				WithProperty("actor", openapi3.NewStringSchema()).
+				WithProperty("request_id", openapi3.NewStringSchema()).
+				WithProperty("list", openapi3.NewStringSchema()).
+				WithProperty("op", openapi3.NewStringSchema().WithEnum(list.OpInsert, list.OpRemove, list.OpSet)).
+				WithProperty("index", openapi3.NewIntegerSchema().WithMin(0)).
+				WithProperty("old", openapi3.NewIntegerSchema()).
+				WithProperty("new", openapi3.NewIntegerSchema()).
+				WithProperty("version", openapi3.NewIntegerSchema().WithMin(0)))
+	auditSchema = schemaRef("AuditLog", openapi3.NewObjectSchema().
+			WithProperty("records", arrayOf(auditRecordSchema)).
+			WithProperty("next_before", openapi3.NewIntegerSchema().WithMin(1)))
+	webhookEvents = toAny([]string{list.OpInsert, list.OpRemove, list.OpSet})
+	webhookSchema = schemaRef("Webhook", openapi3.NewObjectSchema().
+			WithProperty("id", openapi3.NewStringSchema()).
+			WithProperty("url", openapi3.NewStringSchema().WithFormat("uri")).
+			WithProperty("events", arrayOf(&openapi3.SchemaRef{Value: openapi3.NewStringSchema().WithEnum(webhookEvents...)})).
+			WithProperty("secret", openapi3.NewStringSchema()).
+			WithProperty("created_at", openapi3.NewDateTimeSchema()).
+			WithRequired([]string{"url"}))
+	deadLetterSchema = schemaRef("DeadLetter", openapi3.NewObjectSchema().
+				WithProperty("payload", openapi3.NewObjectSchema().
+					WithProperty("id", openapi3.NewStringSchema()).
+					WithProperty("subscription", openapi3.NewStringSchema()).
+					WithProperty("list", openapi3.NewStringSchema()).
+					WithPropertyRef("event", eventSchema)).
+				WithProperty("attempts", openapi3.NewIntegerSchema().WithMin(0)).
+				WithProperty("last_error", openapi3.NewStringSchema()).
+				WithProperty("failed_at", openapi3.NewDateTimeSchema()))
+	errorSchema = schemaRef("Error", openapi3.NewObjectSchema().
+			WithProperty("code", openapi3.NewStringSchema()).
+			WithProperty("message", openapi3.NewStringSchema()).
+			WithProperty("details", openapi3.NewSchema()).
+			WithProperty("request_id", openapi3.NewStringSchema()).
+			WithRequired([]string{"code", "message"}))
+)
+
+func toAny(s []string) []any {
+	out := make([]any, len(s))
+	for i, v := range s {
+		out[i] = v
+	}
+	return out
+}
+
+// content lists every format a body of schema can be sent in. Protobuf only
+// has messages for entities and errors.
+func content(schema *openapi3.SchemaRef) openapi3.Content {
+	types := []string{codec.JSON.MediaType, codec.MsgPack.MediaType, codec.CBOR.MediaType}
+	if schema == entitySchema || schema == errorSchema {
+		types = append(types, codec.Protobuf.MediaType)
+	}
+	return openapi3.NewContentWithSchemaRef(schema, types)
+}
+
+func body(schema *openapi3.SchemaRef) *openapi3.RequestBodyRef {
+	return &openapi3.RequestBodyRef{
+		Value: openapi3.NewRequestBody().WithRequired(true).WithContent(content(schema)),
+	}
+}
+
+func response(op *openapi3.Operation, status int, description string, schema *openapi3.SchemaRef) {
+	r := openapi3.NewResponse().WithDescription(description)
+	if schema != nil {
+		r.WithContent(content(schema))
+	}
+	op.AddResponse(status, r)
+}
+
+// errorResponses documents error responses, which all share the Error schema.
+func errorResponses(op *openapi3.Operation, statuses map[int]string) {
+	for status, description := range statuses {
+		response(op, status, description, errorSchema)
+	}
+}
+
+func header(name, description string) *openapi3.Parameter {
+	return openapi3.NewHeaderParameter(name).
+		WithDescription(description).
+		WithSchema(openapi3.NewStringSchema())
+}
+
+var (
+	headerIfMatch        = header("If-Match", "Only change the list if its ETag matches")
+	headerIfNoneMatch    = header("If-None-Match", "Answer 304 while the list ETag matches")
+	headerIdempotencyKey = header("Idempotency-Key", "Replay the first response for this key").
+				WithSchema(openapi3.NewStringSchema().WithMaxLength(255))
+	headerLastEventID = header("Last-Event-ID", "Resume after this event id")
+)
+
+func operation(id, summary string, params ...*openapi3.Parameter) *openapi3.Operation {
+	op := openapi3.NewOperation()
+	op.OperationID = id
+	op.Summary = summary
+	for _, p := range params {
+		op.AddParameter(p)
+	}
+	return op
+}
+
+// numbersSpec documents the routes numbers() registers under prefix. named
+// says whether prefix carries the {name} of a list.
+func numbersSpec(paths *openapi3.Paths, prefix string, named bool) {
+	id := func(base string) string {
+		if named {
+			return base + "InList"
+		}
+		return base
+	}
+	item := func() *openapi3.PathItem {
+		p := &openapi3.PathItem{}
+		if named {
+			p.Parameters = openapi3.Parameters{{Value: nameParam()}}
+		}
+		return p
+	}
+	listErrors := map[int]string{}
+	if named {
+		listErrors[http.StatusNotFound] = "List not found"
+	}
+	with := func(m map[int]string) map[int]string {
+		for k, v := range listErrors {
+			if _, ok := m[k]; !ok {
+				m[k] = v
+			}
+		}
+		return m
+	}
+	index := openapi3.NewPathParameter("index").WithSchema(openapi3.NewIntegerSchema().WithMin(0))
+	query := func(name, description string, schema *openapi3.Schema) *openapi3.Parameter {
+		return openapi3.NewQueryParameter(name).WithDescription(description).WithSchema(schema)
+	}
+	// the reads can look at the list as it was at an earlier version
+	asOf := []*openapi3.Parameter{
+		query("version", "Read the list as it was at this version", openapi3.NewIntegerSchema().WithMin(0)),
+		query("at", "Read the list as it was at this time", openapi3.NewDateTimeSchema()),
+	}
+	asOfErrors := func(m map[int]string) map[int]string {
+		m = with(m)
+		m[http.StatusBadRequest] += ", or both version and at"
+		if _, ok := m[http.StatusNotFound]; ok {
+			m[http.StatusNotFound] += ", history is off, or version not reached yet"
+		} else {
+			m[http.StatusNotFound] = "History is off, or version not reached yet"
+		}
+		m[http.StatusGone] = "Version is older than the history keeps"
+		return m
+	}
+
+	insert := operation(id("insert"), "Insert a value at an index", headerIfMatch, headerIdempotencyKey)
+	insert.RequestBody = body(entitySchema)
+	response(insert, http.StatusCreated, "Inserted", entitySchema)
+	errorResponses(insert, with(map[int]string{
+		http.StatusBadRequest:         "Invalid index or body",
+		http.StatusConflict:           "List is full",
+		http.StatusPreconditionFailed: "Version mismatch",
+	}))
+	numbers := item()
+	numbers.Put = insert
+	paths.Set(prefix+"/numbers", numbers)
+
+	batch := operation(id("batch"), "Apply operations all or nothing", headerIfMatch, headerIdempotencyKey)
+	batch.RequestBody = body(batchRequestSchema)
+	response(batch, http.StatusOK, "Applied", batchResponseSchema)
+	errorResponses(batch, with(map[int]string{
+		http.StatusBadRequest:         "An operation is invalid",
+		http.StatusNotFound:           "An operation's index was not found",
+		http.StatusConflict:           "List is full",
+		http.StatusPreconditionFailed: "Version mismatch",
+	}))
+	batchItem := item()
+	batchItem.Post = batch
+	paths.Set(prefix+"/numbers:batch", batchItem)
+
+	remove := operation(id("remove"), "Remove the value at an index", index, headerIfMatch, headerIdempotencyKey)
+	response(remove, http.StatusOK, "Removed", nil)
+	errorResponses(remove, with(map[int]string{
+		http.StatusBadRequest:         "Invalid index",
+		http.StatusNotFound:           "Index not found",
+		http.StatusPreconditionFailed: "Version mismatch",
+	}))
+	removeItem := item()
+	removeItem.Delete = remove
+	paths.Set(prefix+"/numbers/{index}", removeItem)
+
+	find := operation(id("find"), "Find the first index of a value",
+		openapi3.NewPathParameter("value").WithSchema(openapi3.NewIntegerSchema()), asOf[0], asOf[1], headerIfNoneMatch)
+	response(find, http.StatusOK, "Found", entitySchema)
+	response(find, http.StatusNotModified, "List unchanged", nil)
+	errorResponses(find, asOfErrors(map[int]string{
+		http.StatusBadRequest: "Invalid value",
+		http.StatusNotFound:   "Value not found",
+	}))
+	findItem := item()
+	findItem.Get = find
+	paths.Set(prefix+"/numbers/value/{value}", findItem)
+
+	get := operation(id("get"), "Get the value at an index", index, asOf[0], asOf[1], headerIfNoneMatch)
+	response(get, http.StatusOK, "Found", entitySchema)
+	response(get, http.StatusNotModified, "List unchanged", nil)
+	errorResponses(get, asOfErrors(map[int]string{
+		http.StatusBadRequest: "Invalid index",
+		http.StatusNotFound:   "Index not found",
+	}))
+	getItem := item()
+	getItem.Get = get
+	paths.Set(prefix+"/numbers/index/{index}", getItem)
+
+	index32 := func() *openapi3.Schema {
+		return openapi3.NewIntegerSchema().WithMin(0).WithMax(math.MaxUint32)
+	}
+	search := operation(id("search"), "Find every element matching the filters",
+		query("value", "Only elements equal to this", openapi3.NewIntegerSchema()),
+		query("min", "Only elements at least this", openapi3.NewIntegerSchema()),
+		query("max", "Only elements at most this", openapi3.NewIntegerSchema()),
+		query("from", "First index to look at", index32()),
+		query("to", "Last index to look at", index32()),
+		query("cursor", "next_cursor of the previous page", index32()),
+		query("limit", "Matches per page", openapi3.NewIntegerSchema().WithMin(1).WithMax(maxSearchLimit)),
+		query("count_only", "Only count the matches", openapi3.NewBoolSchema()),
+		asOf[0], asOf[1], headerIfNoneMatch)
+	response(search, http.StatusOK, "Matches, or their count with count_only", searchSchema)
+	response(search, http.StatusNotModified, "List unchanged", nil)
+	errorResponses(search, asOfErrors(map[int]string{
+		http.StatusBadRequest: "Invalid query parameter",
+	}))
+	searchItem := item()
+	searchItem.Get = search
+	paths.Set(prefix+"/numbers/search", searchItem)
+
+	formats := make([]string, 0, len(formatTypes))
+	formatContent := openapi3.Content{}
+	for format, t := range formatTypes {
+		formats = append(formats, format)
+		formatContent[t] = openapi3.NewMediaType()
+	}
+	slices.Sort(formats)
+	formatParam := func(description string) *openapi3.Parameter {
+		return query("format", description, openapi3.NewStringSchema().WithEnum(toAny(formats)...))
+	}
+
+	export := operation(id("export"), "Download the whole list",
+		formatParam("File format, json by default"), asOf[0], asOf[1], headerIfNoneMatch)
+	export.AddResponse(http.StatusOK, openapi3.NewResponse().
+		WithDescription("Every element in order").
+		WithContent(formatContent))
+	response(export, http.StatusNotModified, "List unchanged", nil)
+	errorResponses(export, asOfErrors(map[int]string{
+		http.StatusBadRequest: "Invalid format",
+	}))
+	exportItem := item()
+	exportItem.Get = export
+	paths.Set(prefix+"/numbers/export", exportItem)
+
+	history := operation(id("history"), "Page through the changes made to the list",
+		query("after", "Only changes after this version, like next_after of the previous page", openapi3.NewIntegerSchema().WithMin(0)),
+		query("limit", "Changes per page", openapi3.NewIntegerSchema().WithMin(1).WithMax(maxHistoryLimit)))
+	response(history, http.StatusOK, "Changes, oldest first", historySchema)
+	errorResponses(history, with(map[int]string{
+		http.StatusBadRequest: "Invalid query parameter",
+		http.StatusNotFound:   "History is off",
+		http.StatusGone:       "Changes after this version are no longer kept",
+	}))
+	historyItem := item()
+	historyItem.Get = history
+	paths.Set(prefix+"/numbers/history", historyItem)
+
+	imp := operation(id("import"), "Append rows to the list or replace it with them",
+		query("mode", "append by default", openapi3.NewStringSchema().
+			WithEnum(string(list.ImportAppend), string(list.ImportReplace))),
+		formatParam("Format of the body, taken from Content-Type by default"),
+		headerIfMatch, headerIdempotencyKey)
+	imp.RequestBody = &openapi3.RequestBodyRef{
+		Value: openapi3.NewRequestBody().WithRequired(true).WithContent(formatContent),
+	}
+	imp.Extensions = map[string]any{StreamedBody: true}
+	response(imp, http.StatusOK, "Imported", importSchema)
+	errorResponses(imp, with(map[int]string{
+		http.StatusBadRequest:           "Invalid rows, listed in the details",
+		http.StatusConflict:             "List is full",
+		http.StatusPreconditionFailed:   "Version mismatch",
+		http.StatusUnsupportedMediaType: "Unknown body format",
+	}))
+	importItem := item()
+	importItem.Post = imp
+	paths.Set(prefix+"/numbers/import", importItem)
+
+	events := operation(id("events"), "Stream changes as Server-Sent Events", headerLastEventID)
+	events.AddResponse(http.StatusOK, openapi3.NewResponse().
+		WithDescription("Event stream; each data line is an Event").
+		WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema(), []string{"text/event-stream"})))
+	errorResponses(events, with(map[int]string{
+		http.StatusBadRequest: "Invalid event id",
+		http.StatusNotFound:   "List has no event feed",
+	}))
+	eventsItem := item()
+	eventsItem.Get = events
+	paths.Set(prefix+"/numbers/events", eventsItem)
+
+	ws := operation(id("eventsWebSocket"), "Stream changes over a WebSocket",
+		openapi3.NewQueryParameter("last_event_id").
+			WithDescription("Resume after this event id").
+			WithSchema(openapi3.NewStringSchema()),
+		headerLastEventID)
+	ws.AddResponse(http.StatusSwitchingProtocols, openapi3.NewResponse().
+		WithDescription("WebSocket of Event messages, in JSON").
+		WithJSONSchemaRef(eventSchema))
+	errorResponses(ws, with(map[int]string{
+		http.StatusBadRequest: "Invalid event id",
+		http.StatusNotFound:   "List has no event feed",
+	}))
+	wsItem := item()
+	wsItem.Get = ws
+	paths.Set(prefix+"/numbers/events/ws", wsItem)
+}
+
+func nameParam() *openapi3.Parameter {
+	return openapi3.NewPathParameter("name").WithSchema(openapi3.NewStringSchema())
+}
+
+func buildSpec() *openapi3.T {
+	paths := openapi3.NewPaths()
+	numbersSpec(paths, "/api/v1", false)
+	numbersSpec(paths, "/api/v1/lists/{name}", true)
+
+	lists := operation("listLists", "List the named lists")
+	response(lists, http.StatusOK, "Named lists", &openapi3.SchemaRef{Value: arrayOf(listInfoSchema)})
+	create := operation("createList", "Create a named list", headerIdempotencyKey)
+	create.RequestBody = body(listInfoSchema)
+	response(create, http.StatusCreated, "Created", listInfoSchema)
+	errorResponses(create, map[int]string{
+		http.StatusBadRequest: "Invalid list name",
+		http.StatusConflict:   "List already exists",
+	})
+	paths.Set("/api/v1/lists", &openapi3.PathItem{Get: lists, Post: create})
+
+	rename := operation("renameList", "Rename a list", headerIdempotencyKey)
+	rename.RequestBody = body(renameSchema)
+	response(rename, http.StatusOK, "Renamed", listInfoSchema)
+	errorResponses(rename, map[int]string{
+		http.StatusBadRequest: "Invalid list name, or fields other than the name",
+		http.StatusNotFound:   "List not found",
+		http.StatusConflict:   "List already exists",
+	})
+	remove := operation("deleteList", "Delete a list", headerIdempotencyKey)
+	response(remove, http.StatusOK, "Deleted", nil)
+	errorResponses(remove, map[int]string{
+		http.StatusNotFound: "List not found",
+	})
+	paths.Set("/api/v1/lists/{name}", &openapi3.PathItem{
+		Parameters: openapi3.Parameters{{Value: nameParam()}},
+		Patch:      rename,
+		Delete:     remove,
+	})
+
+	auditQuery := func(name, description string, schema *openapi3.Schema) *openapi3.Parameter {
+		return openapi3.NewQueryParameter(name).WithDescription(description).WithSchema(schema)
+	}
+	auditLog := operation("audit", "Find who changed the lists, newest first",
+		auditQuery("actor", "Only changes by this api key, JWT subject or client ip", openapi3.NewStringSchema()),
+		auditQuery("request_id", "Only changes made by this request", openapi3.NewStringSchema()),
+		auditQuery("list", "Only changes to this named list, or to the global list with -", openapi3.NewStringSchema()),
+		auditQuery("op", "Only changes of this kind", openapi3.NewStringSchema().WithEnum(list.OpInsert, list.OpRemove, list.OpSet)),
+		auditQuery("since", "Only changes made at or after this time", openapi3.NewDateTimeSchema()),
+		auditQuery("until", "Only changes made at or before this time", openapi3.NewDateTimeSchema()),
+		auditQuery("before", "next_before of the previous page", openapi3.NewIntegerSchema().WithMin(1)),
+		auditQuery("limit", "Records per page", openapi3.NewIntegerSchema().WithMin(1).WithMax(maxAuditLimit)))
+	response(auditLog, http.StatusOK, "Audit records", auditSchema)
+	errorResponses(auditLog, map[int]string{
+		http.StatusBadRequest: "Invalid query parameter",
+		http.StatusNotFound:   "Audit log is off",
+	})
+	paths.Set("/api/v1/audit", &openapi3.PathItem{Get: auditLog})
+
+	webhooksOff := map[int]string{http.StatusNotFound: "Webhooks are off"}
+	webhooks := operation("listWebhooks", "List the webhooks, without their secrets")
+	response(webhooks, http.StatusOK, "Webhooks", &openapi3.SchemaRef{Value: arrayOf(webhookSchema)})
+	errorResponses(webhooks, webhooksOff)
+	createWebhook := operation("createWebhook", "Send the changes to a url", headerIdempotencyKey)
+	createWebhook.RequestBody = body(webhookSchema)
+	response(createWebhook, http.StatusCreated, "Created, with the secret deliveries are signed with", webhookSchema)
+	errorResponses(createWebhook, map[int]string{
+		http.StatusBadRequest: "Invalid url or event",
+		http.StatusNotFound:   "Webhooks are off",
+	})
+	paths.Set("/api/v1/webhooks", &openapi3.PathItem{Get: webhooks, Post: createWebhook})
+
+	webhookID := openapi3.NewPathParameter("id").WithSchema(openapi3.NewStringSchema())
+	deleteWebhook := operation("deleteWebhook", "Stop sending changes to a webhook", headerIdempotencyKey)
+	response(deleteWebhook, http.StatusNoContent, "Deleted", nil)
+	errorResponses(deleteWebhook, map[int]string{
+		http.StatusNotFound: "Webhook not found, or webhooks are off",
+	})
+	paths.Set("/api/v1/webhooks/{id}", &openapi3.PathItem{
+		Parameters: openapi3.Parameters{{Value: webhookID}},
+		Delete:     deleteWebhook,
+	})
+	deadLetters := operation("webhookDeadLetters", "List the deliveries that failed every attempt")
+	response(deadLetters, http.StatusOK, "Failed deliveries, oldest first", &openapi3.SchemaRef{Value: arrayOf(deadLetterSchema)})
+	errorResponses(deadLetters, map[int]string{
+		http.StatusNotFound: "Webhook not found, or webhooks are off",
+	})
+	paths.Set("/api/v1/webhooks/{id}/dead-letters", &openapi3.PathItem{
+		Parameters: openapi3.Parameters{{Value: webhookID}},
+		Get:        deadLetters,
+	})
+
+	spec := operation("openAPI", "This document")
+	spec.AddResponse(http.StatusOK, openapi3.NewResponse().
+		WithDescription("OpenAPI 3 document").
+		WithJSONSchema(openapi3.NewObjectSchema()))
+	paths.Set("/api/v1/openapi.json", &openapi3.PathItem{Get: spec})
+
+	schemas := openapi3.Schemas{}
+	for _, s := range []*openapi3.SchemaRef{
+		entitySchema, listInfoSchema, renameSchema, operationSchema, batchRequestSchema,
+		batchResponseSchema, eventSchema, searchSchema, importSchema, recordSchema,
+		historySchema, auditRecordSchema, auditSchema, webhookSchema,
+		deadLetterSchema, errorSchema,
+	} {
+		schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")] = &openapi3.SchemaRef{Value: s.Value}
+	}
+
+	return &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info: &openapi3.Info{
+			Title:   "echo",
+			Version: "1",
+		},
+		Paths: paths,
+		Components: &openapi3.Components{
+			Schemas: schemas,
+			SecuritySchemes: openapi3.SecuritySchemes{
+				"bearer": &openapi3.SecuritySchemeRef{Value: openapi3.NewJWTSecurityScheme()},
+				"apiKey": &openapi3.SecuritySchemeRef{Value: openapi3.NewSecurityScheme().
+					WithType("apiKey").WithIn("header").WithName("X-API-Key")},
+			},
+		},
+		// credentials are only needed with auth.enabled
+		Security: openapi3.SecurityRequirements{
+			{},
+			{"bearer": []string{}},
+			{"apiKey": []string{}},
+		},
+	}
+}
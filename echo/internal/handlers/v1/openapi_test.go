@@ -0,0 +1,69 @@
+package v1
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/labstack/echo"
+)
+
+// TestSpecMatchesRoutes fails when a route is added or removed without
+// updating the OpenAPI document, or the other way round.
+func TestSpecMatchesRoutes(t *testing.T) {
+	l, err := list.New(list.BootList())
+	if err != nil {
+		t.Fatalf("Can't create list: %v", err)
+	}
+	r, err := list.NewRegistry()
+	if err != nil {
+		t.Fatalf("Can't create registry: %v", err)
+	}
+	e := echo.New()
+	New(e, l, r, nil, nil)
+
+	spec := Spec()
+	err = spec.Validate(context.Background())
+	if err != nil {
+		t.Fatalf("Spec is invalid: %v", err)
+	}
+
+	routes := map[string]bool{}
+	for _, route := range e.Routes() {
+		// echo groups add catch-all routes that only answer 404
+		if strings.HasPrefix(route.Name, "github.com/labstack/echo.") {
+			continue
+		}
+		if strings.HasPrefix(route.Path, "/api/v1") {
+			routes[route.Method+" "+SpecPath(route.Path)] = true
+		}
+	}
+	documented := map[string]bool{}
+	for path, item := range spec.Paths.Map() {
+		for method := range item.Operations() {
+			documented[method+" "+path] = true
+		}
+	}
+
+	var missing, stale []string
+	for route := range routes {
+		if !documented[route] {
+			missing = append(missing, route)
+		}
+	}
+	for route := range documented {
+		if !routes[route] {
+			stale = append(stale, route)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(stale)
+	if len(missing) > 0 {
+		t.Errorf("Routes missing from the spec: %v", missing)
+	}
+	if len(stale) > 0 {
+		t.Errorf("Spec documents routes that don't exist: %v", stale)
+	}
+}
@@ -0,0 +1,132 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/codec"
+	"github.com/labstack/echo"
+)
+
+const (
+	defaultSearchLimit = 100
+	maxSearchLimit     = 1000
+)
+
+type searchResponse struct {
+	Matches []list.ListEntity `json:"matches"`
+	// NextCursor is passed back as cursor to get the following page
+	NextCursor *uint  `json:"next_cursor,omitempty"`
+	Version    uint64 `json:"version"`
+}
+
+type countResponse struct {
+	Count   uint   `json:"count"`
+	Version uint64 `json:"version"`
+}
+
+func invalidParam(name string, err error) error {
+	return apierror.New(http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid "+name).WithInternal(err)
+}
+
+// intParam reads an optional integer query parameter.
+func intParam(c echo.Context, name string) (*int, error) {
+	s := c.QueryParam(name)
+	if s == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, invalidParam(name, err)
+	}
+	return &n, nil
+}
+
+// uintParam reads an optional index-like query parameter.
+func uintParam(c echo.Context, name string) (*uint, error) {
+	s := c.QueryParam(name)
+	if s == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return nil, invalidParam(name, err)
+	}
+	u := uint(n)
+	return &u, nil
+}
+
+// searchQuery turns the query parameters into a list.Query. cursor, from a
+// previous page, moves the start of the window forward.
+func searchQuery(c echo.Context) (list.Query, error) {
+	q := list.Query{Limit: defaultSearchLimit}
+	var err error
+	if q.Value, err = intParam(c, "value"); err != nil {
+		return q, err
+	}
+	if q.Min, err = intParam(c, "min"); err != nil {
+		return q, err
+	}
+	if q.Max, err = intParam(c, "max"); err != nil {
+		return q, err
+	}
+	if q.To, err = uintParam(c, "to"); err != nil {
+		return q, err
+	}
+	for _, name := range []string{"from", "cursor"} {
+		start, err := uintParam(c, name)
+		if err != nil {
+			return q, err
+		}
+		if start != nil && *start > q.From {
+			q.From = *start
+		}
+	}
+	limit, err := uintParam(c, "limit")
+	if err != nil {
+		return q, err
+	}
+	if limit != nil {
+		if *limit == 0 || *limit > maxSearchLimit {
+			return q, apierror.New(http.StatusBadRequest, apierror.CodeInvalidRequest,
+				"Limit must be between 1 and "+strconv.Itoa(maxSearchLimit))
+		}
+		q.Limit = *limit
+	}
+	if s := c.QueryParam("count_only"); s != "" {
+		q.CountOnly, err = strconv.ParseBool(s)
+		if err != nil {
+			return q, invalidParam("count_only", err)
+		}
+	}
+	return q, nil
+}
+
+// Search returns every element matching the query parameters, a page at a
+// time, or only how many there are with count_only.
+func (s *server) Search(c echo.Context) error {
+	q, err := searchQuery(c)
+	if err != nil {
+		return err
+	}
+	l, err := s.readListFor(c)
+	if err != nil {
+		return err
+	}
+
+	res := l.Search(c.Request().Context(), q)
+	setETag(c, res.Version)
+	if notModified(c, res.Version) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	if q.CountOnly {
+		return codec.Render(c, http.StatusOK, countResponse{Count: res.Count, Version: res.Version})
+	}
+	return codec.Render(c, http.StatusOK, searchResponse{
+		Matches:    res.Matches,
+		NextCursor: res.Next,
+		Version:    res.Version,
+	})
+}
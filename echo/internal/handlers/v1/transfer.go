@@ -0,0 +1,382 @@
+package v1
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/codec"
+	"github.com/labstack/echo"
+)
+
+const (
+	formatJSON   = "json"
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+)
+
+var formatTypes = map[string]string{
+	formatJSON:   echo.MIMEApplicationJSON,
+	formatCSV:    "text/csv",
+	formatNDJSON: "application/x-ndjson",
+}
+
+// maxImportErrors caps how many invalid rows an import reports; the rest
+// are only counted.
+const maxImportErrors = 100
+
+// RowError is an import row that was rejected. Line is where the row
+// starts in the body.
+type RowError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+type importResponse struct {
+	Imported int    `json:"imported"`
+	Version  uint64 `json:"version"`
+}
+
+// Export writes the whole list as a file in the format query parameter,
+// JSON by default.
+func (s *server) Export(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = formatJSON
+	}
+	contentType, ok := formatTypes[format]
+	if !ok {
+		return apierror.New(http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid format")
+	}
+	l, err := s.readListFor(c)
+	if err != nil {
+		return err
+	}
+
+	values, version := l.Values()
+	setETag(c, version)
+	if notModified(c, version) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, contentType)
+	res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="numbers.`+format+`"`)
+	res.WriteHeader(http.StatusOK)
+
+	w := bufio.NewWriter(res)
+	switch format {
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"index", "value"})
+		for i, v := range values {
+			cw.Write([]string{strconv.Itoa(i), strconv.Itoa(v)})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	case formatNDJSON:
+		for i, v := range values {
+			fmt.Fprintf(w, "{\"index\":%d,\"value\":%d}\n", i, v)
+		}
+	default:
+		w.WriteString("[")
+		for i, v := range values {
+			if i > 0 {
+				w.WriteString(",")
+			}
+			fmt.Fprintf(w, "\n{\"index\":%d,\"value\":%d}", i, v)
+		}
+		w.WriteString("\n]\n")
+	}
+	return w.Flush()
+}
+
+// Import reads rows from the body as it arrives and appends them to the
+// list, or replaces it with them when mode is replace. Rows are taken in
+// order, so an index column is ignored. If any row is invalid nothing is
+// imported and the error lists the rows.
+func (s *server) Import(c echo.Context) error {
+	mode := list.ImportMode(c.QueryParam("mode"))
+	if mode == "" {
+		mode = list.ImportAppend
+	}
+	if mode != list.ImportAppend && mode != list.ImportReplace {
+		return list.ErrInvalidImportMode
+	}
+	format, err := importFormat(c)
+	if err != nil {
+		return err
+	}
+	l, err := s.listFor(c)
+	if err != nil {
+		return err
+	}
+
+	rows := &rowReader{limit: l.MaxSize()}
+	switch format {
+	case formatCSV:
+		err = rows.csv(c.Request().Body)
+	case formatNDJSON:
+		err = rows.ndjson(c.Request().Body)
+	default:
+		err = rows.json(c.Request().Body)
+	}
+	if err != nil {
+		return err
+	}
+	if rows.invalid > 0 {
+		message := fmt.Sprintf("%d rows are invalid; nothing was imported", rows.invalid)
+		if rows.invalid == 1 {
+			message = "1 row is invalid; nothing was imported"
+		}
+		return apierror.New(http.StatusBadRequest, apierror.CodeInvalidRows, message).
+			WithDetails(rows.errs)
+	}
+
+	version, err := s.change(c, func(match list.Match) (uint64, error) {
+		return l.Import(c.Request().Context(), rows.values, mode, match)
+	})
+	setETag(c, version)
+	if err != nil {
+		return err
+	}
+	return codec.Render(c, http.StatusOK, importResponse{Imported: len(rows.values), Version: version})
+}
+
+// importFormat takes the format from the query, or else from the
+// Content-Type of the body.
+func importFormat(c echo.Context) (string, error) {
+	if format := c.QueryParam("format"); format != "" {
+		if _, ok := formatTypes[format]; !ok {
+			return "", apierror.New(http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid format")
+		}
+		return format, nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	for format, t := range formatTypes {
+		if t == mediaType {
+			return format, nil
+		}
+	}
+	return "", apierror.New(http.StatusUnsupportedMediaType, apierror.CodeUnsupportedMediaType,
+		"Send JSON, CSV or NDJSON, or name the format in the query")
+}
+
+// rowReader collects the values of an import and the rows it rejected.
+type rowReader struct {
+	values  []int
+	errs    []RowError
+	invalid int
+	// limit stops reading once more values arrived than the list can hold;
+	// zero means unlimited
+	limit uint
+}
+
+func (r *rowReader) add(value int) error {
+	if r.limit > 0 && uint(len(r.values)) >= r.limit {
+		return list.ErrListFull
+	}
+	r.values = append(r.values, value)
+	return nil
+}
+
+func (r *rowReader) reject(line int, message string) {
+	r.invalid++
+	if len(r.errs) < maxImportErrors {
+		r.errs = append(r.errs, RowError{Line: line, Message: message})
+	}
+}
+
+// row adds a JSON row, which is either a bare number or an object with a
+// value.
+func (r *rowReader) row(line int, raw []byte) error {
+	if !json.Valid(raw) {
+		r.reject(line, "invalid JSON")
+		return nil
+	}
+	if raw[0] == '{' {
+		var row struct {
+			Value json.RawMessage `json:"value"`
+		}
+		json.Unmarshal(raw, &row)
+		if row.Value == nil {
+			r.reject(line, "value is missing")
+			return nil
+		}
+		raw = row.Value
+	}
+	var value int
+	if err := json.Unmarshal(raw, &value); err != nil {
+		r.reject(line, "value must be an integer")
+		return nil
+	}
+	return r.add(value)
+}
+
+// json reads an array of rows. A syntax error ends the body, since nothing
+// after it can be trusted to line up.
+func (r *rowReader) json(body io.Reader) error {
+	lines := &lineCounter{r: body}
+	dec := json.NewDecoder(lines)
+	syntax := func(err error) error {
+		var se *json.SyntaxError
+		switch {
+		case errors.As(err, &se):
+			r.reject(lines.lineAt(se.Offset), "invalid JSON: "+se.Error())
+		case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+			r.reject(lines.lineAt(dec.InputOffset()), "body ends early")
+		default:
+			return err
+		}
+		return nil
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return syntax(err)
+	}
+	if tok != json.Delim('[') {
+		r.reject(1, "body must be an array")
+		return nil
+	}
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return syntax(err)
+		}
+		line := lines.lineAt(dec.InputOffset() - int64(len(raw)))
+		if err := r.row(line, raw); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return syntax(err)
+	}
+	return nil
+}
+
+// ndjson reads one row per line; blank lines are skipped.
+func (r *rowReader) ndjson(body io.Reader) error {
+	scanner := bufio.NewScanner(body)
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		if err := r.row(line, raw); err != nil {
+			return err
+		}
+	}
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		r.reject(line+1, "line is too long")
+		return nil
+	}
+	return scanner.Err()
+}
+
+// csv reads one row per record. A first record that isn't all numbers is a
+// header naming the value column; without one the value is the last field,
+// so exported files read back as they are.
+func (r *rowReader) csv(body io.Reader) error {
+	cr := csv.NewReader(body)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	column := -1
+	first := true
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			r.reject(pe.StartLine, pe.Err.Error())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := cr.FieldPos(0)
+
+		if first {
+			first = false
+			if isHeader(record) {
+				for i, name := range record {
+					if strings.EqualFold(strings.TrimSpace(name), "value") {
+						column = i
+					}
+				}
+				if column < 0 {
+					r.reject(line, "header has no value column")
+					return nil
+				}
+				continue
+			}
+		}
+
+		i := column
+		if i < 0 {
+			i = len(record) - 1
+		}
+		if i >= len(record) {
+			r.reject(line, "value is missing")
+			continue
+		}
+		value, err := strconv.Atoi(strings.TrimSpace(record[i]))
+		if err != nil {
+			r.reject(line, "value must be an integer")
+			continue
+		}
+		if err := r.add(value); err != nil {
+			return err
+		}
+	}
+}
+
+func isHeader(record []string) bool {
+	for _, field := range record {
+		if _, err := strconv.Atoi(strings.TrimSpace(field)); err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// lineCounter tells which line a byte offset of what was read through it
+// is on. It only keeps what was read past the last offset asked about, so
+// offsets must not go backwards.
+type lineCounter struct {
+	r    io.Reader
+	buf  []byte
+	base int64
+	line int
+}
+
+func (lc *lineCounter) Read(p []byte) (int, error) {
+	n, err := lc.r.Read(p)
+	lc.buf = append(lc.buf, p[:n]...)
+	return n, err
+}
+
+func (lc *lineCounter) lineAt(offset int64) int {
+	n := min(max(offset-lc.base, 0), int64(len(lc.buf)))
+	lc.line += bytes.Count(lc.buf[:n], []byte("\n"))
+	lc.buf = lc.buf[n:]
+	lc.base += n
+	return lc.line + 1
+}
@@ -0,0 +1,123 @@
+package v1
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+)
+
+func TestRowReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		body   string
+		limit  uint
+		values []int
+		errs   []RowError
+		err    error
+	}{
+		{
+			name:   "csv export",
+			format: formatCSV,
+			body:   "index,value\n0,5\n1,-7\n",
+			values: []int{5, -7},
+		},
+		{
+			name:   "csv without header",
+			format: formatCSV,
+			body:   "5\n7\n",
+			values: []int{5, 7},
+		},
+		{
+			name:   "csv value column",
+			format: formatCSV,
+			body:   "Value,note\n5,a\nx,b\n,c\n",
+			values: []int{5},
+			errs: []RowError{
+				{Line: 3, Message: "value must be an integer"},
+				{Line: 4, Message: "value must be an integer"},
+			},
+		},
+		{
+			name:   "csv without value column",
+			format: formatCSV,
+			body:   "a,b\n1,2\n",
+			errs:   []RowError{{Line: 1, Message: "header has no value column"}},
+		},
+		{
+			name:   "json",
+			format: formatJSON,
+			body:   "[\n  1,\n  {\"index\": 9, \"value\": 2},\n  {\"index\": 3},\n  \"4\", 1.5\n]",
+			values: []int{1, 2},
+			errs: []RowError{
+				{Line: 4, Message: "value is missing"},
+				{Line: 5, Message: "value must be an integer"},
+				{Line: 5, Message: "value must be an integer"},
+			},
+		},
+		{
+			name:   "json syntax error",
+			format: formatJSON,
+			body:   "[1,\n2,\n{\"value\" 3}]",
+			values: []int{1, 2},
+			errs:   []RowError{{Line: 3, Message: "invalid JSON: invalid character '3' after object key"}},
+		},
+		{
+			name:   "json cut short",
+			format: formatJSON,
+			body:   "[1,\n2",
+			values: []int{1, 2},
+			errs:   []RowError{{Line: 2, Message: "invalid JSON: unexpected end of JSON input"}},
+		},
+		{
+			name:   "json not an array",
+			format: formatJSON,
+			body:   `{"value": 1}`,
+			errs:   []RowError{{Line: 1, Message: "body must be an array"}},
+		},
+		{
+			name:   "ndjson",
+			format: formatNDJSON,
+			body:   "{\"value\": 1}\n\n2\n{oops\n",
+			values: []int{1, 2},
+			errs:   []RowError{{Line: 4, Message: "invalid JSON"}},
+		},
+		{
+			name:   "more than fit",
+			format: formatNDJSON,
+			body:   "1\n2\n3\n",
+			limit:  2,
+			values: []int{1, 2},
+			err:    list.ErrListFull,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &rowReader{limit: tt.limit}
+			var err error
+			switch tt.format {
+			case formatCSV:
+				err = r.csv(strings.NewReader(tt.body))
+			case formatNDJSON:
+				err = r.ndjson(strings.NewReader(tt.body))
+			default:
+				err = r.json(strings.NewReader(tt.body))
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if len(r.values) != len(tt.values) || (len(tt.values) > 0 && !reflect.DeepEqual(r.values, tt.values)) {
+				t.Errorf("values = %v, want %v", r.values, tt.values)
+			}
+			if !reflect.DeepEqual(r.errs, tt.errs) {
+				t.Errorf("errs = %v, want %v", r.errs, tt.errs)
+			}
+			if r.invalid != len(tt.errs) {
+				t.Errorf("invalid = %d, want %d", r.invalid, len(tt.errs))
+			}
+		})
+	}
+}
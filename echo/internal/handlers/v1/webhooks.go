@@ -0,0 +1,72 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/apierror"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/handlers/codec"
+	"github.com/labstack/echo"
+)
+
+type webhookRequest struct {
+	URL    string   `json:"url" validate:"required,url"`
+	Events []string `json:"events" validate:"dive,oneof=insert remove set"`
+	// Secret signs the deliveries; a random one is made when it is empty
+	Secret string `json:"secret"`
+}
+
+func (s *server) webhooksOn() error {
+	if s.webhooks == nil {
+		return apierror.New(http.StatusNotFound, apierror.CodeNotFound, "Webhooks are off")
+	}
+	return nil
+}
+
+func (s *server) CreateWebhook(c echo.Context) error {
+	if err := s.webhooksOn(); err != nil {
+		return err
+	}
+	data := webhookRequest{}
+	if err := c.Bind(&data); err != nil {
+		return err
+	}
+	if err := c.Validate(&data); err != nil {
+		return err
+	}
+
+	sub, err := s.webhooks.Subscribe(data.URL, data.Events, data.Secret)
+	if err != nil {
+		return err
+	}
+	return codec.Render(c, http.StatusCreated, sub)
+}
+
+func (s *server) Webhooks(c echo.Context) error {
+	if err := s.webhooksOn(); err != nil {
+		return err
+	}
+	return codec.Render(c, http.StatusOK, s.webhooks.Subscriptions())
+}
+
+func (s *server) DeleteWebhook(c echo.Context) error {
+	if err := s.webhooksOn(); err != nil {
+		return err
+	}
+	err := s.webhooks.Unsubscribe(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// DeadLetters returns the deliveries to a webhook that failed every attempt.
+func (s *server) DeadLetters(c echo.Context) error {
+	if err := s.webhooksOn(); err != nil {
+		return err
+	}
+	letters, err := s.webhooks.DeadLetters(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	return codec.Render(c, http.StatusOK, letters)
+}
@@ -0,0 +1,122 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	echov1 "github.com/alipourhabibi/exercises-journal/echo/api/echo/v1"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/auth"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// writes are the methods that change a list; the others are reads, like
+// the GET routes.
+var writes = map[string]bool{
+	echov1.ListService_Insert_FullMethodName: true,
+	echov1.ListService_Remove_FullMethodName: true,
+}
+
+type actorKey struct{}
+
+// actor is who made a call: the principal it authenticated as, or else the
+// address it came from.
+func actor(ctx context.Context) string {
+	if name, ok := ctx.Value(actorKey{}).(string); ok {
+		return name
+	}
+	return peerHost(ctx)
+}
+
+// peerHost is the ip address of the caller.
+func peerHost(ctx context.Context) string {
+	pr, ok := peer.FromContext(ctx)
+	if !ok {
+		return "unknown"
+	}
+	host, _, err := net.SplitHostPort(pr.Addr.String())
+	if err != nil {
+		return pr.Addr.String()
+	}
+	return host
+}
+
+// credentials takes the token from "authorization: Bearer" or x-api-key
+// metadata, like the http headers.
+func credentials(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if h := md.Get("authorization"); len(h) > 0 {
+		scheme, token, ok := strings.Cut(h[0], " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	if h := md.Get("x-api-key"); len(h) > 0 {
+		return h[0]
+	}
+	return ""
+}
+
+// guard applies the same auth, rate limits and follower rules as the http
+// middleware. The context it returns carries the principal, if any.
+func (s *server) guard(ctx context.Context, method string) (context.Context, error) {
+	write := writes[method]
+
+	var p *auth.Principal
+	if s.auth != nil && s.auth.Enabled() {
+		principal, err := s.auth.Authenticate(credentials(ctx))
+		if errors.Is(err, auth.ErrNoCredentials) {
+			return ctx, status.Error(codes.Unauthenticated, "Missing credentials")
+		}
+		if err != nil {
+			return ctx, status.Error(codes.Unauthenticated, "Invalid credentials")
+		}
+		if write && !principal.Role.Writes() {
+			return ctx, status.Error(codes.PermissionDenied, "Role "+string(principal.Role)+" may not do this")
+		}
+		p = &principal
+		ctx = context.WithValue(ctx, actorKey{}, principal.Name)
+	}
+
+	if s.limiter != nil && s.limiter.Enabled() {
+		client := "ip:" + peerHost(ctx)
+		if p != nil && s.limiter.KeyBy() == ratelimit.KeyByAPIKey {
+			client = "key:" + p.Name
+		}
+		ok, wait := s.limiter.Allow(client, write)
+		if !ok {
+			seconds := int(math.Ceil(wait.Seconds()))
+			return ctx, status.Error(codes.ResourceExhausted, "Too many requests; retry in "+strconv.Itoa(seconds)+"s")
+		}
+	}
+
+	if write && s.leader != "" {
+		return ctx, status.Error(codes.FailedPrecondition, "This is a follower; send writes to the leader at "+s.leader)
+	}
+	return ctx, nil
+}
+
+func (s *server) unaryGuard(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := s.guard(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *server) streamGuard(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	_, err := s.guard(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
@@ -0,0 +1,238 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+
+	echov1 "github.com/alipourhabibi/exercises-journal/echo/api/echo/v1"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/audit"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/cluster"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/go-playground/validator"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// opReset matches the reset event of the http event streams.
+const opReset = "reset"
+
+// listFor returns the named list, or the global one for an empty name.
+func (s *server) listFor(name string) (*list.ListService, error) {
+	if name == "" {
+		return s.list, nil
+	}
+	l, ok := s.lists.Get(name)
+	if !ok {
+		return nil, status.Error(codes.NotFound, "List not found")
+	}
+	return l, nil
+}
+
+// newValidator checks requests with the rules the http API applies to the
+// same entities, naming fields as the http API does.
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// invalid turns failed validation into an InvalidArgument status naming the
+// fields and the rules they broke.
+func invalid(ctx context.Context, err error) error {
+	var fields validator.ValidationErrors
+	if !errors.As(err, &fields) {
+		return listError(ctx, err)
+	}
+	violations := make([]string, 0, len(fields))
+	for _, f := range fields {
+		violations = append(violations, fmt.Sprintf("%s is %s", f.Field(), f.Tag()))
+	}
+	return status.Error(codes.InvalidArgument, "Request is invalid: "+strings.Join(violations, ", "))
+}
+
+// listError maps list errors to gRPC statuses, with the messages the http
+// API uses.
+func listError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, list.ErrInvalidIndex):
+		return status.Error(codes.InvalidArgument, "Invalid index")
+	case errors.Is(err, list.ErrIndexNotFound):
+		return status.Error(codes.NotFound, "Index not found")
+	case errors.Is(err, list.ErrValueNotFound):
+		return status.Error(codes.NotFound, "Value not found")
+	case errors.Is(err, list.ErrListFull):
+		return status.Error(codes.ResourceExhausted, "List is full")
+	case errors.Is(err, list.ErrVersionMismatch):
+		return status.Error(codes.FailedPrecondition, "Version mismatch")
+	case errors.Is(err, cluster.ErrNotLeader):
+		return status.Error(codes.FailedPrecondition, "This node isn't the leader; send writes to the one that is")
+	case errors.Is(err, cluster.ErrNotStarted):
+		return status.Error(codes.Unavailable, "This node hasn't joined the cluster yet")
+	}
+	// like the http API, keep what went wrong out of the response
+	slog.ErrorContext(ctx, "call failed", "error", err)
+	return status.Error(codes.Internal, "Internal error")
+}
+
+// ifVersion turns an optional version into a list precondition.
+func ifVersion(v *uint64) list.Match {
+	if v == nil {
+		return nil
+	}
+	want := *v
+	return func(version uint64) bool {
+		return version == want
+	}
+}
+
+// change runs fn, a change to the list named name, with a context that
+// gathers its events, and logs them to the audit log like the http API
+// does.
+func (s *server) change(ctx context.Context, name string, fn func(ctx context.Context) (uint64, error)) (uint64, error) {
+	if s.audit == nil {
+		return fn(ctx)
+	}
+	ctx, events := list.Collect(ctx)
+	version, err := fn(ctx)
+	if err != nil {
+		return version, err
+	}
+	// the change is made, so failing to log it mustn't fail the call
+	err = s.audit.Commit(events.Events(), audit.Request{Actor: actor(ctx), List: name})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not write audit records", "error", err)
+	}
+	return version, nil
+}
+
+func (s *server) Insert(ctx context.Context, req *echov1.InsertRequest) (*echov1.InsertResponse, error) {
+	err := s.validate.Struct(list.ListEntity{Index: uint(req.Index), Value: int(req.Value)})
+	if err != nil {
+		return nil, invalid(ctx, err)
+	}
+	l, err := s.listFor(req.List)
+	if err != nil {
+		return nil, err
+	}
+	version, err := s.change(ctx, req.List, func(ctx context.Context) (uint64, error) {
+		return l.Insert(ctx, uint(req.Index), int(req.Value), ifVersion(req.IfVersion))
+	})
+	if err != nil {
+		return nil, listError(ctx, err)
+	}
+	return &echov1.InsertResponse{Version: version}, nil
+}
+
+func (s *server) Remove(ctx context.Context, req *echov1.RemoveRequest) (*echov1.RemoveResponse, error) {
+	l, err := s.listFor(req.List)
+	if err != nil {
+		return nil, err
+	}
+	version, err := s.change(ctx, req.List, func(ctx context.Context) (uint64, error) {
+		return l.Remove(ctx, uint(req.Index), ifVersion(req.IfVersion))
+	})
+	if err != nil {
+		return nil, listError(ctx, err)
+	}
+	return &echov1.RemoveResponse{Version: version}, nil
+}
+
+func (s *server) Find(ctx context.Context, req *echov1.FindRequest) (*echov1.FindResponse, error) {
+	l, err := s.listFor(req.List)
+	if err != nil {
+		return nil, err
+	}
+	index, version, err := l.Find(ctx, int(req.Value))
+	if err != nil {
+		return nil, listError(ctx, err)
+	}
+	return &echov1.FindResponse{Index: uint32(index), Version: version}, nil
+}
+
+func (s *server) Get(ctx context.Context, req *echov1.GetRequest) (*echov1.GetResponse, error) {
+	l, err := s.listFor(req.List)
+	if err != nil {
+		return nil, err
+	}
+	value, version, err := l.Get(ctx, uint(req.Index))
+	if err != nil {
+		return nil, listError(ctx, err)
+	}
+	return &echov1.GetResponse{Value: int64(value), Version: version}, nil
+}
+
+func event(e list.Event) *echov1.Event {
+	return &echov1.Event{
+		Version: e.Version,
+		Op:      e.Op,
+		Index:   uint32(e.Index),
+		Value:   int64(e.Value),
+	}
+}
+
+// Watch sends the changes of a list until the client goes away, falls
+// behind, or the server shuts down.
+func (s *server) Watch(req *echov1.WatchRequest, stream grpc.ServerStreamingServer[echov1.Event]) error {
+	l, err := s.listFor(req.List)
+	if err != nil {
+		return err
+	}
+	feed := l.Feed()
+	if feed == nil {
+		return status.Error(codes.FailedPrecondition, "List has no event feed")
+	}
+
+	var sub *list.Subscription
+	reset := false
+	if req.LastVersion == nil {
+		sub = feed.Subscribe()
+	} else {
+		sub, err = feed.Resume(*req.LastVersion)
+		if errors.Is(err, list.ErrEventsGone) {
+			sub, reset = feed.Subscribe(), true
+		} else if err != nil {
+			return listError(stream.Context(), err)
+		}
+	}
+	defer sub.Close()
+
+	if reset {
+		err = stream.Send(&echov1.Event{Op: opReset, Version: sub.From})
+		if err != nil {
+			return err
+		}
+	}
+	for _, e := range sub.Replay {
+		err = stream.Send(event(e))
+		if err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.Unavailable, "Fell behind; resume from the last version")
+			}
+			err = stream.Send(event(e))
+			if err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		case <-s.closing:
+			return status.Error(codes.Unavailable, "Server is shutting down")
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestListError(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range []struct {
+		name    string
+		err     error
+		code    codes.Code
+		message string
+	}{
+		{"known", list.ErrListFull, codes.ResourceExhausted, "List is full"},
+		{"unknown", errors.New("open /var/lib/echo/snapshot: permission denied"), codes.Internal, "Internal error"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := status.Convert(listError(ctx, tt.err))
+			if s.Code() != tt.code || s.Message() != tt.message {
+				t.Errorf("listError() = %v %q, want %v %q", s.Code(), s.Message(), tt.code, tt.message)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	ctx := context.Background()
+	err := newValidator().Struct(list.ListEntity{Value: 0})
+	s := status.Convert(invalid(ctx, err))
+	if s.Code() != codes.InvalidArgument || s.Message() != "Request is invalid: value is required" {
+		t.Errorf("invalid() = %v %q", s.Code(), s.Message())
+	}
+	if err := newValidator().Struct(list.ListEntity{Value: 3}); err != nil {
+		t.Errorf("Validating value 3 failed with %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	echov1 "github.com/alipourhabibi/exercises-journal/echo/api/echo/v1"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/audit"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/auth"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/list"
+	"github.com/alipourhabibi/exercises-journal/echo/internal/core/ratelimit"
+	"github.com/go-playground/validator"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	grpccreds "google.golang.org/grpc/credentials"
+)
+
+type server struct {
+	echov1.UnimplementedListServiceServer
+	g       *grpc.Server
+	ln      net.Listener
+	list    *list.ListService
+	lists   *list.Registry
+	auth    *auth.AuthService
+	limiter *ratelimit.LimiterService
+	// audit logs who changed what; nil turns it off
+	audit *audit.Log
+	// validate holds requests to the rules the http API checks
+	validate *validator.Validate
+	// leader is where a follower sends clients that write; empty when
+	// writes are allowed
+	leader string
+	// tls serves over TLS when set
+	tls *tls.Config
+	// closing is closed on shutdown so Watch streams end instead of holding
+	// the graceful stop up
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+type ServerConfiguration func(*server) error
+
+// New builds a gRPC server around the same lists the http server uses.
+func New(cfgs ...ServerConfiguration) (*server, error) {
+	s := &server{
+		closing:  make(chan struct{}),
+		validate: newValidator(),
+	}
+	for _, cfg := range cfgs {
+		err := cfg(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if s.list == nil || s.lists == nil {
+		return nil, errors.New("rpc: a list and a registry are required")
+	}
+
+	opts := []grpc.ServerOption{
+		// spans cover the whole call, guards included, and continue the
+		// trace in the caller's metadata
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(s.unaryGuard),
+		grpc.ChainStreamInterceptor(s.streamGuard),
+	}
+	if s.tls != nil {
+		opts = append(opts, grpc.Creds(grpccreds.NewTLS(s.tls)))
+	}
+	s.g = grpc.NewServer(opts...)
+	echov1.RegisterListServiceServer(s.g, s)
+	return s, nil
+}
+
+func WithList(l *list.ListService) ServerConfiguration {
+	return func(s *server) error {
+		s.list = l
+		return nil
+	}
+}
+
+func WithRegistry(r *list.Registry) ServerConfiguration {
+	return func(s *server) error {
+		s.lists = r
+		return nil
+	}
+}
+
+// WithAuth requires calls to authenticate against a, the same service the
+// http server uses.
+func WithAuth(a *auth.AuthService) ServerConfiguration {
+	return func(s *server) error {
+		s.auth = a
+		return nil
+	}
+}
+
+// WithRateLimit takes calls out of the same budgets as http requests.
+func WithRateLimit(l *ratelimit.LimiterService) ServerConfiguration {
+	return func(s *server) error {
+		s.limiter = l
+		return nil
+	}
+}
+
+// WithAudit logs the changes made through the server to a, the log the
+// http server writes too.
+func WithAudit(a *audit.Log) ServerConfiguration {
+	return func(s *server) error {
+		s.audit = a
+		return nil
+	}
+}
+
+// WithReadOnly refuses writes, since the lists follow the leader at
+// leader.
+func WithReadOnly(leader string) ServerConfiguration {
+	return func(s *server) error {
+		s.leader = leader
+		return nil
+	}
+}
+
+// WithTLS serves over TLS with cfg, which may look up its certificate on
+// every handshake.
+func WithTLS(cfg *tls.Config) ServerConfiguration {
+	return func(s *server) error {
+		s.tls = cfg
+		return nil
+	}
+}
+
+// Listen binds the server to port without serving yet, so the caller knows
+// the port is usable before it retires a previous server.
+func (s *server) Listen(port uint) error {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	s.ln = ln
+	return nil
+}
+
+// Start serves on the listener from Listen until the server is shut down.
+func (s *server) Start(ctx context.Context) error {
+	if s.ln == nil {
+		return errors.New("rpc: Listen must be called before Start")
+	}
+	err := s.g.Serve(s.ln)
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown ends the open streams and waits for the other calls to finish,
+// cutting them off once ctx is done.
+func (s *server) Shutdown(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+	stopped := make(chan struct{})
+	go func() {
+		s.g.GracefulStop()
+		close(stopped)
+	}()
+	// a server that never started still holds the listener from Listen
+	defer func() {
+		if s.ln != nil {
+			s.ln.Close()
+		}
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.g.Stop()
+		return ctx.Err()
+	}
+}
@@ -1,11 +1,18 @@
 package main
 
 import (
+	"errors"
+	"os"
+
 	"github.com/alipourhabibi/exercises-journal/echo/cmd"
 )
 
 func main() {
 	err := cmd.Execute()
+	var exit *cmd.ExitError
+	if errors.As(err, &exit) {
+		os.Exit(exit.Code)
+	}
 	if err != nil {
 		panic(err)
 	}
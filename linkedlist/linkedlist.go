@@ -97,3 +97,15 @@ func (l *LinkedList) Get(index uint) (int, bool) {
 	}
 	return head.Data, true
 }
+
+// Iterate calls fn with each element in order until fn returns false,
+// walking the list once.
+func (l *LinkedList) Iterate(fn func(index uint, data int) bool) {
+	index := uint(0)
+	for head := l.head; head != nil; head = head.next {
+		if !fn(index, head.Data) {
+			return
+		}
+		index++
+	}
+}
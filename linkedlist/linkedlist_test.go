@@ -292,3 +292,27 @@ func TestPropertyBasedTest(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestIterate(t *testing.T) {
+	l := New()
+	for k, v := range []int{5, 6, 7, 8} {
+		l.Insert(uint(k), v)
+	}
+
+	var got []int
+	l.Iterate(func(index uint, data int) bool {
+		if index != uint(len(got)) {
+			t.Fatalf("Item %d was given index %d", len(got), index)
+		}
+		got = append(got, data)
+		return data != 7
+	})
+	if len(got) != 3 || got[0] != 5 || got[2] != 7 {
+		t.Fatalf("Iterate should stop after 7 but gave %v", got)
+	}
+
+	New().Iterate(func(uint, int) bool {
+		t.Fatalf("Iterate shouldn't call fn on an empty list")
+		return false
+	})
+}